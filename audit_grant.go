@@ -0,0 +1,113 @@
+package securelog
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrOutsideGrant indicates a request for records an audit grant does not cover.
+var ErrOutsideGrant = errors.New("records outside audit grant")
+
+// AuditGrant entitles an auditor to verify records [From, To] of a log on the
+// V-chain. T derives Key from A_0 and computes both aggregate tags itself, so
+// the auditor needs nothing else from the log owner.
+//
+// Key evolution is one-way, so the grant reveals nothing about records before
+// From. It does allow computing later keys; VerifyGrant only reads [From, To].
+type AuditGrant struct {
+	LogID    string
+	Auditor  string        // who the grant was issued to
+	From     uint64        // a - first record covered
+	To       uint64        // b - last record covered
+	Key      [KeySize]byte // A_{a-1}
+	StartTag [32]byte      // μ_V,a-1 (zero when From == 1)
+	EndTag   [32]byte      // μ_V,b
+	IssuedAt time.Time
+	// Redactable is set for grants on logs written with Config.Redactable;
+	// VerifyGrant then verifies the range as redactable records.
+	Redactable bool
+}
+
+// IssueGrant verifies records (the log from its first entry through at least
+// to) on the T-chain, recomputes the V-chain from A_0, and returns a grant for
+// [from, to]. The grant is recorded and can be listed with Grants.
+func (ts *TrustedServer) IssueGrant(logID, auditor string, records []Record, from, to uint64) (AuditGrant, error) {
+	commit, ok := ts.commitments[logID]
+	if !ok {
+		return AuditGrant{}, errors.New("log not registered with trusted server")
+	}
+	if from == 0 || from > to {
+		return AuditGrant{}, fmt.Errorf("invalid grant range [%d, %d]", from, to)
+	}
+	if uint64(len(records)) < to || records[0].Index != 1 {
+		return AuditGrant{}, fmt.Errorf("records do not cover [1, %d]", to)
+	}
+	records = records[:to]
+
+	// The T-chain authenticates the records; V tags alone could have been
+	// recomputed by anyone holding A_1. Cached checkpoints are not used since
+	// they only vouch for the record at the checkpoint, not the V tags before it.
+	var zeroTag [32]byte
+	trusted := chainOptions{redactable: commit.Redactable}
+	if _, err := verifyChain(context.Background(), records, 0, commit.KeyB0, zeroTag, false, trusted); err != nil {
+		return AuditGrant{}, fmt.Errorf("verify T-chain: %w", err)
+	}
+
+	g := AuditGrant{LogID: logID, Auditor: auditor, From: from, To: to, Key: commit.KeyA0, IssuedAt: time.Now(), Redactable: commit.Redactable}
+	for i := uint64(1); i < from; i++ {
+		fwdKey(&g.Key) // A_i = H(A_{i-1})
+	}
+	co := chainOptions{redactable: commit.Redactable, onStep: func(r Record, _ [KeySize]byte, tag [32]byte) {
+		if r.Index == from-1 {
+			g.StartTag = tag
+		}
+	}}
+	end, err := verifyChain(context.Background(), records, 0, commit.KeyA0, zeroTag, true, co)
+	if err != nil {
+		return AuditGrant{}, fmt.Errorf("verify V-chain: %w", err)
+	}
+	g.EndTag = end
+
+	ts.grantMu.Lock()
+	ts.grants[logID] = append(ts.grants[logID], g)
+	ts.grantMu.Unlock()
+	return g, nil
+}
+
+// Grants returns the audit grants issued for logID, in issue order.
+func (ts *TrustedServer) Grants(logID string) []AuditGrant {
+	ts.grantMu.Lock()
+	defer ts.grantMu.Unlock()
+	return append([]AuditGrant(nil), ts.grants[logID]...)
+}
+
+// VerifyGrant verifies exactly the records covered by g on the V-chain.
+func (v *SemiTrustedVerifier) VerifyGrant(g AuditGrant) error {
+	return v.VerifyGrantContext(context.Background(), g, VerifyOptions{})
+}
+
+// VerifyGrantContext is VerifyGrant with cancellation and progress reporting.
+func (v *SemiTrustedVerifier) VerifyGrantContext(ctx context.Context, g AuditGrant, opts VerifyOptions) error {
+	if g.From == 0 || g.From > g.To {
+		return fmt.Errorf("invalid grant range [%d, %d]", g.From, g.To)
+	}
+	recs, err := collectRange(ctx, v.store, g.From, g.To)
+	if err != nil {
+		return err
+	}
+	if uint64(len(recs)) != g.To-g.From+1 {
+		return ErrGap
+	}
+	opts.Redactable = opts.Redactable || g.Redactable
+	final, err := VerifyChainContext(ctx, recs, g.From-1, g.Key, g.StartTag, true, opts)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(final[:], g.EndTag[:]) {
+		return ErrTagMismatch
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package securelog
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+func TestAuditGrant(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-grant-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logID := "grant-log"
+	commit, _, err := logger.InitProtocol(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := NewTrustedServer()
+	ts.RegisterLog(commit)
+	records := readAllRecords(t, store)
+
+	grant, err := ts.IssueGrant(logID, "auditor-q3", records, 6, 15)
+	if err != nil {
+		t.Fatalf("IssueGrant failed: %v", err)
+	}
+	if grant.StartTag != records[4].TagV || grant.EndTag != records[14].TagV {
+		t.Fatal("Grant tags do not match the V-chain")
+	}
+
+	verifier := NewSemiTrustedVerifier(store)
+	if err := verifier.VerifyGrant(grant); err != nil {
+		t.Fatalf("VerifyGrant failed: %v", err)
+	}
+
+	// Grants survive a protobuf round trip.
+	decoded, err := FromProtoAuditGrant(ToProtoAuditGrant(grant))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.VerifyGrant(decoded); err != nil {
+		t.Fatalf("VerifyGrant after protobuf round trip failed: %v", err)
+	}
+
+	// A grant from the first record starts at A_0 with no aggregate.
+	first, err := ts.IssueGrant(logID, "auditor-q1", records, 1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Key != commit.KeyA0 {
+		t.Error("Grant from record 1 should carry A_0")
+	}
+	if err := verifier.VerifyGrant(first); err != nil {
+		t.Fatalf("VerifyGrant from first record failed: %v", err)
+	}
+
+	if got := ts.Grants(logID); len(got) != 2 || got[0].Auditor != "auditor-q3" {
+		t.Fatalf("Expected both grants recorded, got %+v", got)
+	}
+
+	// The grant does not vouch for a longer range.
+	widened := grant
+	widened.To = 16
+	if err := verifier.VerifyGrant(widened); !errors.Is(err, ErrTagMismatch) {
+		t.Errorf("Expected ErrTagMismatch for widened grant, got: %v", err)
+	}
+
+	// T refuses to issue grants over records whose T-chain does not verify.
+	tampered := append([]Record(nil), records...)
+	tampered[9].Msg = []byte("forged")
+	if _, err := ts.IssueGrant(logID, "auditor", tampered, 6, 15); err == nil {
+		t.Error("Expected IssueGrant to reject tampered records")
+	}
+	if _, err := ts.IssueGrant(logID, "auditor", records, 10, 5); err == nil {
+		t.Error("Expected error for inverted range")
+	}
+	if _, err := ts.IssueGrant(logID, "auditor", records, 1, 100); err == nil {
+		t.Error("Expected error for range beyond records")
+	}
+}
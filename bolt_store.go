@@ -0,0 +1,525 @@
+package securelog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"iter"
+	"math"
+	"os"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltStore keeps a log in a bbolt database, an embedded pure-Go B+tree
+// key-value store: lighter than SQLite, and indexed unlike the flat file
+// store. Each kind of state has its own bucket, keyed by the big-endian
+// record index so cursors walk it in index order:
+//
+//	records: idx -> [8]byte ts || [1]byte codec || [32]byte tagV || [32]byte tagT || msg
+//	anchors: idx -> [32]byte key (A_i) || [32]byte tagV || [32]byte tagT
+//	tree:    idx -> [32]byte leaf hash || [32]byte root over records 1..idx
+//	tail:    "tail" -> [8]byte idx || [32]byte tagV || [32]byte tagT
+//	meta:    "version" -> [2]byte boltFormatVersion
+//
+// The message is stored with codec (see compressMsg). Every Append is a
+// single transaction, so a crash leaves either all or none of a record.
+type boltStore struct {
+	db       *bolt.DB
+	readOnly bool
+	compress bool // compress messages on write
+
+	compressed compressionCounter
+}
+
+// BoltStoreOptions configures OpenBoltStoreWithOptions.
+type BoltStoreOptions struct {
+	Check    CheckMode // check (and repair) the store before returning it
+	ReadOnly bool      // open an existing database for reading only; other processes may read it too
+	Compress bool      // compress messages below the MAC layer (see CompressionStats); ignored when ReadOnly
+}
+
+// boltFormatVersion is stored in the meta bucket.
+const boltFormatVersion uint16 = 1
+
+const (
+	boltRecordHeader = 8 + 1 + 2*32 // ts, codec and tags before the message
+	boltAnchorSize   = KeySize + 2*32
+	boltTreeSize     = 2 * 32
+	boltTailSize     = 8 + 2*32
+
+	// boltBatchSize is the number of records read per transaction while
+	// iterating, so no transaction stays open while callers hold a record.
+	boltBatchSize = 256
+)
+
+var (
+	boltRecordsBucket = []byte("records")
+	boltAnchorsBucket = []byte("anchors")
+	boltTreeBucket    = []byte("tree")
+	boltTailBucket    = []byte("tail")
+	boltMetaBucket    = []byte("meta")
+
+	boltTailKey    = []byte("tail")
+	boltVersionKey = []byte("version")
+)
+
+// OpenBoltStore opens or creates a bbolt database at path. bbolt locks the
+// file, so one process at a time may open it for writing.
+func OpenBoltStore(path string) (Store, error) {
+	return OpenBoltStoreWithOptions(path, BoltStoreOptions{})
+}
+
+// OpenBoltStoreWithOptions is OpenBoltStore with options.
+func OpenBoltStoreWithOptions(path string, opts BoltStoreOptions) (Store, error) {
+	if opts.ReadOnly {
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	if opts.ReadOnly {
+		err = db.View(checkBoltFormat)
+	} else {
+		err = db.Update(ensureBoltBuckets)
+	}
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	st := &boltStore{db: db, readOnly: opts.ReadOnly, compress: opts.Compress && !opts.ReadOnly}
+	if err := checkOnOpen(st, opts.Check); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return st, nil
+}
+
+// ensureBoltBuckets creates the buckets of a new database and records its
+// format version.
+func ensureBoltBuckets(tx *bolt.Tx) error {
+	for _, name := range [][]byte{boltRecordsBucket, boltAnchorsBucket, boltTreeBucket, boltTailBucket, boltMetaBucket} {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return fmt.Errorf("create bucket %s: %w", name, err)
+		}
+	}
+	meta := tx.Bucket(boltMetaBucket)
+	if meta.Get(boltVersionKey) == nil {
+		if err := meta.Put(boltVersionKey, binary.BigEndian.AppendUint16(nil, boltFormatVersion)); err != nil {
+			return err
+		}
+	}
+	return checkBoltFormat(tx)
+}
+
+// checkBoltFormat refuses databases that are not securelog stores or were
+// written in a newer format.
+func checkBoltFormat(tx *bolt.Tx) error {
+	meta := tx.Bucket(boltMetaBucket)
+	if meta == nil {
+		return fmt.Errorf("%w: not a securelog bolt store", ErrUnsupportedFormat)
+	}
+	v := meta.Get(boltVersionKey)
+	if len(v) != 2 {
+		return fmt.Errorf("%w: invalid bolt store version", ErrUnsupportedFormat)
+	}
+	if version := binary.BigEndian.Uint16(v); version > boltFormatVersion {
+		return fmt.Errorf("%w: bolt store version %d", ErrUnsupportedFormat, version)
+	}
+	for _, name := range [][]byte{boltRecordsBucket, boltAnchorsBucket, boltTreeBucket, boltTailBucket} {
+		if tx.Bucket(name) == nil {
+			return fmt.Errorf("%w: bolt store lacks bucket %s", ErrUnsupportedFormat, name)
+		}
+	}
+	return nil
+}
+
+// boltKey returns the bucket key of index idx.
+func boltKey(idx uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, idx)
+}
+
+// lastBoltIndex returns the index of the last key in b, or 0 if it is empty.
+func lastBoltIndex(b *bolt.Bucket) uint64 {
+	k, _ := b.Cursor().Last()
+	if len(k) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(k)
+}
+
+// Close closes the database.
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+// Append stores a record, its tree entry, the anchor if not nil and the tail
+// state in one transaction.
+func (s *boltStore) Append(r Record, tail TailState, anchor *Anchor) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	codec, stored := codecRaw, r.Msg
+	if s.compress {
+		codec, stored = compressMsg(r.Msg)
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(boltRecordsBucket)
+		if last := lastBoltIndex(records); last != r.Index-1 {
+			return fmt.Errorf("non-contiguous append: have %d, got %d", last, r.Index)
+		}
+		if err := records.Put(boltKey(r.Index), encodeBoltRecord(r, codec, stored)); err != nil {
+			return fmt.Errorf("put record %d: %w", r.Index, err)
+		}
+
+		leaf := appendedLeaf(r, tail)
+		if err := tx.Bucket(boltTreeBucket).Put(boltKey(r.Index), slices.Concat(leaf[:], tail.TreeRoot[:])); err != nil {
+			return fmt.Errorf("put tree entry %d: %w", r.Index, err)
+		}
+		if anchor != nil {
+			if err := tx.Bucket(boltAnchorsBucket).Put(boltKey(anchor.Index), slices.Concat(anchor.Key[:], anchor.TagV[:], anchor.TagT[:])); err != nil {
+				return fmt.Errorf("put anchor %d: %w", anchor.Index, err)
+			}
+		}
+		return putBoltTail(tx, tail)
+	})
+	if err != nil {
+		return err
+	}
+	s.compressed.add(len(r.Msg), len(stored), codec)
+	return nil
+}
+
+// putBoltTail replaces the tail state.
+func putBoltTail(tx *bolt.Tx, tail TailState) error {
+	v := binary.BigEndian.AppendUint64(nil, tail.Index)
+	v = append(v, tail.TagV[:]...)
+	v = append(v, tail.TagT[:]...)
+	if err := tx.Bucket(boltTailBucket).Put(boltTailKey, v); err != nil {
+		return fmt.Errorf("put tail: %w", err)
+	}
+	return nil
+}
+
+// encodeBoltRecord returns the value stored for r, whose message is stored
+// as stored with codec.
+func encodeBoltRecord(r Record, codec byte, stored []byte) []byte {
+	v := make([]byte, 0, boltRecordHeader+len(stored))
+	v = binary.BigEndian.AppendUint64(v, uint64(r.TS))
+	v = append(v, codec)
+	v = append(v, r.TagV[:]...)
+	v = append(v, r.TagT[:]...)
+	return append(v, stored...)
+}
+
+// decodeBoltRecord decodes the value stored for record idx. The record does
+// not share memory with v, which is only valid inside its transaction.
+func decodeBoltRecord(idx uint64, v []byte) (Record, error) {
+	if len(v) < boltRecordHeader {
+		return Record{}, fmt.Errorf("%w: record %d is truncated", ErrStorageCorruption, idx)
+	}
+	r := Record{Index: idx, TS: int64(binary.BigEndian.Uint64(v[0:8]))}
+	copy(r.TagV[:], v[9:41])
+	copy(r.TagT[:], v[41:73])
+	msg, err := decompressMsg(v[8], v[boltRecordHeader:])
+	if err != nil {
+		return Record{}, fmt.Errorf("record %d: %w", idx, err)
+	}
+	r.Msg = slices.Clone(msg)
+	if r.Msg == nil {
+		r.Msg = []byte{}
+	}
+	return r, nil
+}
+
+// Iter returns a channel that streams records starting from startIdx in ascending order.
+func (s *boltStore) Iter(startIdx uint64) (<-chan Record, func() error, error) {
+	out, done := seqChan(s.All(startIdx))
+	return out, done, nil
+}
+
+// All yields the records with Index >= start.
+func (s *boltStore) All(start uint64) iter.Seq2[Record, error] {
+	return s.Range(start, math.MaxUint64)
+}
+
+// Range yields the records with start <= Index <= end. Records are read in
+// batches, each from its own read transaction.
+func (s *boltStore) Range(start, end uint64) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		var last uint64
+		for next := start; ; {
+			batch, more, err := s.readBatch(next, end)
+			for _, r := range batch {
+				if !yield(r, nil) {
+					return
+				}
+				last = r.Index
+			}
+			if err != nil {
+				yield(Record{}, &StoreReadError{Offset: -1, Index: last, Err: err})
+				return
+			}
+			if !more {
+				return
+			}
+			next = last + 1
+		}
+	}
+}
+
+// readBatch returns up to boltBatchSize records with from <= Index <= to, and
+// whether more may follow. It returns the records before a bad one with the
+// error.
+func (s *boltStore) readBatch(from, to uint64) ([]Record, bool, error) {
+	var batch []Record
+	more := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltRecordsBucket).Cursor()
+		for k, v := c.Seek(boltKey(from)); k != nil; k, v = c.Next() {
+			if len(k) != 8 {
+				return fmt.Errorf("%w: invalid record key %x", ErrStorageCorruption, k)
+			}
+			idx := binary.BigEndian.Uint64(k)
+			if idx > to {
+				return nil
+			}
+			if len(batch) == boltBatchSize {
+				more = true
+				return nil
+			}
+			r, err := decodeBoltRecord(idx, v)
+			if err != nil {
+				return err
+			}
+			batch = append(batch, r)
+		}
+		return nil
+	})
+	return batch, more, err
+}
+
+// IterTime yields the records with from <= TS < to in index order. bbolt
+// keeps no secondary index, so it scans the log.
+func (s *boltStore) IterTime(from, to time.Time) iter.Seq2[Record, error] {
+	lo, hi := from.UnixNano(), to.UnixNano()
+	return func(yield func(Record, error) bool) {
+		for r, err := range s.All(1) {
+			if err != nil {
+				yield(Record{}, err)
+				return
+			}
+			if r.TS >= lo && r.TS < hi && !yield(r, nil) {
+				return
+			}
+		}
+	}
+}
+
+// AnchorAt retrieves the anchor checkpoint at the specified index.
+func (s *boltStore) AnchorAt(i uint64) (Anchor, bool, error) {
+	var a Anchor
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltAnchorsBucket).Get(boltKey(i))
+		if v == nil {
+			return nil
+		}
+		var err error
+		a, err = decodeBoltAnchor(tx, i, v)
+		found = err == nil
+		return err
+	})
+	return a, found, err
+}
+
+// ListAnchors returns all stored anchor checkpoints in ascending order by index.
+func (s *boltStore) ListAnchors() ([]Anchor, error) {
+	var out []Anchor
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltAnchorsBucket).ForEach(func(k, v []byte) error {
+			if len(k) != 8 {
+				return fmt.Errorf("%w: invalid anchor key %x", ErrStorageCorruption, k)
+			}
+			a, err := decodeBoltAnchor(tx, binary.BigEndian.Uint64(k), v)
+			if err != nil {
+				return err
+			}
+			out = append(out, a)
+			return nil
+		})
+	})
+	return out, err
+}
+
+// decodeBoltAnchor decodes the anchor stored at idx, taking its tree root
+// from the tree bucket.
+func decodeBoltAnchor(tx *bolt.Tx, idx uint64, v []byte) (Anchor, error) {
+	if len(v) != boltAnchorSize {
+		return Anchor{}, fmt.Errorf("invalid anchor sizes")
+	}
+	a := Anchor{Index: idx}
+	copy(a.Key[:], v[:KeySize])
+	copy(a.TagV[:], v[KeySize:KeySize+32])
+	copy(a.TagT[:], v[KeySize+32:])
+	a.TreeRoot = boltTreeRoot(tx, idx)
+	return a, nil
+}
+
+// boltTreeRoot returns the tree root after record idx, or zero if the tree
+// bucket has none.
+func boltTreeRoot(tx *bolt.Tx, idx uint64) [32]byte {
+	_, root := boltTreeEntry(tx, idx)
+	return root
+}
+
+// boltTreeEntry returns the leaf hash of record idx and the tree root after
+// it, or zeros if the tree bucket has none.
+func boltTreeEntry(tx *bolt.Tx, idx uint64) (leaf, root [32]byte) {
+	if v := tx.Bucket(boltTreeBucket).Get(boltKey(idx)); len(v) == boltTreeSize {
+		copy(leaf[:], v[:32])
+		copy(root[:], v[32:])
+	}
+	return leaf, root
+}
+
+// Tail returns the current tail state containing the latest index and MAC tags.
+func (s *boltStore) Tail() (TailState, bool, error) {
+	var tail TailState
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltTailBucket).Get(boltTailKey)
+		if v == nil {
+			return nil
+		}
+		if len(v) != boltTailSize {
+			return fmt.Errorf("invalid tail sizes")
+		}
+		tail.Index = binary.BigEndian.Uint64(v[:8])
+		copy(tail.TagV[:], v[8:40])
+		copy(tail.TagT[:], v[40:])
+		tail.Leaf, tail.TreeRoot = boltTreeEntry(tx, tail.Index)
+		found = true
+		return nil
+	})
+	return tail, found, err
+}
+
+// LeafHashes returns the leaf hashes of records with indexes in [from, to],
+// stopping at the first gap in the tree bucket.
+func (s *boltStore) LeafHashes(from, to uint64) ([][32]byte, error) {
+	var out [][32]byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltTreeBucket).Cursor()
+		next := from
+		for k, v := c.Seek(boltKey(from)); k != nil && next <= to; k, v = c.Next() {
+			if len(k) != 8 || binary.BigEndian.Uint64(k) != next || len(v) != boltTreeSize {
+				break
+			}
+			var leaf [32]byte
+			copy(leaf[:], v[:32])
+			out = append(out, leaf)
+			next++
+		}
+		return nil
+	})
+	return out, err
+}
+
+// ReplaceMsg replaces the stored message of record idx, keeping its index,
+// timestamp and tags. It is used for redaction.
+func (s *boltStore) ReplaceMsg(idx uint64, msg []byte) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(boltRecordsBucket)
+		v := records.Get(boltKey(idx))
+		if v == nil {
+			return fmt.Errorf("record %d not found", idx)
+		}
+		r, err := decodeBoltRecord(idx, v)
+		if err != nil {
+			return err
+		}
+		codec, stored := codecRaw, msg
+		if s.compress {
+			codec, stored = compressMsg(msg)
+		}
+		return records.Put(boltKey(idx), encodeBoltRecord(r, codec, stored))
+	})
+}
+
+// Prune deletes records and anchors with index below beforeIndex. The tree
+// bucket is kept, since leaf hashes carry no payload and are needed for
+// proofs.
+func (s *boltStore) Prune(beforeIndex uint64) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if beforeIndex == 0 {
+			return nil
+		}
+		for _, name := range [][]byte{boltRecordsBucket, boltAnchorsBucket} {
+			if err := deleteBoltKeys(tx.Bucket(name), 0, beforeIndex-1); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ResetTail drops anchors and tree entries beyond tail.Index and replaces the
+// tail state. tail.Index must be the last record.
+func (s *boltStore) ResetTail(tail TailState) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if last := lastBoltIndex(tx.Bucket(boltRecordsBucket)); last != tail.Index {
+			return fmt.Errorf("tail at %d is not the last record %d", tail.Index, last)
+		}
+		for _, name := range [][]byte{boltAnchorsBucket, boltTreeBucket} {
+			if err := deleteBoltKeys(tx.Bucket(name), tail.Index+1, math.MaxUint64); err != nil {
+				return err
+			}
+		}
+		if tail.Index == 0 {
+			return tx.Bucket(boltTailBucket).Delete(boltTailKey)
+		}
+		return putBoltTail(tx, tail)
+	})
+}
+
+// deleteBoltKeys deletes the keys of b with from <= index <= to.
+func deleteBoltKeys(b *bolt.Bucket, from, to uint64) error {
+	// Deleting through a cursor while walking it skips keys, so collect
+	// them first.
+	var keys [][]byte
+	c := b.Cursor()
+	for k, _ := c.Seek(boltKey(from)); k != nil; k, _ = c.Next() {
+		if len(k) != 8 {
+			return fmt.Errorf("%w: invalid key %x", ErrStorageCorruption, k)
+		}
+		if binary.BigEndian.Uint64(k) > to {
+			break
+		}
+		keys = append(keys, slices.Clone(k))
+	}
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CompressionStats reports the messages appended since the store was opened.
+func (s *boltStore) CompressionStats() CompressionStats {
+	return s.compressed.get()
+}
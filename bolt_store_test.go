@@ -0,0 +1,234 @@
+package securelog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+func TestBoltStore_IterBatches(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-bolt-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenBoltStore(filepath.Join(tmpDir, "logs.bolt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	logger, err := New(Config{AnchorEvery: 100}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, _ := logger.GetInitialKeys()
+	n := 2*boltBatchSize + 10
+	for i := 0; i < n; i++ {
+		if _, err := logger.Append([]byte(fmt.Sprintf("entry %d", i)), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records := readAllRecords(t, store)
+	if len(records) != n {
+		t.Fatalf("Expected %d records across batches, got %d", n, len(records))
+	}
+	for i, r := range records {
+		if r.Index != uint64(i+1) || string(r.Msg) != fmt.Sprintf("entry %d", i) {
+			t.Fatalf("Record %d = {%d %q}", i+1, r.Index, r.Msg)
+		}
+	}
+	var zeroTag [32]byte
+	if _, err := VerifyFrom(records, 0, a0, zeroTag); err != nil {
+		t.Fatalf("V-chain: %v", err)
+	}
+
+	// Appending while iterating must not deadlock: no read transaction is
+	// open while a record is yielded.
+	var count int
+	for r, err := range RecordRange(store, boltBatchSize-1, boltBatchSize+1) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count == 0 {
+			if _, err := logger.Append([]byte("during iteration"), time.Now()); err != nil {
+				t.Fatalf("Append during iteration: %v", err)
+			}
+		}
+		if r.Index != uint64(boltBatchSize-1+count) {
+			t.Errorf("Range yielded record %d at position %d", r.Index, count)
+		}
+		count++
+	}
+	if count != 3 {
+		t.Errorf("RecordRange across a batch boundary yielded %d records", count)
+	}
+
+	// A damaged value ends the iteration with a read error after the last
+	// good record.
+	s := store.(*boltStore)
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltRecordsBucket).Put(boltKey(300), []byte("short"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	count = 0
+	var readErr *StoreReadError
+	for _, err := range AllRecords(store, 1) {
+		if err != nil {
+			if !errors.As(err, &readErr) {
+				t.Fatalf("Expected a *StoreReadError, got: %v", err)
+			}
+			break
+		}
+		count++
+	}
+	if count != 299 || readErr == nil || readErr.Index != 299 || !errors.Is(readErr, ErrStorageCorruption) {
+		t.Errorf("Expected 299 records and corruption after record 299, got %d and %v", count, readErr)
+	}
+}
+
+func TestBoltStore_ReadOnlyAndFormat(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-bolt-ro-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "logs.bolt")
+	if _, err := OpenBoltStoreWithOptions(path, BoltStoreOptions{ReadOnly: true}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected opening a missing store read-only to fail with ErrNotExist, got: %v", err)
+	}
+	store, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := New(Config{AnchorEvery: 2}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read-only stores share the file.
+	ro1, err := OpenBoltStoreWithOptions(path, BoltStoreOptions{ReadOnly: true, Check: CheckOnOpen})
+	if err != nil {
+		t.Fatalf("Opening read-only failed: %v", err)
+	}
+	defer ro1.Close()
+	ro2, err := OpenBoltStoreWithOptions(path, BoltStoreOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("Opening a second read-only store failed: %v", err)
+	}
+	defer ro2.Close()
+	if got := readAllRecords(t, ro2); len(got) != 4 {
+		t.Errorf("Expected 4 records, got %d", len(got))
+	}
+	if err := ro1.Append(Record{Index: 5}, TailState{Index: 5}, nil); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly from Append, got: %v", err)
+	}
+	if err := ro1.(RedactableStore).ReplaceMsg(1, nil); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly from ReplaceMsg, got: %v", err)
+	}
+	if err := ro1.(Pruner).Prune(2); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly from Prune, got: %v", err)
+	}
+	if err := ro1.(Repairer).ResetTail(TailState{Index: 4}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly from ResetTail, got: %v", err)
+	}
+
+	// Databases that are not securelog stores, or are newer, are refused.
+	other := filepath.Join(tmpDir, "other.bolt")
+	db, err := bolt.Open(other, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("unrelated"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenBoltStoreWithOptions(other, BoltStoreOptions{ReadOnly: true}); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat for a foreign database, got: %v", err)
+	}
+
+	newer := filepath.Join(tmpDir, "newer.bolt")
+	store, err = OpenBoltStore(newer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.(*boltStore).db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltMetaBucket).Put(boltVersionKey, binary.BigEndian.AppendUint16(nil, boltFormatVersion+1))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenBoltStore(newer); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat for a newer store, got: %v", err)
+	}
+}
+
+func TestBoltStore_Compression(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-bolt-compress-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenBoltStoreWithOptions(filepath.Join(tmpDir, "logs.bolt"), BoltStoreOptions{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := logger.Append(auditJSON(i), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := store.(CompressionReporter).CompressionStats()
+	if stats.Records != 4 || stats.Compressed != 4 || stats.Ratio() >= 0.9 {
+		t.Errorf("Compressed stats = %+v, ratio %.2f", stats, stats.Ratio())
+	}
+	var stored int
+	if err := store.(*boltStore).db.View(func(tx *bolt.Tx) error {
+		stored = len(tx.Bucket(boltRecordsBucket).Get(boltKey(2))) - boltRecordHeader
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if stored >= len(auditJSON(1)) {
+		t.Errorf("Record 2 stored in %d bytes, its message has %d", stored, len(auditJSON(1)))
+	}
+	for _, r := range readAllRecords(t, store) {
+		if string(r.Msg) != string(auditJSON(int(r.Index-1))) {
+			t.Errorf("Record %d reads %q", r.Index, r.Msg)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package securelog
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Store inconsistencies reported as CheckIssue kinds. ErrStorageCorruption
+// is reported as well, for records whose checksum does not match.
+var (
+	// ErrStoreUnreadable indicates a record that could not be read back.
+	ErrStoreUnreadable = errors.New("record unreadable")
+	// ErrStoreGap indicates record indexes missing from the store.
+	ErrStoreGap = errors.New("records missing")
+	// ErrStoreOrder indicates records out of order or duplicated.
+	ErrStoreOrder = errors.New("records out of order")
+	// ErrTailMismatch indicates a tail state that is missing or does not match the last record.
+	ErrTailMismatch = errors.New("tail does not match last record")
+	// ErrAnchorMismatch indicates an anchor without a record or with tags other than its record's.
+	ErrAnchorMismatch = errors.New("anchor does not match its record")
+	// ErrTreeMismatch indicates stored Merkle tree state that does not match the records.
+	ErrTreeMismatch = errors.New("tree state does not match records")
+)
+
+// Repairer is implemented by stores that RepairStore can repair.
+type Repairer interface {
+	// ResetTail drops anchors and tree state beyond tail.Index and makes tail
+	// the stored tail state. tail.Index must be the last record in the store;
+	// tail.TreeRoot and tail.Leaf are taken from the tree state.
+	ResetTail(tail TailState) error
+}
+
+// CheckMode selects whether a store is checked when it is opened.
+type CheckMode int
+
+const (
+	// CheckOff opens the store without checking it.
+	CheckOff CheckMode = iota
+	// CheckOnOpen runs CheckStore and refuses to open a store with issues.
+	CheckOnOpen
+	// RepairOnOpen runs RepairStore and refuses to open a store with issues
+	// left after repair.
+	RepairOnOpen
+)
+
+// CheckIssue is one inconsistency found by CheckStore. Kind is one of the
+// ErrStore*, ErrTailMismatch, ErrAnchorMismatch, ErrTreeMismatch or
+// ErrStorageCorruption sentinels.
+type CheckIssue struct {
+	Index      uint64 // record or anchor index concerned
+	Kind       error
+	Detail     string
+	Repairable bool // purely structural: RepairStore can fix it without losing evidence
+	Repaired   bool
+}
+
+func (i CheckIssue) String() string {
+	s := fmt.Sprintf("index %d: %v (%s)", i.Index, i.Kind, i.Detail)
+	if i.Repaired {
+		s += " [repaired]"
+	}
+	return s
+}
+
+// CheckReport is the result of CheckStore.
+type CheckReport struct {
+	First   uint64 // index of the first record (0 for an empty store)
+	Last    uint64 // index of the last record read (0 for an empty store)
+	Records uint64 // number of records read
+	Anchors int
+	Issues  []CheckIssue
+}
+
+// OK reports whether every issue found has been repaired.
+func (r CheckReport) OK() bool {
+	for _, i := range r.Issues {
+		if !i.Repaired {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckError is returned when a store opened with CheckOnOpen or
+// RepairOnOpen fails its check. errors.Is matches every issue kind it contains.
+type CheckError struct {
+	Report CheckReport
+}
+
+func (e *CheckError) Error() string {
+	var parts []string
+	for _, i := range e.Report.Issues {
+		if !i.Repaired {
+			parts = append(parts, i.String())
+		}
+	}
+	return fmt.Sprintf("store check found %d issue(s): %s", len(parts), strings.Join(parts, "; "))
+}
+
+// Unwrap exposes the issue kinds to errors.Is.
+func (e *CheckError) Unwrap() []error {
+	var kinds []error
+	for _, i := range e.Report.Issues {
+		if !i.Repaired {
+			kinds = append(kinds, i.Kind)
+		}
+	}
+	return kinds
+}
+
+// CheckStore reads the whole store and reports inconsistencies between its
+// records, anchors, tail and tree state: unreadable or corrupt records, gaps
+// and reordering, a tail that does not match the last record, anchors
+// without a matching record and Merkle roots or leaf hashes that do not
+// match the records. It needs no keys, so it cannot tell whether the tags
+// themselves are authentic; that is what the verifiers are for. Nor does it
+// know whether the log is redactable, so tree state matching the records in
+// either mode (see LeafHash) is accepted.
+func CheckStore(st Store) (CheckReport, error) {
+	var rep CheckReport
+	anchors, err := st.ListAnchors()
+	if err != nil {
+		return rep, err
+	}
+	sort.Slice(anchors, func(i, j int) bool { return anchors[i].Index < anchors[j].Index })
+	rep.Anchors = len(anchors)
+	tail, hasTail, err := st.Tail()
+	if err != nil {
+		return rep, err
+	}
+	issue := func(idx uint64, kind error, format string, args ...any) {
+		rep.Issues = append(rep.Issues, CheckIssue{Index: idx, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	treeStore, hasTree := st.(TreeStore)
+	// Trees over the records read as a log that is not redactable ([0]) and
+	// as a redactable one ([1]); they only differ for envelopes.
+	var trees [2]merkleTree
+	var leaves [2][][32]byte
+	var last Record
+	ordered, readable := true, true
+	a := 0
+	for r, err := range AllRecords(st, 1) {
+		if err != nil {
+			kind := ErrStoreUnreadable
+			if errors.Is(err, ErrStorageCorruption) {
+				kind = ErrStorageCorruption
+			}
+			issue(rep.Last+1, kind, "%v", err)
+			readable = false
+			break
+		}
+		switch {
+		case rep.Records == 0:
+			rep.First = r.Index
+		case r.Index <= rep.Last:
+			issue(r.Index, ErrStoreOrder, "after record %d", rep.Last)
+			ordered = false
+		case r.Index != rep.Last+1:
+			issue(rep.Last+1, ErrStoreGap, "records %d..%d", rep.Last+1, r.Index-1)
+			ordered = false
+		}
+		rep.Records++
+		rep.Last, last = r.Index, r
+
+		for m := range trees {
+			leaf := LeafHash(r, m == 1)
+			if hasTree {
+				leaves[m] = append(leaves[m], leaf)
+			}
+			trees[m].append(leaf)
+		}
+		for ; a < len(anchors) && anchors[a].Index <= r.Index; a++ {
+			an := anchors[a]
+			switch {
+			case an.Index < r.Index:
+				issue(an.Index, ErrAnchorMismatch, "no record %d", an.Index)
+			case an.TagV != r.TagV || an.TagT != r.TagT:
+				issue(an.Index, ErrAnchorMismatch, "tags differ from record %d", an.Index)
+			case rep.First == 1 && ordered && !isZero32(an.TreeRoot) && !matchesTree(an.TreeRoot, trees):
+				issue(an.Index, ErrTreeMismatch, "anchor tree root differs from records 1..%d", an.Index)
+			}
+		}
+	}
+
+	// A crash can leave the tail behind the log and anchors beyond it; a tail
+	// ahead of the log or tags that differ are kept as possible evidence, and
+	// so are anchors beyond the log next to such a tail.
+	tailAhead := hasTail && tail.Index > rep.Last
+	tailKept := tailAhead
+	switch {
+	case !hasTail && rep.Records > 0:
+		issue(rep.Last, ErrTailMismatch, "no tail state")
+		rep.Issues[len(rep.Issues)-1].Repairable = readable
+	case !hasTail:
+	case tailAhead && readable:
+		issue(tail.Index, ErrTailMismatch, "tail at %d beyond last record %d", tail.Index, rep.Last)
+	case tail.Index < rep.Last:
+		issue(tail.Index, ErrTailMismatch, "tail at %d behind last record %d", tail.Index, rep.Last)
+		rep.Issues[len(rep.Issues)-1].Repairable = readable
+	case tail.Index == rep.Last && (tail.TagV != last.TagV || tail.TagT != last.TagT):
+		issue(tail.Index, ErrTailMismatch, "tags differ from record %d", tail.Index)
+		tailKept = true
+	case tail.Index == rep.Last && rep.First == 1 && ordered && !isZero32(tail.TreeRoot) && !matchesTree(tail.TreeRoot, trees):
+		issue(tail.Index, ErrTreeMismatch, "tail tree root differs from records 1..%d", tail.Index)
+	}
+	if readable {
+		for ; a < len(anchors); a++ {
+			issue(anchors[a].Index, ErrAnchorMismatch, "no record %d", anchors[a].Index)
+			rep.Issues[len(rep.Issues)-1].Repairable = !tailKept && anchors[a].Index > rep.Last
+		}
+	}
+
+	if hasTree && ordered && rep.Records > 0 {
+		stored, err := treeStore.LeafHashes(rep.First, rep.Last)
+		if err != nil {
+			return rep, err
+		}
+		for i, h := range stored {
+			if h != leaves[0][i] && h != leaves[1][i] {
+				issue(rep.First+uint64(i), ErrTreeMismatch, "leaf hash differs from record %d", rep.First+uint64(i))
+				break
+			}
+		}
+	}
+	return rep, nil
+}
+
+// matchesTree reports whether root is the root of either tree.
+func matchesTree(root [32]byte, trees [2]merkleTree) bool {
+	return root == trees[0].root() || root == trees[1].root()
+}
+
+// RepairStore runs CheckStore and fixes the repairable issues, which a crash
+// can leave behind: a missing tail or one behind the last record, and anchors
+// beyond the last record. Anything that may be evidence of tampering is only
+// reported. The store must implement Repairer to be repaired.
+func RepairStore(st Store) (CheckReport, error) {
+	rep, err := CheckStore(st)
+	if err != nil {
+		return rep, err
+	}
+	repairable := false
+	for _, i := range rep.Issues {
+		repairable = repairable || i.Repairable
+	}
+	if !repairable {
+		return rep, nil
+	}
+	repairer, ok := st.(Repairer)
+	if !ok {
+		return rep, nil
+	}
+
+	var tail TailState
+	if rep.Records > 0 {
+		last, err := recordAt(st, rep.Last)
+		if err != nil {
+			return rep, err
+		}
+		tail = TailState{Index: last.Index, TagV: last.TagV, TagT: last.TagT}
+	}
+	if err := repairer.ResetTail(tail); err != nil {
+		return rep, fmt.Errorf("repair store: %w", err)
+	}
+	for i := range rep.Issues {
+		rep.Issues[i].Repaired = rep.Issues[i].Repairable
+	}
+	return rep, nil
+}
+
+// recordAt reads record idx from st.
+func recordAt(st Store, idx uint64) (Record, error) {
+	for r, err := range RecordRange(st, idx, idx) {
+		return r, err
+	}
+	return Record{}, fmt.Errorf("record %d not found", idx)
+}
+
+// checkOnOpen applies mode to a freshly opened store.
+func checkOnOpen(st Store, mode CheckMode) error {
+	var rep CheckReport
+	var err error
+	switch mode {
+	case CheckOff:
+		return nil
+	case CheckOnOpen:
+		rep, err = CheckStore(st)
+	case RepairOnOpen:
+		rep, err = RepairStore(st)
+	default:
+		return fmt.Errorf("unknown check mode %d", mode)
+	}
+	if err != nil {
+		return err
+	}
+	if !rep.OK() {
+		return &CheckError{Report: rep}
+	}
+	return nil
+}
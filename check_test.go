@@ -0,0 +1,327 @@
+package securelog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+// damageStore writes inconsistent tail and anchor state behind the back of a
+// store's Append.
+type damageStore struct {
+	setTail   func(TailState)
+	addAnchor func(Anchor)
+}
+
+func fileDamage(t *testing.T, store Store) damageStore {
+	s := store.(*fileStore)
+	return damageStore{
+		setTail: func(tail TailState) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if err := s.writeTailLocked(tail); err != nil {
+				t.Fatal(err)
+			}
+		},
+		addAnchor: func(a Anchor) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if err := s.writeAnchorLocked(a); err != nil {
+				t.Fatal(err)
+			}
+		},
+	}
+}
+
+func sqliteDamage(t *testing.T, store Store) damageStore {
+	s := store.(*sqliteStore)
+	return damageStore{
+		setTail: func(tail TailState) {
+			if _, err := s.db.Exec(`UPDATE tail SET idx=?, tagV=?, tagT=? WHERE log_id=?`,
+				tail.Index, tail.TagV[:], tail.TagT[:], s.logID); err != nil {
+				t.Fatal(err)
+			}
+		},
+		addAnchor: func(a Anchor) {
+			if _, err := s.db.Exec(`INSERT INTO anchors(log_id, idx, key, tagV, tagT) VALUES(?, ?, ?, ?, ?)`,
+				s.logID, a.Index, a.Key[:], a.TagV[:], a.TagT[:]); err != nil {
+				t.Fatal(err)
+			}
+		},
+	}
+}
+
+func boltDamage(t *testing.T, store Store) damageStore {
+	s := store.(*boltStore)
+	return damageStore{
+		setTail: func(tail TailState) {
+			if err := s.db.Update(func(tx *bolt.Tx) error { return putBoltTail(tx, tail) }); err != nil {
+				t.Fatal(err)
+			}
+		},
+		addAnchor: func(a Anchor) {
+			if err := s.db.Update(func(tx *bolt.Tx) error {
+				return tx.Bucket(boltAnchorsBucket).Put(boltKey(a.Index), slices.Concat(a.Key[:], a.TagV[:], a.TagT[:]))
+			}); err != nil {
+				t.Fatal(err)
+			}
+		},
+	}
+}
+
+func memoryDamage(t *testing.T, store Store) damageStore {
+	s := store.(*MemoryStore)
+	return damageStore{
+		setTail: func(tail TailState) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.tail = tail
+		},
+		addAnchor: func(a Anchor) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.anchors = append(s.anchors, a)
+		},
+	}
+}
+
+func closeStore(store Store) {
+	switch s := store.(type) {
+	case *fileStore:
+		_ = s.Close()
+	case *sqliteStore:
+		_ = s.db.Close()
+	case *boltStore:
+		_ = s.Close()
+	}
+}
+
+func hasIssue(rep CheckReport, kind error, idx uint64) (CheckIssue, bool) {
+	for _, i := range rep.Issues {
+		if errors.Is(i.Kind, kind) && i.Index == idx {
+			return i, true
+		}
+	}
+	return CheckIssue{}, false
+}
+
+func TestCheckStore_AllStores(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-check-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	backends := []struct {
+		name   string
+		open   func() (Store, error)
+		damage func(*testing.T, Store) damageStore
+	}{
+		{"file", func() (Store, error) { return OpenFileStore(filepath.Join(tmpDir, "file")) }, fileDamage},
+		{"sqlite", func() (Store, error) { return OpenSQLiteStore(filepath.Join(tmpDir, "check.db")) }, sqliteDamage},
+		{"bolt", func() (Store, error) { return OpenBoltStore(filepath.Join(tmpDir, "check.bolt")) }, boltDamage},
+		{"memory", func() (Store, error) { return NewMemoryStore(), nil }, memoryDamage},
+	}
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			store, err := b.open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closeStore(store)
+			logger, err := New(Config{AnchorEvery: 3}, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a0, _ := logger.GetInitialKeys()
+
+			rep, err := CheckStore(store)
+			if err != nil || !rep.OK() || rep.Records != 0 {
+				t.Fatalf("CheckStore of an empty store = %+v, %v", rep, err)
+			}
+			var stale TailState
+			for i := 0; i < 5; i++ {
+				if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+					t.Fatal(err)
+				}
+				if i == 3 {
+					stale, _, _ = store.Tail()
+				}
+			}
+			rep, err = CheckStore(store)
+			if err != nil || !rep.OK() || rep.First != 1 || rep.Last != 5 || rep.Records != 5 || rep.Anchors != 1 {
+				t.Fatalf("CheckStore of a clean store = %+v, %v", rep, err)
+			}
+
+			// A stale tail and a dangling anchor are crash artifacts.
+			d := b.damage(t, store)
+			d.setTail(stale)
+			d.addAnchor(Anchor{Index: 6, TagV: [32]byte{1}})
+			rep, err = CheckStore(store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []struct {
+				kind error
+				idx  uint64
+			}{{ErrTailMismatch, 4}, {ErrAnchorMismatch, 6}} {
+				if i, ok := hasIssue(rep, want.kind, want.idx); !ok || !i.Repairable || i.Repaired {
+					t.Errorf("Expected a repairable %v at %d, got %v", want.kind, want.idx, rep.Issues)
+				}
+			}
+			if rep.OK() {
+				t.Error("Expected a damaged store to fail the check")
+			}
+			rep, err = RepairStore(store)
+			if err != nil || !rep.OK() || len(rep.Issues) != 2 {
+				t.Fatalf("RepairStore = %+v, %v", rep, err)
+			}
+			if rep, err := CheckStore(store); err != nil || len(rep.Issues) != 0 {
+				t.Fatalf("CheckStore after repair = %+v, %v", rep, err)
+			}
+			if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+				t.Fatalf("Append after repair: %v", err)
+			}
+			var zeroTag [32]byte
+			if _, err := VerifyFrom(readAllRecords(t, store), 0, a0, zeroTag); err != nil {
+				t.Fatalf("V-chain after repair: %v", err)
+			}
+			if err := NewSemiTrustedVerifier(store).VerifyFromAnchor(mustAnchor(t, store, 3)); err != nil {
+				t.Fatalf("VerifyFromAnchor after repair: %v", err)
+			}
+
+			// Tags that differ may be evidence and are left alone.
+			forged := Anchor{Index: 9, TagV: [32]byte{2}}
+			d.addAnchor(forged)
+			tail, _, _ := store.Tail()
+			forgedTail := tail
+			forgedTail.TagV[0] ^= 1
+			d.setTail(forgedTail)
+			rep, err = RepairStore(store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i, ok := hasIssue(rep, ErrTailMismatch, 6); !ok || i.Repairable || i.Repaired {
+				t.Errorf("Expected an unrepairable tail mismatch, got %v", rep.Issues)
+			}
+			if i, ok := hasIssue(rep, ErrAnchorMismatch, 9); !ok || i.Repairable || i.Repaired {
+				t.Errorf("Expected a dangling anchor left alone beside a suspect tail, got %v", rep.Issues)
+			}
+			if got, _, _ := store.Tail(); got != forgedTail {
+				t.Error("RepairStore rewrote a tail that may be evidence")
+			}
+			if _, ok, _ := store.AnchorAt(9); !ok {
+				t.Error("RepairStore dropped an anchor while the tail was unrepairable")
+			}
+		})
+	}
+}
+
+func TestCheckStore_OnOpen(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-check-open-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	t.Run("sqlite", func(t *testing.T) {
+		dbPath := filepath.Join(tmpDir, "open.db")
+		store, err := OpenSQLiteStore(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger, err := New(Config{AnchorEvery: 3}, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5; i++ {
+			if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, _, _ := store.Tail()
+		stale := want
+		stale.Index = 2
+		sqliteDamage(t, store).setTail(stale)
+		closeStore(store)
+
+		_, err = OpenSQLiteStoreWithOptions(dbPath, SQLiteStoreOptions{Check: CheckOnOpen})
+		var checkErr *CheckError
+		if !errors.As(err, &checkErr) || !errors.Is(err, ErrTailMismatch) {
+			t.Fatalf("Expected a CheckError for a stale tail, got: %v", err)
+		}
+		store, err = OpenSQLiteStoreWithOptions(dbPath, SQLiteStoreOptions{Check: RepairOnOpen})
+		if err != nil {
+			t.Fatalf("Opening with RepairOnOpen failed: %v", err)
+		}
+		defer closeStore(store)
+		if got, _, _ := store.Tail(); got != want {
+			t.Errorf("Tail after repair = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		dir := filepath.Join(tmpDir, "file")
+		store, err := OpenFileStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger, err := New(Config{AnchorEvery: 3}, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5; i++ {
+			if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := store.(*fileStore).Close(); err != nil {
+			t.Fatal(err)
+		}
+		if store, err = OpenFileStoreWithOptions(dir, FileStoreOptions{Check: CheckOnOpen}); err != nil {
+			t.Fatalf("Opening a clean store with CheckOnOpen failed: %v", err)
+		}
+		if err := store.(*fileStore).Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Flip a byte in the message of record 2.
+		logPath := filepath.Join(dir, logsFileName)
+		data, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entrySize := headerSize + codecSize + len("entry") + tagsSize + checksumSize
+		data[fileHeaderSize+entrySize+headerSize] ^= 0x01
+		if err := os.WriteFile(logPath, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		for _, mode := range []CheckMode{CheckOnOpen, RepairOnOpen} {
+			_, err := OpenFileStoreWithOptions(dir, FileStoreOptions{Check: mode})
+			if !errors.Is(err, ErrStorageCorruption) {
+				t.Errorf("Expected ErrStorageCorruption with mode %d, got: %v", mode, err)
+			}
+		}
+		store, err = OpenFileStore(dir)
+		if err != nil {
+			t.Fatalf("Opening without a check failed: %v", err)
+		}
+		defer store.(*fileStore).Close()
+		rep, err := CheckStore(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i, ok := hasIssue(rep, ErrStorageCorruption, 2); !ok || i.Repairable {
+			t.Errorf("Expected unrepairable corruption at record 2, got %v", rep.Issues)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package securelog
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// Stores can compress messages below the MAC layer: the tags, leaf hashes and
+// every record handed out cover the original message, and only the stored
+// bytes change. Each stored message is tagged with a codec, so compressed and
+// uncompressed records mix freely and enabling or disabling compression needs
+// no rewrite.
+const (
+	codecRaw   byte = 0 // message stored as is
+	codecFlate byte = 1 // message length (uint32) then a raw DEFLATE stream (compress/flate)
+
+	// compressMinSize is the size below which messages are not worth
+	// compressing.
+	compressMinSize = 64
+	// flateMaxRatio bounds how far DEFLATE can expand its input: a stream
+	// declaring a longer message than that is corrupt.
+	flateMaxRatio = 1032
+)
+
+// compressMsg returns the codec and stored bytes for msg, compressing it if
+// that makes it smaller.
+func compressMsg(msg []byte) (byte, []byte) {
+	if len(msg) < compressMinSize || int64(len(msg)) > math.MaxUint32 {
+		return codecRaw, msg
+	}
+	var buf bytes.Buffer
+	buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(msg))))
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return codecRaw, msg
+	}
+	if _, err := w.Write(msg); err != nil || w.Close() != nil || buf.Len() >= len(msg) {
+		return codecRaw, msg
+	}
+	return codecFlate, buf.Bytes()
+}
+
+// decompressMsg returns the message stored as data with codec.
+func decompressMsg(codec byte, data []byte) ([]byte, error) {
+	switch codec {
+	case codecRaw:
+		return data, nil
+	case codecFlate:
+		if len(data) < 4 {
+			return nil, fmt.Errorf("%w: compressed message too short", ErrStorageCorruption)
+		}
+		// Inflate no further than the recorded length, which itself cannot
+		// exceed what DEFLATE can produce from the stream, so a crafted
+		// stream cannot expand without bound.
+		size := int64(binary.BigEndian.Uint32(data))
+		stream := data[4:]
+		if size > flateMaxRatio*int64(len(stream)) {
+			return nil, fmt.Errorf("%w: compressed message claims %d bytes from %d", ErrStorageCorruption, size, len(stream))
+		}
+		r := flate.NewReader(bytes.NewReader(stream))
+		defer r.Close()
+		msg, err := io.ReadAll(io.LimitReader(r, size+1))
+		if err != nil {
+			return nil, fmt.Errorf("%w: inflate message: %v", ErrStorageCorruption, err)
+		}
+		if int64(len(msg)) != size {
+			return nil, fmt.Errorf("%w: message inflates to %d bytes, recorded %d", ErrStorageCorruption, len(msg), size)
+		}
+		return msg, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown message codec %d", ErrStorageCorruption, codec)
+	}
+}
+
+// CompressionStats counts the messages a store has written since it was
+// opened, before and after compression.
+type CompressionStats struct {
+	Records     uint64 // messages written
+	Compressed  uint64 // messages stored compressed
+	RawBytes    uint64 // size of the messages
+	StoredBytes uint64 // size of the messages as stored
+}
+
+// Ratio returns StoredBytes / RawBytes, or 1 if nothing has been written.
+func (s CompressionStats) Ratio() float64 {
+	if s.RawBytes == 0 {
+		return 1
+	}
+	return float64(s.StoredBytes) / float64(s.RawBytes)
+}
+
+// CompressionReporter is implemented by stores that can compress messages.
+type CompressionReporter interface {
+	CompressionStats() CompressionStats
+}
+
+// compressionCounter accumulates CompressionStats; it is safe for concurrent
+// use.
+type compressionCounter struct {
+	mu    sync.Mutex
+	stats CompressionStats
+}
+
+func (c *compressionCounter) add(raw, stored int, codec byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Records++
+	if codec != codecRaw {
+		c.stats.Compressed++
+	}
+	c.stats.RawBytes += uint64(raw)
+	c.stats.StoredBytes += uint64(stored)
+}
+
+func (c *compressionCounter) get() CompressionStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
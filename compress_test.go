@@ -0,0 +1,461 @@
+package securelog
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+// auditJSON returns a verbose, compressible message like the audit entries
+// compression is meant for.
+func auditJSON(i int) []byte {
+	return []byte(fmt.Sprintf(`{"event":"file.access","user":"alice","path":"/srv/data/report-%d.csv",`+
+		`"action":"read","result":"allowed","client":{"ip":"10.0.0.7","agent":"audit-agent/1.0"}}`, i))
+}
+
+func TestCompressMsg(t *testing.T) {
+	for _, msg := range [][]byte{
+		nil,
+		[]byte("short"),
+		auditJSON(1),
+		bytes.Repeat([]byte("a"), 10000),
+	} {
+		codec, data := compressMsg(msg)
+		if codec == codecFlate && len(data) >= len(msg) {
+			t.Errorf("Compressed %d bytes to %d", len(msg), len(data))
+		}
+		got, err := decompressMsg(codec, data)
+		if err != nil || !bytes.Equal(got, msg) {
+			t.Errorf("Round trip of %d bytes = %d bytes, %v", len(msg), len(got), err)
+		}
+	}
+	if codec, _ := compressMsg(auditJSON(1)); codec != codecFlate {
+		t.Error("Expected a JSON audit entry to be compressed")
+	}
+	if _, err := decompressMsg(codecFlate, []byte("not deflate")); !errors.Is(err, ErrStorageCorruption) {
+		t.Errorf("Expected ErrStorageCorruption for a broken stream, got: %v", err)
+	}
+	if _, err := decompressMsg(7, nil); !errors.Is(err, ErrStorageCorruption) {
+		t.Errorf("Expected ErrStorageCorruption for an unknown codec, got: %v", err)
+	}
+}
+
+func TestDecompressMsg_Bomb(t *testing.T) {
+	// 16 MiB of zeros compresses to a few KiB.
+	var stream bytes.Buffer
+	w, err := flate.NewWriter(&stream, flate.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(make([]byte, 16<<20)); err != nil || w.Close() != nil {
+		t.Fatal("compress zeros")
+	}
+	withSize := func(size uint32) []byte {
+		return append(binary.BigEndian.AppendUint32(nil, size), stream.Bytes()...)
+	}
+
+	// The stream inflates past the recorded length.
+	if _, err := decompressMsg(codecFlate, withSize(100)); !errors.Is(err, ErrStorageCorruption) {
+		t.Errorf("Expected ErrStorageCorruption for a stream longer than recorded, got: %v", err)
+	}
+	// The recorded length is more than DEFLATE can produce from the stream.
+	if _, err := decompressMsg(codecFlate, withSize(math.MaxUint32)); !errors.Is(err, ErrStorageCorruption) {
+		t.Errorf("Expected ErrStorageCorruption for an impossible length, got: %v", err)
+	}
+	if _, err := decompressMsg(codecFlate, []byte{0, 1}); !errors.Is(err, ErrStorageCorruption) {
+		t.Errorf("Expected ErrStorageCorruption for a truncated header, got: %v", err)
+	}
+	if got, err := decompressMsg(codecFlate, withSize(16<<20)); err != nil || len(got) != 16<<20 {
+		t.Errorf("Expected the recorded length to inflate, got %d bytes, %v", len(got), err)
+	}
+}
+
+func TestCompress_FileStore(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-compress-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Records written without compression stay readable once it is enabled.
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := New(Config{AnchorEvery: 5, Redactable: true}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, _ := logger.GetInitialKeys()
+	var msgs [][]byte
+	for i := 0; i < 5; i++ {
+		msgs = append(msgs, auditJSON(i))
+		if _, err := logger.Append(msgs[i], time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := store.(CompressionReporter).CompressionStats(); stats.Records != 5 || stats.Compressed != 0 || stats.Ratio() != 1 {
+		t.Errorf("Uncompressed stats = %+v", stats)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	plainSize := fileSize(t, filepath.Join(tmpDir, logsFileName))
+
+	store, err = OpenFileStoreWithOptions(tmpDir, FileStoreOptions{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	logger.store = store
+	for i := 5; i < 10; i++ {
+		msgs = append(msgs, auditJSON(i))
+		if _, err := logger.Append(msgs[i], time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The random salt of each redactable envelope does not compress.
+	stats := store.(CompressionReporter).CompressionStats()
+	if stats.Records != 5 || stats.Compressed != 5 || stats.Ratio() >= 1 {
+		t.Errorf("Compressed stats = %+v, ratio %.2f", stats, stats.Ratio())
+	}
+	if grown := fileSize(t, filepath.Join(tmpDir, logsFileName)) - plainSize; grown >= plainSize-fileHeaderSize {
+		t.Errorf("Five compressed records took %d bytes, five plain ones %d", grown, plainSize-fileHeaderSize)
+	}
+
+	// Reads, tags and leaf hashes cover the original messages.
+	records := readAllRecords(t, store)
+	for i, r := range records {
+		if payload, _ := r.Payload(); !bytes.Equal(payload, msgs[i]) {
+			t.Errorf("Record %d reads %q, want %q", r.Index, payload, msgs[i])
+		}
+	}
+	var zeroTag [32]byte
+	redactable := VerifyOptions{Redactable: true}
+	if _, err := VerifyChainContext(context.Background(), records, 0, a0, zeroTag, true, redactable); err != nil {
+		t.Fatalf("V-chain over compressed records: %v", err)
+	}
+	if err := NewSemiTrustedVerifier(store).VerifyFromAnchorContext(context.Background(), mustAnchor(t, store, 5), redactable); err != nil {
+		t.Fatalf("VerifyFromAnchor over compressed records: %v", err)
+	}
+	if rep, err := CheckStore(store); err != nil || !rep.OK() {
+		t.Fatalf("CheckStore = %+v, %v", rep, err)
+	}
+
+	// Redaction rewrites a compressed record in place.
+	if redactable.RedactionKey, err = redactForTest(t, store, 7); err != nil {
+		t.Fatal(err)
+	}
+	records = readAllRecords(t, store)
+	if _, ok := records[6].Payload(); ok {
+		t.Error("Expected record 7 to be redacted")
+	}
+	if payload, _ := records[7].Payload(); !bytes.Equal(payload, msgs[7]) {
+		t.Errorf("Record 8 after redaction reads %q", payload)
+	}
+	if _, err := VerifyChainContext(context.Background(), records, 0, a0, zeroTag, true, redactable); err != nil {
+		t.Fatalf("V-chain after redaction: %v", err)
+	}
+
+	// A store in an older format refuses compression until it is migrated.
+	legacyDir := filepath.Join(tmpDir, "legacy")
+	legacy, err := OpenFileStore(legacyDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyLogger, err := New(Config{}, legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := legacyLogger.Append(auditJSON(i), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	segs, err := legacy.(SegmentedStore).Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.Close(); err != nil {
+		t.Fatal(err)
+	}
+	downgradeToV0(t, legacyDir, segs)
+	if _, err := OpenFileStoreWithOptions(legacyDir, FileStoreOptions{Compress: true}); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("Expected ErrUnsupportedFormat for compression of a version 0 store, got: %v", err)
+	}
+	if err := MigrateFileStore(legacyDir, ""); err != nil {
+		t.Fatal(err)
+	}
+	legacy, err = OpenFileStoreWithOptions(legacyDir, FileStoreOptions{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer legacy.Close()
+	if got := readAllRecords(t, legacy); len(got) != 3 || !bytes.Equal(got[2].Msg, auditJSON(2)) {
+		t.Errorf("Migrated store reads %d records", len(got))
+	}
+}
+
+func TestCompress_FileStoreCorruptCodec(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-compress-corrupt-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Rewrite the codec of record 2 with a valid checksum: the checksum
+	// cannot catch it, but the read must still fail rather than yield bytes.
+	recordSize := entrySize(codecSize+len("entry"), checksumSize)
+	f, err := os.OpenFile(filepath.Join(tmpDir, logsFileName), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := make([]byte, recordSize)
+	if _, err := f.ReadAt(entry, fileHeaderSize+recordSize); err != nil {
+		t.Fatal(err)
+	}
+	r, _ := readRecord(bytes.NewReader(entry), checksumSize, int64(len(entry)))
+	r.Msg[0] = 9
+	if _, err := f.WriteAt(encodeRecord(r, checksumSize), fileHeaderSize+recordSize); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	var count int
+	var readErr *StoreReadError
+	for _, err := range RecordRange(store, 1, 3) {
+		if err != nil {
+			if !errors.As(err, &readErr) {
+				t.Fatalf("Expected a *StoreReadError, got: %v", err)
+			}
+			break
+		}
+		count++
+	}
+	if count != 1 || readErr == nil || readErr.Offset != fileHeaderSize+recordSize || readErr.Index != 1 ||
+		!errors.Is(readErr, ErrStorageCorruption) || !strings.Contains(readErr.Error(), "codec") {
+		t.Errorf("Expected 1 record and a codec error, got %d and %v", count, readErr)
+	}
+}
+
+func TestCompress_SQLiteStore(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-sqlite-compress-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	dbPath := filepath.Join(tmpDir, "logs.db")
+
+	// A version 2 database, whose logs table has no codec column, is
+	// upgraded on open and its messages read as stored.
+	store, err := OpenSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := New(Config{AnchorEvery: 4}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, _ := logger.GetInitialKeys()
+	var msgs [][]byte
+	for i := 0; i < 4; i++ {
+		msgs = append(msgs, auditJSON(i))
+		if _, err := logger.Append(msgs[i], time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db := store.(*sqliteStore).db
+	for _, stmt := range []string{`ALTER TABLE logs DROP COLUMN codec`, `PRAGMA user_version=2`} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenSQLiteDBReadOnly(dbPath); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat opening a version 2 database read-only, got: %v", err)
+	}
+
+	store, err = OpenSQLiteStoreWithOptions(dbPath, SQLiteStoreOptions{Compress: true, Search: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	logger.store = store
+	for i := 4; i < 8; i++ {
+		msgs = append(msgs, auditJSON(i))
+		if _, err := logger.Append(msgs[i], time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := store.(CompressionReporter).CompressionStats()
+	if stats.Records != 4 || stats.Compressed != 4 || stats.Ratio() >= 0.9 {
+		t.Errorf("Compressed stats = %+v, ratio %.2f", stats, stats.Ratio())
+	}
+	var stored int
+	if err := store.(*sqliteStore).db.QueryRow(`SELECT length(msg) FROM logs WHERE idx=8`).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored >= len(msgs[7]) {
+		t.Errorf("Record 8 stored in %d bytes, its message has %d", stored, len(msgs[7]))
+	}
+
+	records := readAllRecords(t, store)
+	for i, r := range records {
+		if !bytes.Equal(r.Msg, msgs[i]) {
+			t.Errorf("Record %d reads %q, want %q", r.Index, r.Msg, msgs[i])
+		}
+	}
+	var zeroTag [32]byte
+	if _, err := VerifyFrom(records, 0, a0, zeroTag); err != nil {
+		t.Fatalf("V-chain over compressed records: %v", err)
+	}
+	if err := NewSemiTrustedVerifier(store).VerifyFromAnchor(mustAnchor(t, store, 4)); err != nil {
+		t.Fatalf("VerifyFromAnchor over compressed records: %v", err)
+	}
+
+	// The search index holds the original text of compressed records.
+	var hits []uint64
+	for r, err := range store.(SearchStore).Search(`"report-6.csv"`) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(r.Msg, msgs[6]) {
+			t.Errorf("Search hit reads %q", r.Msg)
+		}
+		hits = append(hits, r.Index)
+	}
+	if len(hits) != 1 || hits[0] != 7 {
+		t.Errorf("Search yielded %v, want [7]", hits)
+	}
+	var n int
+	for r, err := range RecordsInTime(store, time.Unix(0, records[5].TS), time.Unix(0, records[7].TS+1)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(r.Msg, msgs[r.Index-1]) {
+			t.Errorf("Time query reads %q for record %d", r.Msg, r.Index)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("Time query yielded %d records, want 3", n)
+	}
+
+	if _, err := store.(*sqliteStore).db.Exec(`UPDATE logs SET codec=9 WHERE idx=6`); err != nil {
+		t.Fatal(err)
+	}
+	var readErr *StoreReadError
+	for _, err := range RecordRange(store, 1, 8) {
+		if err != nil {
+			if !errors.As(err, &readErr) || !errors.Is(err, ErrStorageCorruption) || readErr.Index != 5 {
+				t.Errorf("Expected a corruption error after record 5, got: %v", err)
+			}
+			break
+		}
+	}
+	if readErr == nil {
+		t.Error("Expected an unknown codec to fail the read")
+	}
+}
+
+func TestCompress_SQLiteLogStore(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-compress-logs-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	db, err := OpenSQLiteDB(filepath.Join(tmpDir, "logs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Two logs share the database, only one of them compressed.
+	stores := map[string]Store{}
+	for id, compress := range map[string]bool{"packed": true, "plain": false} {
+		store, err := OpenSQLiteLogStoreWithOptions(db, id, SQLiteLogStoreOptions{Compress: compress})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stores[id] = store
+		logger, err := New(Config{}, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a0, _ := logger.GetInitialKeys()
+		for i := 0; i < 4; i++ {
+			if _, err := logger.Append(auditJSON(i), time.Now()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		stats := store.(CompressionReporter).CompressionStats()
+		if compress != (stats.Compressed == 4) {
+			t.Errorf("%s: compressed stats = %+v", id, stats)
+		}
+		var zeroTag [32]byte
+		if _, err := VerifyFrom(readAllRecords(t, store), 0, a0, zeroTag); err != nil {
+			t.Fatalf("%s: V-chain: %v", id, err)
+		}
+	}
+
+	var packed, plain int
+	for id, n := range map[string]*int{"packed": &packed, "plain": &plain} {
+		if err := db.QueryRow(`SELECT SUM(length(msg)) FROM logs WHERE log_id=?`, id).Scan(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if packed >= plain {
+		t.Errorf("Compressed log stores %d bytes, the plain one %d", packed, plain)
+	}
+
+	// A store opened without compression reads the compressed log.
+	reader, err := OpenSQLiteLogStore(db, "packed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range readAllRecords(t, reader) {
+		if !bytes.Equal(r.Msg, auditJSON(i)) {
+			t.Errorf("Record %d reads %q", r.Index, r.Msg)
+		}
+	}
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
@@ -0,0 +1,164 @@
+package securelog
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Encrypted logs (Config.Encrypt) store each message as
+//
+//	[5]byte:  envelopeMagic
+//	[1]byte:  envelopeEncrypted
+//	[12]byte: nonce
+//	[n]byte:  AES-256-GCM ciphertext and tag
+//
+// under K_i = HMAC(E_i, encKeyDomain), where E_0 is committed to T and
+// E_i = H(E_{i-1}) evolves with every record, as A_i and B_i do. The nonce is
+// random: a failed store append is retried under the same E_i, so the key
+// alone does not rule out reuse. The index and timestamp are the associated
+// data. The MACs cover the ciphertext, so verifiers need no
+// decryption key. On redactable logs the ciphertext is the payload of the
+// content envelope. Protocol records (START, CLOSE, prune events) stay in the
+// clear so T can check them.
+const (
+	envelopeEncrypted = 0x03
+	encKeyDomain      = "securelog encrypt v1\x00"
+	encNonceSize      = 12
+	encOverhead       = encNonceSize + 16 // nonce and GCM tag
+)
+
+var (
+	// ErrNotEncrypted indicates a log was not written with Config.Encrypt.
+	ErrNotEncrypted = errors.New("log is not encrypted")
+	// ErrDecrypt indicates a message that cannot be decrypted with the key given.
+	ErrDecrypt = errors.New("message decryption failed")
+	// ErrRedacted indicates a record whose payload has been redacted.
+	ErrRedacted = errors.New("record has been redacted")
+)
+
+// messageAEAD returns the cipher for record idx given E_idx, and the
+// associated data binding a ciphertext to idx and ts.
+func messageAEAD(key [KeySize]byte, idx uint64, ts int64) (cipher.AEAD, []byte, error) {
+	k := mac(key[:], []byte(encKeyDomain))
+	block, err := aes.NewCipher(k[:])
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	ad := binary.BigEndian.AppendUint64(nil, idx)
+	ad = binary.BigEndian.AppendUint64(ad, uint64(ts))
+	return aead, ad, nil
+}
+
+// encryptMessage returns msg in an encrypted envelope for record idx.
+func encryptMessage(key [KeySize]byte, idx uint64, ts int64, msg []byte) ([]byte, error) {
+	aead, ad, err := messageAEAD(key, idx, ts)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, encNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(envelopeMagic)+1+len(msg)+encOverhead)
+	out = append(out, envelopeMagic...)
+	out = append(out, envelopeEncrypted)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, msg, ad), nil
+}
+
+// Encrypted reports whether the message of r is encrypted. It is false for
+// redacted records.
+func (r Record) Encrypted() bool {
+	payload, ok := r.Payload()
+	return ok && envelopeKind(payload) == envelopeEncrypted
+}
+
+// Decryptor decrypts the records of an encrypted log. It keeps the latest
+// key it derived, so decrypting records in index order costs one hash each.
+// A Decryptor is not safe for concurrent use.
+type Decryptor struct {
+	redactable bool          // records are redactable envelopes (Config.Redactable)
+	base       uint64        // index of baseKey
+	baseKey    [KeySize]byte // E_base
+	idx        uint64        // index of key
+	key        [KeySize]byte // E_idx
+}
+
+// NewDecryptor returns a Decryptor for the records after idx, given E_idx,
+// of a log written with Config.Redactable set to redactable.
+// NewDecryptor(0, E_0, redactable) decrypts the whole log; since keys only
+// evolve forward, a later key reveals nothing before it.
+func NewDecryptor(idx uint64, key [KeySize]byte, redactable bool) *Decryptor {
+	return &Decryptor{redactable: redactable, base: idx, baseKey: key, idx: idx, key: key}
+}
+
+// Decrypt returns the message of r. Records that are not encrypted, such as
+// protocol records, are returned as stored; redacted records fail with
+// ErrRedacted.
+func (d *Decryptor) Decrypt(r Record) ([]byte, error) {
+	payload, ok := messageOf(r, d.redactable)
+	if !ok {
+		return nil, fmt.Errorf("record %d: %w", r.Index, ErrRedacted)
+	}
+	if envelopeKind(payload) != envelopeEncrypted {
+		return payload, nil
+	}
+	if r.Index <= d.base {
+		return nil, fmt.Errorf("record %d precedes the decryption key at %d", r.Index, d.base)
+	}
+	if r.Index < d.idx {
+		d.idx, d.key = d.base, d.baseKey
+	}
+	for ; d.idx < r.Index; d.idx++ {
+		fwdKey(&d.key) // E_i = H(E_{i-1})
+	}
+	aead, ad, err := messageAEAD(d.key, r.Index, r.TS)
+	if err != nil {
+		return nil, err
+	}
+	sealed := payload[len(envelopeMagic)+1:]
+	msg, err := aead.Open(nil, sealed[:encNonceSize], sealed[encNonceSize:], ad)
+	if err != nil {
+		return nil, fmt.Errorf("record %d: %w", r.Index, ErrDecrypt)
+	}
+	return msg, nil
+}
+
+// DecryptionKey returns E_{from-1} of logID, derived from the committed E_0,
+// so an authorised reader can decrypt the records from from on with
+// NewDecryptor(from-1, key, redactable) and nothing before them. It fails with
+// ErrNotEncrypted if the log committed no encryption key.
+func (ts *TrustedServer) DecryptionKey(logID string, from uint64) ([KeySize]byte, error) {
+	commit, ok := ts.commitments[logID]
+	if !ok {
+		return [KeySize]byte{}, errors.New("log not registered with trusted server")
+	}
+	if isZero32(commit.KeyE0) {
+		return [KeySize]byte{}, ErrNotEncrypted
+	}
+	if from == 0 {
+		return [KeySize]byte{}, errors.New("records start at index 1")
+	}
+	key := commit.KeyE0
+	for i := uint64(1); i < from; i++ {
+		fwdKey(&key) // E_i = H(E_{i-1})
+	}
+	return key, nil
+}
+
+// Decryptor returns a Decryptor for the whole of logID (see DecryptionKey).
+func (ts *TrustedServer) Decryptor(logID string) (*Decryptor, error) {
+	key, err := ts.DecryptionKey(logID, 1)
+	if err != nil {
+		return nil, err
+	}
+	return NewDecryptor(0, key, ts.commitments[logID].Redactable), nil
+}
@@ -0,0 +1,273 @@
+package securelog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+func TestEncrypt_FileStore(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-encrypt-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	logger, err := New(Config{AnchorEvery: 4, Encrypt: true}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, b0 := logger.GetInitialKeys()
+	e0, ok := logger.GetInitialEncryptionKey()
+	if !ok || isZero32(e0) {
+		t.Fatal("Expected an encryption key")
+	}
+
+	var msgs [][]byte
+	for i := 0; i < 10; i++ {
+		msg := []byte(fmt.Sprintf("secret %d", i))
+		msgs = append(msgs, msg)
+		e, err := logger.Append(msg, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(e.Msg, msg) {
+			t.Errorf("Entry %d carries %q, want the plaintext", e.Index, e.Msg)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, logsFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("secret")) {
+		t.Error("logs.dat holds plaintext")
+	}
+
+	// Both chains verify over the ciphertext without the encryption key.
+	records := readAllRecords(t, store)
+	var zeroTag [32]byte
+	if _, err := VerifyFrom(records, 0, a0, zeroTag); err != nil {
+		t.Fatalf("V-chain: %v", err)
+	}
+	if _, err := VerifyFromTrusted(records, 0, b0, zeroTag); err != nil {
+		t.Fatalf("T-chain: %v", err)
+	}
+	if err := NewSemiTrustedVerifier(store).VerifyFromAnchor(mustAnchor(t, store, 4)); err != nil {
+		t.Fatalf("VerifyFromAnchor: %v", err)
+	}
+
+	d := NewDecryptor(0, e0, false)
+	for _, i := range []int{0, 1, 9, 3, 4} {
+		r := records[i]
+		if !r.Encrypted() {
+			t.Fatalf("Record %d is not encrypted", r.Index)
+		}
+		got, err := d.Decrypt(r)
+		if err != nil {
+			t.Fatalf("Decrypt record %d: %v", r.Index, err)
+		}
+		if !bytes.Equal(got, msgs[i]) {
+			t.Errorf("Record %d decrypted to %q, want %q", r.Index, got, msgs[i])
+		}
+	}
+
+	// A later key decrypts the records after it and nothing before.
+	e5 := e0
+	for i := 0; i < 5; i++ {
+		fwdKey(&e5)
+	}
+	later := NewDecryptor(5, e5, false)
+	if got, err := later.Decrypt(records[5]); err != nil || !bytes.Equal(got, msgs[5]) {
+		t.Errorf("Decrypt record 6 from E_5 = %q, %v", got, err)
+	}
+	if _, err := later.Decrypt(records[4]); err == nil {
+		t.Error("Expected a key at 5 to refuse record 5")
+	}
+	if _, err := NewDecryptor(0, a0, false).Decrypt(records[0]); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected ErrDecrypt with the wrong key, got: %v", err)
+	}
+
+	// The ciphertext is bound to its index and timestamp.
+	moved := records[2]
+	moved.TS++
+	if _, err := d.Decrypt(moved); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected ErrDecrypt for a changed timestamp, got: %v", err)
+	}
+	tampered := records[2]
+	tampered.Msg = bytes.Clone(tampered.Msg)
+	tampered.Msg[len(tampered.Msg)-1] ^= 0x01
+	if _, err := d.Decrypt(tampered); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected ErrDecrypt for a tampered ciphertext, got: %v", err)
+	}
+	if _, err := VerifyFrom([]Record{records[0], records[1], tampered}, 0, a0, zeroTag); !errors.Is(err, ErrTagMismatch) {
+		t.Errorf("Expected ErrTagMismatch for a tampered ciphertext, got: %v", err)
+	}
+}
+
+func TestEncrypt_RetryAfterFailedAppend(t *testing.T) {
+	store := NewMemoryStore()
+	logger, err := New(Config{Encrypt: true}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e0, _ := logger.GetInitialEncryptionKey()
+	a0, _ := logger.GetInitialKeys()
+
+	// The failed append and its retry encrypt under the same E_1.
+	store.FailAppend(1, nil)
+	if _, err := logger.Append([]byte("first attempt"), time.Now()); !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("Expected ErrInjectedFault, got: %v", err)
+	}
+	if _, err := logger.Append([]byte("retried entry"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	records := readAllRecords(t, store)
+	if len(records) != 1 || records[0].Index != 1 {
+		t.Fatalf("Expected only the retried record 1, got %d records", len(records))
+	}
+	var zeroTag [32]byte
+	if _, err := VerifyFrom(records, 0, a0, zeroTag); err != nil {
+		t.Fatalf("V-chain after a retried append: %v", err)
+	}
+	if got, err := NewDecryptor(0, e0, false).Decrypt(records[0]); err != nil || string(got) != "retried entry" {
+		t.Errorf("Retried record decrypted to %q, %v", got, err)
+	}
+
+	// Each encryption under one key draws a fresh nonce.
+	key := e0
+	fwdKey(&key)
+	first, err := encryptMessage(key, 1, records[0].TS, []byte("first attempt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := func(b []byte) []byte { return b[len(envelopeMagic)+1 : len(envelopeMagic)+1+encNonceSize] }
+	if bytes.Equal(nonce(first), nonce(records[0].Msg)) || bytes.Equal(nonce(first), make([]byte, encNonceSize)) {
+		t.Error("Expected a fresh random nonce for every encryption")
+	}
+}
+
+func TestEncrypt_Protocol(t *testing.T) {
+	for _, redactable := range []bool{false, true} {
+		t.Run(fmt.Sprintf("redactable=%v", redactable), func(t *testing.T) {
+			store := NewMemoryStore()
+			logger, err := New(Config{AnchorEvery: 3, Encrypt: true, Redactable: redactable}, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			logID := "encrypted-log"
+			commit, openMsg, err := logger.InitProtocol(logID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e0, _ := logger.GetInitialEncryptionKey(); isZero32(commit.KeyE0) || e0 == commit.KeyE0 {
+				t.Error("Expected the commitment to carry E_0 and the logger to have moved past it")
+			}
+			for i := 0; i < 6; i++ {
+				if _, err := logger.Append([]byte(fmt.Sprintf("card %d", i)), time.Now()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			closeMsg, err := logger.CloseProtocol(logID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ts := NewTrustedServer()
+			ts.RegisterLog(commit)
+			ts.RegisterOpen(openMsg)
+			if err := ts.AcceptClosure(closeMsg); err != nil {
+				t.Fatal(err)
+			}
+			records := readAllRecords(t, store)
+			if records[0].Encrypted() || records[len(records)-1].Encrypted() {
+				t.Error("Expected the START and CLOSE records in the clear")
+			}
+			if err := ts.FinalVerify(logID, records); err != nil {
+				t.Fatalf("FinalVerify of an encrypted log: %v", err)
+			}
+
+			d, err := ts.Decryptor(logID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range records {
+				got, err := d.Decrypt(r)
+				if err != nil {
+					t.Fatalf("Decrypt record %d: %v", r.Index, err)
+				}
+				want := fmt.Sprintf("card %d", r.Index-2)
+				switch r.Index {
+				case 1:
+					want = "START"
+				case uint64(len(records)):
+					want = "CLOSE"
+				}
+				if string(got) != want {
+					t.Errorf("Record %d decrypted to %q, want %q", r.Index, got, want)
+				}
+			}
+
+			// An authorised reader gets the key for a suffix of the log.
+			key, err := ts.DecryptionKey(logID, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader := NewDecryptor(4, key, redactable)
+			if got, err := reader.Decrypt(records[4]); err != nil || string(got) != "card 3" {
+				t.Errorf("Reader decrypted record 5 to %q, %v", got, err)
+			}
+			if _, err := reader.Decrypt(records[3]); err == nil {
+				t.Error("Expected the reader's key to refuse record 4")
+			}
+
+			if !redactable {
+				if _, err := redactForTest(t, store, 3); !errors.Is(err, ErrNotRedactable) {
+					t.Errorf("Expected ErrNotRedactable, got: %v", err)
+				}
+				return
+			}
+			auth, err := ts.AuthorizeRedaction(logID, records[2], "pci", "dpo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Redact(store, auth); err != nil {
+				t.Fatal(err)
+			}
+			records = readAllRecords(t, store)
+			if _, err := d.Decrypt(records[2]); !errors.Is(err, ErrRedacted) {
+				t.Errorf("Expected ErrRedacted, got: %v", err)
+			}
+			if got, err := d.Decrypt(records[3]); err != nil || string(got) != "card 2" {
+				t.Errorf("Decrypt after redaction = %q, %v", got, err)
+			}
+			var zeroTag [32]byte
+			opts := VerifyOptions{Redactable: true, RedactionKey: ts.RedactionPublicKey()}
+			if _, err := VerifyChainContext(context.Background(), records, 0, commit.KeyA0, zeroTag, true, opts); err != nil {
+				t.Errorf("V-chain after redaction: %v", err)
+			}
+			if err := ts.FinalVerify(logID, records); err != nil {
+				t.Errorf("FinalVerify after redaction: %v", err)
+			}
+		})
+	}
+
+	ts := NewTrustedServer()
+	ts.RegisterLog(InitCommitment{LogID: "plain"})
+	if _, err := ts.Decryptor("plain"); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Expected ErrNotEncrypted, got: %v", err)
+	}
+}
@@ -2,7 +2,7 @@ package securelog
 
 // Storage Backend Comparison
 //
-// This package provides two storage backends for secure logging:
+// This package provides three storage backends for secure logging:
 //
 // 1. POSIX File Storage (file_store.go) - DEFAULT & RECOMMENDED
 //    - Simple append-only binary files
@@ -16,6 +16,12 @@ package securelog
 //    - SQL queries for flexible access
 //    - Best for: applications already using SQLite, complex queries
 //
+// 3. Bolt Storage (bolt_store.go) - EMBEDDED KEY-VALUE
+//    - bbolt B+tree database in a single file, pure Go
+//    - One transaction per append
+//    - Records, anchors, tree and tail in buckets keyed by big-endian index
+//    - Best for: edge agents that need indexed reads without SQLite
+//
 // Usage Examples:
 //
 // === POSIX File Storage (Default, Recommended) ===
@@ -47,6 +53,22 @@ package securelog
 //   logger, _ := securelog.New(securelog.Config{AnchorEvery: 100}, store)
 //   logger.Append([]byte("event 1"), time.Now())
 //
+//   // Many logs in one database
+//   db, _ := securelog.OpenSQLiteDB("file:logs.db")
+//   orders, _ := securelog.OpenSQLiteLogStore(db, "orders")
+//   billing, _ := securelog.OpenSQLiteLogStore(db, "billing")
+//   ids, _ := securelog.ListSQLiteLogs(db) // ["billing", "orders"]
+//
+//
+// === Bolt Storage (Embedded) ===
+//
+//   store, err := securelog.OpenBoltStore("/var/lib/agent/audit.bolt")
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   logger, _ := securelog.New(securelog.Config{AnchorEvery: 100}, store)
+//   logger.Append([]byte("event 1"), time.Now())
+//
 //
 // File Format (POSIX storage):
 //
@@ -96,17 +118,26 @@ package securelog
 //   - Requires SQLite library dependency
 //   - Higher memory usage (~10-50 MB)
 //
+// Bolt Storage (Embedded):
+//   ✓ Transactions ensure atomicity
+//   ✓ Ordered index lookups without a query engine
+//   ✓ Memory-mapped reads
+//   - One writer process at a time (file lock)
+//   - Time-range queries scan the log
+//
 //
 // Migration Between Backends:
 //
 //   // Export from SQLite
 //   sqlStore, _ := securelog.OpenSQLiteStore("app.db")
-//   ch, done, _ := sqlStore.Iter(1)
 //   var records []securelog.Record
-//   for r := range ch {
+//   for r, err := range securelog.AllRecords(sqlStore, 1) {
+//       if err != nil {
+//           // *StoreReadError: the export is incomplete
+//           break
+//       }
 //       records = append(records, r)
 //   }
-//   done()
 //
 //   // Import to file storage
 //   fileStore, _ := securelog.OpenFileStore("/var/log/secure")
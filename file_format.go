@@ -0,0 +1,425 @@
+package securelog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// From format version 1 on, the files that hold MAC state - logs.dat and the
+// segment data files, anchors.idx and tail.dat - start with a fixed-size
+// header:
+//
+//	[4]byte: magic "SLOG"
+//	[2]byte: format version (uint16)
+//	[1]byte: file kind (1=records, 2=anchors, 3=tail)
+//	[1]byte: suite ID (see SuiteHMACSHA256)
+//	[1]byte: log ID length
+//	[55]byte: log ID, zero-padded
+//
+// Entries follow in the layouts documented on fileStore; version 2 added a
+// checksum to every record and version 3 a codec byte to every message.
+// Files written before versioning have no header and are read as version 0;
+// no record, anchor or tail entry starts with the magic bytes, since those
+// would put the index beyond 2^62. logs.idx and tree.dat hold only data derived from the records
+// and stay headerless. MigrateFileStore rewrites a store to FileFormatVersion.
+const (
+	fileMagic      = "SLOG"
+	fileHeaderSize = 64
+	maxLogIDLen    = fileHeaderSize - 9
+)
+
+const (
+	// FileFormatVersion is the format version new file stores are written in.
+	FileFormatVersion uint16 = 3
+	// SuiteHMACSHA256 identifies HMAC-SHA256 tags with SHA-256 key evolution,
+	// tag folding and Merkle hashing. It is the only suite so far.
+	SuiteHMACSHA256 uint8 = 1
+)
+
+// fileKind tells the header-carrying files of a store apart.
+type fileKind uint8
+
+const (
+	kindRecords fileKind = 1
+	kindAnchors fileKind = 2
+	kindTail    fileKind = 3
+)
+
+var (
+	// ErrUnsupportedFormat indicates store files this version cannot read.
+	ErrUnsupportedFormat = errors.New("unsupported file store format")
+	// ErrLogIDMismatch indicates a file store opened for a log it does not belong to.
+	ErrLogIDMismatch = errors.New("file store belongs to another log")
+
+	errMixedFormat = fmt.Errorf("%w: files of different format versions (run MigrateFileStore)", ErrUnsupportedFormat)
+)
+
+// FileFormat describes the on-disk format of a file store.
+type FileFormat struct {
+	Version uint16 // 0 for stores written before format versioning
+	Suite   uint8
+	LogID   string // always empty for version 0
+}
+
+func encodeFileHeader(kind fileKind, f FileFormat) []byte {
+	buf := make([]byte, fileHeaderSize)
+	copy(buf[0:4], fileMagic)
+	binary.BigEndian.PutUint16(buf[4:6], f.Version)
+	buf[6] = byte(kind)
+	buf[7] = f.Suite
+	buf[8] = byte(len(f.LogID))
+	copy(buf[9:], f.LogID)
+	return buf
+}
+
+func decodeFileHeader(buf []byte) (fileKind, FileFormat, error) {
+	var f FileFormat
+	if string(buf[0:4]) != fileMagic {
+		return 0, f, fmt.Errorf("%w: bad magic", ErrUnsupportedFormat)
+	}
+	f.Version = binary.BigEndian.Uint16(buf[4:6])
+	f.Suite = buf[7]
+	n := int(buf[8])
+	if n > maxLogIDLen {
+		return 0, f, fmt.Errorf("%w: log ID length %d", ErrUnsupportedFormat, n)
+	}
+	f.LogID = string(buf[9 : 9+n])
+	return fileKind(buf[6]), f, nil
+}
+
+// fileHeader returns the header for a new file of the given kind, or nil for
+// a version 0 store.
+func (s *fileStore) fileHeader(kind fileKind) []byte {
+	if s.format.Version == 0 {
+		return nil
+	}
+	return encodeFileHeader(kind, s.format)
+}
+
+// probeHeader reads the start of the file at path. versioned reports a
+// header, which is nil if the file ends before the header does; empty
+// reports a missing or empty file.
+func probeHeader(path string) (header []byte, versioned, empty bool, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, true, nil
+	}
+	if err != nil {
+		return nil, false, false, fmt.Errorf("open %s: %w", filepath.Base(path), err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, fileHeaderSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, false, false, fmt.Errorf("read %s: %w", filepath.Base(path), err)
+	}
+	switch {
+	case n == 0:
+		return nil, false, true, nil
+	case !bytes.HasPrefix([]byte(fileMagic), buf[:min(n, len(fileMagic))]):
+		return nil, false, false, nil
+	case n < fileHeaderSize:
+		return nil, true, false, nil
+	}
+	return buf, true, false, nil
+}
+
+// detectFormat determines the format of the store files at paths. A store
+// without any data is new and gets FileFormatVersion with logID.
+func detectFormat(paths []string, logID string) (FileFormat, error) {
+	var found *FileFormat
+	legacy, versioned := false, false
+	for _, path := range paths {
+		header, v, empty, err := probeHeader(path)
+		if err != nil {
+			return FileFormat{}, err
+		}
+		if empty {
+			continue
+		}
+		if !v {
+			legacy = true
+			continue
+		}
+		versioned = true
+		if header == nil {
+			continue // torn while the file was created; holds no entries
+		}
+		_, f, err := decodeFileHeader(header)
+		if err != nil {
+			return FileFormat{}, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		if found != nil && found.Version != f.Version {
+			return FileFormat{}, errMixedFormat
+		}
+		if found != nil && *found != f {
+			return FileFormat{}, fmt.Errorf("%w: %s has a different header", ErrUnsupportedFormat, filepath.Base(path))
+		}
+		found = &f
+	}
+
+	switch {
+	case legacy && versioned:
+		return FileFormat{}, errMixedFormat
+	case legacy:
+		return FileFormat{Version: 0, Suite: SuiteHMACSHA256}, nil
+	case found == nil:
+		if len(logID) > maxLogIDLen {
+			return FileFormat{}, fmt.Errorf("log ID longer than %d bytes", maxLogIDLen)
+		}
+		return FileFormat{Version: FileFormatVersion, Suite: SuiteHMACSHA256, LogID: logID}, nil
+	}
+
+	f := *found
+	if f.Version == 0 || f.Version > FileFormatVersion {
+		return FileFormat{}, fmt.Errorf("%w: version %d", ErrUnsupportedFormat, f.Version)
+	}
+	if f.Suite != SuiteHMACSHA256 {
+		return FileFormat{}, fmt.Errorf("%w: suite %d", ErrUnsupportedFormat, f.Suite)
+	}
+	if logID != "" && f.LogID != logID {
+		return FileFormat{}, fmt.Errorf("%w: want %q, store has %q", ErrLogIDMismatch, logID, f.LogID)
+	}
+	return f, nil
+}
+
+// formatPaths lists the files detectFormat inspects: tail.dat, anchors.idx
+// and the data files of all segments kept in the store directory.
+func formatPaths(dir string, segs []SegmentInfo) []string {
+	paths := []string{filepath.Join(dir, tailFileName), filepath.Join(dir, anchorsFileName)}
+	for _, seg := range segs {
+		if seg.Dir == "" {
+			data, _ := segmentFileNames(seg.Seq)
+			paths = append(paths, filepath.Join(dir, data))
+		}
+	}
+	return paths
+}
+
+// prepareFileLocked writes the header of a new file of a versioned store, or
+// checks the header of an existing one.
+func (s *fileStore) prepareFileLocked(f *os.File, kind fileKind) error {
+	if s.hdrLen == 0 {
+		return nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", filepath.Base(f.Name()), err)
+	}
+	if info.Size() < fileHeaderSize {
+		// New, or torn while being created: nothing follows the header yet.
+		if err := f.Truncate(0); err != nil {
+			return fmt.Errorf("truncate %s: %w", filepath.Base(f.Name()), err)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("seek %s: %w", filepath.Base(f.Name()), err)
+		}
+		if _, err := f.Write(s.fileHeader(kind)); err != nil {
+			return fmt.Errorf("write %s header: %w", filepath.Base(f.Name()), err)
+		}
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("sync %s: %w", filepath.Base(f.Name()), err)
+		}
+		return nil
+	}
+
+	buf := make([]byte, fileHeaderSize)
+	if _, err := f.ReadAt(buf, 0); err != nil {
+		return fmt.Errorf("read %s header: %w", filepath.Base(f.Name()), err)
+	}
+	k, format, err := decodeFileHeader(buf)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(f.Name()), err)
+	}
+	if k != kind || format != s.format {
+		return fmt.Errorf("%w: %s header does not match the store", ErrUnsupportedFormat, filepath.Base(f.Name()))
+	}
+	return nil
+}
+
+// FileStoreFormat reports the format of the file store in dir without
+// opening it. A directory without store data reports FileFormatVersion.
+func FileStoreFormat(dir string) (FileFormat, error) {
+	segs, found, err := loadManifest(dir)
+	if err != nil {
+		return FileFormat{}, err
+	}
+	if !found {
+		segs = []SegmentInfo{{Seq: 0}}
+	}
+	return detectFormat(formatPaths(dir, segs), "")
+}
+
+// MigrateFileStore rewrites the file store in dir to FileFormatVersion,
+// recording logID in the new file headers. Records, anchors and tail keep
+// their content, so all MAC tags are preserved; records gain checksums and
+// codec bytes (leaving messages uncompressed) and the offset indexes are
+// rebuilt. The store must not be open while it is
+// migrated. A store already at FileFormatVersion is left as is, and an
+// interrupted migration completes when run again.
+func MigrateFileStore(dir, logID string) error {
+	if len(logID) > maxLogIDLen {
+		return fmt.Errorf("log ID longer than %d bytes", maxLogIDLen)
+	}
+
+	// Opening repairs an interrupted append before the files are copied.
+	st, err := openFileStore(dir, FileStoreOptions{LogID: logID})
+	switch {
+	case errors.Is(err, errMixedFormat):
+		// An earlier migration was interrupted; its recovery already ran.
+	case err != nil:
+		return err
+	default:
+		version := st.format.Version
+		if err := st.Close(); err != nil {
+			return err
+		}
+		if version == FileFormatVersion {
+			return nil
+		}
+	}
+
+	segs, found, err := loadManifest(dir)
+	if err != nil {
+		return err
+	}
+	if !found {
+		segs = []SegmentInfo{{Seq: 0}}
+	}
+
+	// tail.dat goes first: from then on OpenFileStore sees a mixed store and
+	// refuses it until every file has been migrated.
+	format := FileFormat{Version: FileFormatVersion, Suite: SuiteHMACSHA256, LogID: logID}
+	if err := migrateFile(filepath.Join(dir, tailFileName), kindTail, format); err != nil {
+		return err
+	}
+	if err := migrateFile(filepath.Join(dir, anchorsFileName), kindAnchors, format); err != nil {
+		return err
+	}
+	for i := len(segs) - 1; i >= 0; i-- {
+		dataPath, indexPath := segmentPathsIn(dir, segs[i])
+		if err := migrateFile(dataPath, kindRecords, format); err != nil {
+			return err
+		}
+		if err := rebuildIndex(dataPath, indexPath); err != nil {
+			return err
+		}
+	}
+
+	st, err = openFileStore(dir, FileStoreOptions{LogID: logID})
+	if err != nil {
+		return fmt.Errorf("open migrated store: %w", err)
+	}
+	return st.Close()
+}
+
+// migrateFile rewrites the file at path in format unless it is already in it.
+func migrateFile(path string, kind fileKind, format FileFormat) error {
+	header, versioned, _, err := probeHeader(path)
+	if err != nil {
+		return err
+	}
+	var from FileFormat
+	var hdrLen int64
+	if versioned {
+		hdrLen = fileHeaderSize // a torn header is shorter: no entries to copy
+		if header != nil {
+			if _, from, err = decodeFileHeader(header); err != nil {
+				return fmt.Errorf("%s: %w", filepath.Base(path), err)
+			}
+		}
+	}
+	if from.Version == format.Version {
+		return nil
+	}
+
+	src, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", filepath.Base(path), err)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", filepath.Base(path), err)
+	}
+	if _, err := src.Seek(hdrLen, io.SeekStart); err != nil {
+		return fmt.Errorf("seek %s: %w", filepath.Base(path), err)
+	}
+	return replaceFile(filepath.Dir(path), path, func(w io.Writer) error {
+		if _, err := w.Write(encodeFileHeader(kind, format)); err != nil {
+			return fmt.Errorf("write %s header: %w", filepath.Base(path), err)
+		}
+		if kind == kindRecords {
+			return upgradeRecords(w, src, max(info.Size()-hdrLen, 0), from.Version)
+		}
+		if _, err := io.Copy(w, src); err != nil {
+			return fmt.Errorf("copy %s: %w", filepath.Base(path), err)
+		}
+		return nil
+	})
+}
+
+// upgradeRecords copies the avail bytes of records in src, written in format
+// version from, to w in the current format: with checksums and raw codec bytes.
+func upgradeRecords(w io.Writer, src io.Reader, avail int64, from uint16) error {
+	var sumLen int64
+	if from >= 2 {
+		sumLen = checksumSize
+	}
+	reader := bufio.NewReader(src)
+	for {
+		r, err := readRecord(reader, sumLen, avail)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read record: %w", err)
+		}
+		avail -= entrySize(len(r.Msg), sumLen)
+		r.Msg = append([]byte{codecRaw}, r.Msg...)
+		if _, err := w.Write(encodeRecord(r, checksumSize)); err != nil {
+			return fmt.Errorf("write record: %w", err)
+		}
+	}
+}
+
+// rebuildIndex rewrites the offset index of a data file in the current format.
+func rebuildIndex(dataPath, indexPath string) error {
+	src, err := os.Open(dataPath)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", filepath.Base(dataPath), err)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", filepath.Base(dataPath), err)
+	}
+	if _, err := src.Seek(fileHeaderSize, io.SeekStart); err != nil {
+		return fmt.Errorf("seek %s: %w", filepath.Base(dataPath), err)
+	}
+
+	reader := bufio.NewReader(src)
+	return replaceFile(filepath.Dir(indexPath), indexPath, func(w io.Writer) error {
+		off := int64(fileHeaderSize)
+		for {
+			r, err := readRecord(reader, checksumSize, info.Size()-off)
+			if err == io.EOF {
+				return nil
+			}
+			if errors.Is(err, errEntryBounds) || (err != nil && !errors.Is(err, ErrStorageCorruption)) {
+				return fmt.Errorf("read record: %w", err)
+			}
+			if _, err := w.Write(encodeIndexEntry(r.Index, off)); err != nil {
+				return fmt.Errorf("write index entry: %w", err)
+			}
+			off += entrySize(len(r.Msg), checksumSize)
+		}
+	})
+}
@@ -0,0 +1,242 @@
+package securelog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+func TestFileStore_FormatHeader(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-format-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStoreWithOptions(tmpDir, FileStoreOptions{LogID: "app-log-001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := New(Config{AnchorEvery: 2}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.(*fileStore).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, kind := range map[string]fileKind{logsFileName: kindRecords, anchorsFileName: kindAnchors, tailFileName: kindTail} {
+		data, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, f, err := decodeFileHeader(data)
+		if err != nil || k != kind || f.Version != FileFormatVersion || f.Suite != SuiteHMACSHA256 || f.LogID != "app-log-001" {
+			t.Errorf("%s: unexpected header kind=%d %+v err=%v", name, k, f, err)
+		}
+	}
+	if f, err := FileStoreFormat(tmpDir); err != nil || f.LogID != "app-log-001" {
+		t.Errorf("FileStoreFormat = %+v, %v", f, err)
+	}
+
+	if _, err := OpenFileStoreWithOptions(tmpDir, FileStoreOptions{LogID: "other"}); !errors.Is(err, ErrLogIDMismatch) {
+		t.Errorf("Expected ErrLogIDMismatch, got: %v", err)
+	}
+	store, err = OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatalf("Opening without a log ID failed: %v", err)
+	}
+	if got := readAllRecords(t, store); len(got) != 3 {
+		t.Errorf("Expected 3 records, got %d", len(got))
+	}
+	if err := store.(*fileStore).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A newer version is refused rather than misparsed.
+	tailPath := filepath.Join(tmpDir, tailFileName)
+	data, err := os.ReadFile(tailPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.BigEndian.PutUint16(data[4:6], FileFormatVersion+1)
+	if err := os.WriteFile(tailPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFileStore(tmpDir); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat for a future version, got: %v", err)
+	}
+}
+
+func TestMigrateFileStore(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-migrate-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	entrySize := int64(headerSize + len("entry") + tagsSize + checksumSize)
+	opts := FileStoreOptions{SegmentMaxBytes: fileHeaderSize + 4*entrySize}
+	store, err := OpenFileStoreWithOptions(tmpDir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := New(Config{AnchorEvery: 3}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, _ := logger.GetInitialKeys()
+	for i := 0; i < 10; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	segs, err := store.(SegmentedStore).Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.(*fileStore).Close(); err != nil {
+		t.Fatal(err)
+	}
+	downgradeToV0(t, tmpDir, segs)
+
+	// Headerless files are read as version 0 and stay headerless on append.
+	if f, err := FileStoreFormat(tmpDir); err != nil || f.Version != 0 {
+		t.Fatalf("FileStoreFormat of a legacy store = %+v, %v", f, err)
+	}
+	store, err = OpenFileStoreWithOptions(tmpDir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.store = store
+	for i := 0; i < 3; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wantRecords := readAllRecords(t, store)
+	wantAnchors, err := store.ListAnchors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTail, _, err := store.Tail()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.(*fileStore).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := FileStoreFormat(tmpDir); err != nil || f.Version != 0 {
+		t.Fatalf("Appending changed the format of a legacy store: %+v, %v", f, err)
+	}
+
+	// An interrupted migration leaves a store that is refused until the
+	// migration is run again.
+	format := FileFormat{Version: FileFormatVersion, Suite: SuiteHMACSHA256, LogID: "app-log-001"}
+	if err := migrateFile(filepath.Join(tmpDir, tailFileName), kindTail, format); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFileStore(tmpDir); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("Expected ErrUnsupportedFormat for a partly migrated store, got: %v", err)
+	}
+	if err := MigrateFileStore(tmpDir, "app-log-001"); err != nil {
+		t.Fatalf("MigrateFileStore failed: %v", err)
+	}
+	if err := MigrateFileStore(tmpDir, "app-log-001"); err != nil {
+		t.Fatalf("MigrateFileStore of a migrated store failed: %v", err)
+	}
+	if err := MigrateFileStore(tmpDir, "other"); !errors.Is(err, ErrLogIDMismatch) {
+		t.Errorf("Expected ErrLogIDMismatch, got: %v", err)
+	}
+	if f, err := FileStoreFormat(tmpDir); err != nil || f != format {
+		t.Fatalf("FileStoreFormat after migration = %+v, %v", f, err)
+	}
+
+	store, err = OpenFileStoreWithOptions(tmpDir, FileStoreOptions{SegmentMaxBytes: opts.SegmentMaxBytes, LogID: "app-log-001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+	records := readAllRecords(t, store)
+	anchors, _ := store.ListAnchors()
+	tail, _, _ := store.Tail()
+	if !reflect.DeepEqual(records, wantRecords) || !reflect.DeepEqual(anchors, wantAnchors) || tail != wantTail {
+		t.Fatal("Migration changed the store contents")
+	}
+	var got []uint64
+	for r, err := range RecordRange(store, 6, 8) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r.Index)
+	}
+	if !reflect.DeepEqual(got, []uint64{6, 7, 8}) {
+		t.Errorf("RecordRange(6, 8) after migration yielded %v", got)
+	}
+
+	logger.store = store
+	if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	var zeroTag [32]byte
+	if _, err := VerifyFrom(readAllRecords(t, store), 0, a0, zeroTag); err != nil {
+		t.Fatalf("V-chain after migration failed: %v", err)
+	}
+	if err := NewSemiTrustedVerifier(store).VerifyFromAnchor(mustAnchor(t, store, 3)); err != nil {
+		t.Fatalf("VerifyFromAnchor after migration failed: %v", err)
+	}
+}
+
+// downgradeToV0 rewrites a store in dir into the headerless version 0 layout,
+// without record checksums or codec bytes.
+func downgradeToV0(t *testing.T, dir string, segs []SegmentInfo) {
+	t.Helper()
+	strip := func(path string) []byte {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data[fileHeaderSize:]
+	}
+	write := func(path string, data []byte) {
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(dir, tailFileName), strip(filepath.Join(dir, tailFileName)))
+	write(filepath.Join(dir, anchorsFileName), strip(filepath.Join(dir, anchorsFileName)))
+	for _, seg := range segs {
+		dataPath, indexPath := segmentPathsIn(dir, seg)
+		reader := bytes.NewReader(strip(dataPath))
+		var data, index []byte
+		for {
+			r, err := readRecord(reader, checksumSize, int64(reader.Len()))
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Msg = r.Msg[1:]
+			index = append(index, encodeIndexEntry(r.Index, int64(len(data)))...)
+			data = append(data, encodeRecord(r, 0)...)
+		}
+		write(dataPath, data)
+		write(indexPath, index)
+	}
+}
@@ -0,0 +1,301 @@
+package securelog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// logs.idx maps record indexes to their byte offsets in logs.dat. Entries
+// are fixed-width and in log order, so the last record is one read away and
+// any record can be located by binary search:
+//
+//	[8]byte: index (uint64)
+//	[8]byte: byte offset of the entry in logs.dat
+//
+// The index is derived from logs.dat. It is not synced on every append;
+// instead OpenFileStore brings it up to date, or rebuilds it when it is
+// missing or does not match the log.
+const (
+	indexFileName  = "logs.idx"
+	indexEntrySize = 8 + 8 // idx + offset
+)
+
+// searchEntries returns the position of the first fixed-size entry in f, past
+// a header of base bytes, whose leading index is >= target, together with the
+// number of entries in f. The position equals the count when no entry qualifies.
+func searchEntries(f *os.File, base, entrySize int64, target uint64) (pos, n int64, err error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("stat %s: %w", filepath.Base(f.Name()), err)
+	}
+	n = max(info.Size()-base, 0) / entrySize
+
+	var readErr error
+	var buf [8]byte
+	i := sort.Search(int(n), func(i int) bool {
+		if readErr != nil {
+			return true
+		}
+		if _, err := f.ReadAt(buf[:], base+int64(i)*entrySize); err != nil {
+			readErr = err
+			return true
+		}
+		return binary.BigEndian.Uint64(buf[:]) >= target
+	})
+	if readErr != nil {
+		return 0, 0, fmt.Errorf("read %s: %w", filepath.Base(f.Name()), readErr)
+	}
+	return int64(i), n, nil
+}
+
+// readIndexEntryLocked returns entry pos of logs.idx.
+func (s *fileStore) readIndexEntryLocked(pos int64) (idx uint64, off int64, err error) {
+	var buf [indexEntrySize]byte
+	if _, err := s.indexFile.ReadAt(buf[:], pos*indexEntrySize); err != nil {
+		return 0, 0, fmt.Errorf("read index entry: %w", err)
+	}
+	return binary.BigEndian.Uint64(buf[0:8]), int64(binary.BigEndian.Uint64(buf[8:16])), nil
+}
+
+// appendIndexLocked adds the entry for record idx stored at off.
+func (s *fileStore) appendIndexLocked(idx uint64, off int64) error {
+	info, err := s.indexFile.Stat()
+	if err != nil {
+		return fmt.Errorf("stat index file: %w", err)
+	}
+	if _, err := s.indexFile.WriteAt(encodeIndexEntry(idx, off), info.Size()); err != nil {
+		return fmt.Errorf("write index entry: %w", err)
+	}
+	return nil
+}
+
+func encodeIndexEntry(idx uint64, off int64) []byte {
+	buf := make([]byte, indexEntrySize)
+	binary.BigEndian.PutUint64(buf[0:8], idx)
+	binary.BigEndian.PutUint64(buf[8:16], uint64(off))
+	return buf
+}
+
+// lastRecordLocked returns the index of the last record and the offset just
+// past it in the active data file. If the active segment is empty, the offset
+// is that of its first entry and the index that of the last record before it
+// (0 for an empty log).
+func (s *fileStore) lastRecordLocked() (idx uint64, end int64, err error) {
+	info, err := s.indexFile.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("stat index file: %w", err)
+	}
+	n := info.Size() / indexEntrySize
+	if n == 0 {
+		return s.baseIndexLocked(), s.hdrLen, nil
+	}
+	idx, off, err := s.readIndexEntryLocked(n - 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	hdrIdx, end, err := recordEndAt(s.logFile, off, s.sumLen)
+	if err != nil {
+		return 0, 0, err
+	}
+	if hdrIdx != idx {
+		return 0, 0, fmt.Errorf("index entry for record %d points at record %d", idx, hdrIdx)
+	}
+	return idx, end, nil
+}
+
+// recordEndAt reads the header of the logs.dat entry at off and returns its
+// index and the offset just past it.
+func recordEndAt(f *os.File, off, sumLen int64) (idx uint64, end int64, err error) {
+	var hdr [headerSize]byte
+	if _, err := f.ReadAt(hdr[:], off); err != nil {
+		return 0, 0, fmt.Errorf("read record header: %w", noEOF(err))
+	}
+	msgLen := int(binary.BigEndian.Uint32(hdr[16:20]))
+	return binary.BigEndian.Uint64(hdr[0:8]), off + entrySize(msgLen, sumLen), nil
+}
+
+// seekLocked returns the logs.dat offset of the first record with Index >= start,
+// or the offset past the last indexed record when there is none.
+func (s *fileStore) seekLocked(start uint64) (int64, error) {
+	if s.indexStale {
+		return s.hdrLen, nil
+	}
+	pos, n, err := searchEntries(s.indexFile, 0, indexEntrySize, start)
+	if err != nil {
+		return 0, err
+	}
+	if pos < n {
+		_, off, err := s.readIndexEntryLocked(pos)
+		return off, err
+	}
+	_, end, err := s.lastRecordLocked()
+	return end, err
+}
+
+// checkIndexEntryLocked reports whether entry pos of logs.idx still points
+// at the record it names, complete within the first logSize bytes of logs.dat.
+func (s *fileStore) checkIndexEntryLocked(pos, logSize int64) (off, end int64, ok bool, err error) {
+	idx, off, err := s.readIndexEntryLocked(pos)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if off < 0 || off+headerSize > logSize {
+		return 0, 0, false, nil
+	}
+	hdrIdx, end, err := recordEndAt(s.logFile, off, s.sumLen)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return off, end, hdrIdx == idx && end <= logSize, nil
+}
+
+// recoverIndexLocked brings logs.idx up to date with logs.dat. It resumes after
+// the last indexed record when the index still matches the log, and rebuilds
+// it from scratch otherwise. A torn final record is truncated away; a short
+// record anywhere else is reported as ErrStorageCorruption and logs.dat is
+// left as it is (see checkTornLocked).
+func (s *fileStore) recoverIndexLocked() error {
+	logInfo, err := s.logFile.Stat()
+	if err != nil {
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	idxInfo, err := s.indexFile.Stat()
+	if err != nil {
+		return fmt.Errorf("stat index file: %w", err)
+	}
+
+	n, next, err := s.usableIndexLocked(idxInfo.Size(), logInfo.Size())
+	if err != nil {
+		return err
+	}
+	if next == logInfo.Size() && idxInfo.Size() == n*indexEntrySize {
+		return nil
+	}
+	prev := s.baseIndexLocked()
+	if n > 0 {
+		if prev, _, err = s.readIndexEntryLocked(n - 1); err != nil {
+			return err
+		}
+	}
+	if err := s.indexFile.Truncate(n * indexEntrySize); err != nil {
+		return fmt.Errorf("truncate index file: %w", err)
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(s.logFile, next, logInfo.Size()-next))
+	writer := bufio.NewWriter(io.NewOffsetWriter(s.indexFile, n*indexEntrySize))
+	for {
+		// Corrupt records are indexed all the same and reported when read.
+		r, err := readRecord(reader, s.sumLen, logInfo.Size()-next)
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, errEntryBounds) {
+			// A crash tore the final record before it was committed.
+			if err := s.checkTornLocked(next, logInfo.Size(), prev); err != nil {
+				return err
+			}
+			if err := s.logFile.Truncate(next); err != nil {
+				return fmt.Errorf("truncate torn record: %w", err)
+			}
+			if err := s.logFile.Sync(); err != nil {
+				return fmt.Errorf("sync log file: %w", err)
+			}
+			break
+		}
+		if err != nil && !errors.Is(err, ErrStorageCorruption) {
+			return fmt.Errorf("read log file: %w", err)
+		}
+		if _, err := writer.Write(encodeIndexEntry(r.Index, next)); err != nil {
+			return fmt.Errorf("write index entry: %w", err)
+		}
+		next += entrySize(len(r.Msg), s.sumLen)
+		prev = r.Index
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("write index file: %w", err)
+	}
+	if err := s.indexFile.Sync(); err != nil {
+		return fmt.Errorf("sync index file: %w", err)
+	}
+	return nil
+}
+
+// checkTornLocked decides whether the short entry at off, the last thing in
+// the first logSize bytes of logs.dat, is a record torn by a crash: it must
+// be the record following prev, and that record must not have been
+// committed. Append writes tail.dat after the record, so a tail at or past
+// it means the record was complete once and a later one may follow; its
+// length field was damaged rather than its write cut short.
+func (s *fileStore) checkTornLocked(off, logSize int64, prev uint64) error {
+	want := prev + 1
+	if off+headerSize <= logSize {
+		idx, end, err := recordEndAt(s.logFile, off, s.sumLen)
+		if err != nil {
+			return err
+		}
+		if idx != want {
+			return fmt.Errorf("%w: short entry at offset %d claims record %d, expected %d",
+				ErrStorageCorruption, off, idx, want)
+		}
+		if end <= logSize {
+			return fmt.Errorf("%w: entry at offset %d is short but fits the file", ErrStorageCorruption, off)
+		}
+	}
+	tail, ok, err := s.readTailLocked()
+	if err != nil {
+		return err
+	}
+	if ok && tail.Index >= want {
+		return fmt.Errorf("%w: record %d is short but was committed (tail at %d)",
+			ErrStorageCorruption, want, tail.Index)
+	}
+	return nil
+}
+
+// usableIndexLocked returns the number of logs.idx entries that can be used,
+// all or none, and the logs.dat offset just past the last of them.
+func (s *fileStore) usableIndexLocked(idxSize, logSize int64) (n, next int64, err error) {
+	n = idxSize / indexEntrySize
+	if n == 0 {
+		return 0, s.hdrLen, nil
+	}
+	firstOff, _, firstOK, err := s.checkIndexEntryLocked(0, logSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	_, lastEnd, lastOK, err := s.checkIndexEntryLocked(n-1, logSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !firstOK || !lastOK || firstOff != s.hdrLen {
+		return 0, s.hdrLen, nil
+	}
+	return n, lastEnd, nil
+}
+
+// checkIndexLocked is recoverIndexLocked for read-only stores: an index that
+// does not match the log is not rebuilt but bypassed, so reads scan the log
+// from the start. An index lagging behind the log is still used; reads run
+// on past its last entry.
+func (s *fileStore) checkIndexLocked() error {
+	logInfo, err := s.logFile.Stat()
+	if err != nil {
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	idxInfo, err := s.indexFile.Stat()
+	if err != nil {
+		return fmt.Errorf("stat index file: %w", err)
+	}
+	n, _, err := s.usableIndexLocked(idxInfo.Size(), logInfo.Size())
+	if err != nil {
+		return err
+	}
+	s.indexStale = n == 0 && idxInfo.Size() > 0
+	return nil
+}
@@ -0,0 +1,156 @@
+package securelog
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Crash consistency of the file store rests on the log being authoritative.
+// Append writes, in order:
+//
+//  1. the tree.dat entry and, if any, the anchors.idx entry for the record,
+//  2. the record itself, synced - the commit point,
+//  3. the logs.idx and times.idx entries (derived data, rebuilt on open if needed),
+//  4. tail.dat, overwritten in place.
+//
+// Everything but the record can be checked against the log, so after a crash
+// recoverLocked truncates a torn final record, drops tree and anchor entries
+// for records that were never committed, and rewrites the tail from the last
+// record. Anchor keys cannot be recomputed from the log, which is why anchors
+// are written before their record rather than after it.
+
+// recoverLocked repairs what a crash during Append can leave behind. It runs
+// on open, after the active segment's index has been brought up to date.
+func (s *fileStore) recoverLocked() error {
+	last, tagV, tagT, err := s.lastTagsLocked()
+	if err != nil {
+		return err
+	}
+	if err := s.trimBeyondLocked(last); err != nil {
+		return err
+	}
+
+	tail, ok, err := s.readTailLocked()
+	if err != nil {
+		return err
+	}
+	if last == 0 {
+		if ok {
+			if err := s.tailFile.Truncate(s.hdrLen); err != nil {
+				return fmt.Errorf("truncate tail file: %w", err)
+			}
+		}
+		return nil
+	}
+	if ok && tail.Index == last && tail.TagV == tagV && tail.TagT == tagT {
+		return nil
+	}
+	return s.writeTailLocked(TailState{Index: last, TagV: tagV, TagT: tagT})
+}
+
+// lastTagsLocked returns the index and tags of the last committed record.
+func (s *fileStore) lastTagsLocked() (idx uint64, tagV, tagT [32]byte, err error) {
+	info, err := s.indexFile.Stat()
+	if err != nil {
+		return 0, tagV, tagT, fmt.Errorf("stat index file: %w", err)
+	}
+	n := info.Size() / indexEntrySize
+	if n == 0 {
+		// The active segment is empty; its predecessor's boundary tags apply.
+		if len(s.segments) < 2 {
+			return 0, tagV, tagT, nil
+		}
+		prev := s.segments[len(s.segments)-2]
+		return prev.Last, prev.EndTagV, prev.EndTagT, nil
+	}
+
+	idx, off, err := s.readIndexEntryLocked(n - 1)
+	if err != nil {
+		return 0, tagV, tagT, err
+	}
+	_, end, err := recordEndAt(s.logFile, off, s.sumLen)
+	if err != nil {
+		return 0, tagV, tagT, err
+	}
+	var buf [tagsSize]byte
+	if _, err := s.logFile.ReadAt(buf[:], end-s.sumLen-tagsSize); err != nil {
+		return 0, tagV, tagT, fmt.Errorf("read record tags: %w", noEOF(err))
+	}
+	copy(tagV[:], buf[0:32])
+	copy(tagT[:], buf[32:64])
+	return idx, tagV, tagT, nil
+}
+
+// trimBeyondLocked drops tree and anchor entries for records after last,
+// together with any torn entry at the end of those files.
+func (s *fileStore) trimBeyondLocked(last uint64) error {
+	info, err := s.treeFile.Stat()
+	if err != nil {
+		return fmt.Errorf("stat tree file: %w", err)
+	}
+	size := min(info.Size(), int64(last)*treeEntrySize)
+	size -= size % treeEntrySize
+	if size != info.Size() {
+		if err := s.treeFile.Truncate(size); err != nil {
+			return fmt.Errorf("truncate tree file: %w", err)
+		}
+		if err := s.treeFile.Sync(); err != nil {
+			return fmt.Errorf("sync tree file: %w", err)
+		}
+	}
+
+	info, err = s.anchorFile.Stat()
+	if err != nil {
+		return fmt.Errorf("stat anchor file: %w", err)
+	}
+	n := (info.Size() - s.hdrLen) / anchorEntrySize
+	keep := n
+	if n > 0 {
+		var buf [8]byte
+		if _, err := s.anchorFile.ReadAt(buf[:], s.hdrLen+(n-1)*anchorEntrySize); err != nil {
+			return fmt.Errorf("read anchor: %w", err)
+		}
+		if binary.BigEndian.Uint64(buf[:]) > last {
+			if keep, _, err = searchEntries(s.anchorFile, s.hdrLen, anchorEntrySize, last+1); err != nil {
+				return err
+			}
+		}
+	}
+	if size := s.hdrLen + keep*anchorEntrySize; size != info.Size() {
+		if err := s.anchorFile.Truncate(size); err != nil {
+			return fmt.Errorf("truncate anchor file: %w", err)
+		}
+		if err := s.anchorFile.Sync(); err != nil {
+			return fmt.Errorf("sync anchor file: %w", err)
+		}
+	}
+	return nil
+}
+
+// ResetTail drops anchors and tree entries beyond tail.Index and rewrites
+// tail.dat. tail.Index must be the last record.
+func (s *fileStore) ResetTail(tail TailState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	last, _, err := s.lastRecordLocked()
+	if err != nil {
+		return err
+	}
+	if tail.Index != last {
+		return fmt.Errorf("tail at %d is not the last record %d", tail.Index, last)
+	}
+	if err := s.trimBeyondLocked(last); err != nil {
+		return err
+	}
+	if last == 0 {
+		if err := s.tailFile.Truncate(s.hdrLen); err != nil {
+			return fmt.Errorf("truncate tail file: %w", err)
+		}
+		return nil
+	}
+	return s.writeTailLocked(tail)
+}
@@ -0,0 +1,335 @@
+package securelog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+// crashWriteOrder lists the files fileStore.Append writes, in order.
+var crashWriteOrder = []string{treeFileName, anchorsFileName, logsFileName, indexFileName, timesFileName, tailFileName}
+
+// recordingStore remembers the arguments of the last Append.
+type recordingStore struct {
+	Store
+	rec    Record
+	tail   TailState
+	anchor *Anchor
+}
+
+func (s *recordingStore) Append(r Record, tail TailState, anchor *Anchor) error {
+	s.rec, s.tail, s.anchor = r, tail, anchor
+	return s.Store.Append(r, tail, anchor)
+}
+
+func snapshotDir(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[e.Name()] = data
+	}
+	return files
+}
+
+func writeDir(t *testing.T, dir string, files map[string][]byte) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// crashStates returns the on-disk states a crash can leave while Append turns
+// before into after: every file earlier in crashWriteOrder fully written, the
+// current one written up to each byte offset, later ones untouched. tail.dat
+// and times.idx are overwritten in place; the other files are appended to.
+func crashStates(before, after map[string][]byte) []map[string][]byte {
+	var states []map[string][]byte
+	for step, name := range crashWriteOrder {
+		old, cur := before[name], after[name]
+		if reflect.DeepEqual(old, cur) {
+			continue
+		}
+		start := len(old)
+		if name == tailFileName || name == timesFileName {
+			start = 0
+		}
+		for k := 0; start+k <= len(cur); k++ {
+			state := make(map[string][]byte)
+			for i, n := range crashWriteOrder {
+				if i < step {
+					state[n] = after[n]
+				} else {
+					state[n] = before[n]
+				}
+			}
+			torn := append([]byte(nil), cur[:start+k]...)
+			if len(old) > start+k {
+				torn = append(torn, old[start+k:]...)
+			}
+			state[name] = torn
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+func TestFileStore_CrashConsistency(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-crash-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Crash while writing the first record, an anchored one and a plain one.
+	for _, n := range []uint64{1, 5, 7} {
+		t.Run(fmt.Sprintf("record-%d", n), func(t *testing.T) {
+			baseDir := filepath.Join(tmpDir, fmt.Sprintf("base-%d", n))
+			store, err := OpenFileStore(baseDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs := &recordingStore{Store: store}
+			logger, err := New(Config{AnchorEvery: 5}, rs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a0, _ := logger.GetInitialKeys()
+			for i := uint64(1); i < n; i++ {
+				if _, err := logger.Append([]byte(fmt.Sprintf("entry %d", i)), time.Now()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before := snapshotDir(t, baseDir)
+			if _, err := logger.Append([]byte(fmt.Sprintf("entry %d", n)), time.Now()); err != nil {
+				t.Fatal(err)
+			}
+			after := snapshotDir(t, baseDir)
+			wantRecords := readAllRecords(t, store)
+			wantAnchors, err := store.ListAnchors()
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantTail, _, err := store.Tail()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.(*fileStore).Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			states := crashStates(before, after)
+			if len(states) < 200 {
+				t.Fatalf("Expected a crash state per written byte, got %d", len(states))
+			}
+			for i, state := range states {
+				dir := filepath.Join(tmpDir, fmt.Sprintf("crash-%d-%d", n, i))
+				writeDir(t, dir, state)
+				checkCrashState(t, dir, n, rs, a0, wantRecords, wantAnchors, wantTail)
+				if t.Failed() {
+					t.Fatalf("Crash state %d of %d failed", i, len(states))
+				}
+				_ = os.RemoveAll(dir)
+			}
+		})
+	}
+}
+
+// checkCrashState opens a store left behind by a crash while appending record
+// n and checks it recovers to either n-1 or n records, consistently across
+// all files, and accepts record n again if it was lost.
+func checkCrashState(
+	t *testing.T, dir string, n uint64, ref *recordingStore, a0 [KeySize]byte,
+	wantRecords []Record, wantAnchors []Anchor, wantTail TailState,
+) {
+	t.Helper()
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Errorf("OpenFileStore after crash: %v", err)
+		return
+	}
+	defer store.(*fileStore).Close()
+
+	var records []Record
+	for r, err := range AllRecords(store, 1) {
+		if err != nil {
+			t.Errorf("Read after crash: %v", err)
+			return
+		}
+		records = append(records, r)
+	}
+	m := uint64(len(records))
+	if m != n && m != n-1 {
+		t.Errorf("Expected %d or %d records, got %d", n-1, n, m)
+		return
+	}
+	if m > 0 && !reflect.DeepEqual(records, wantRecords[:m]) {
+		t.Error("Recovered records differ from those written")
+		return
+	}
+
+	tail, ok, err := store.Tail()
+	if err != nil {
+		t.Errorf("Tail after crash: %v", err)
+		return
+	}
+	if m == 0 {
+		if ok {
+			t.Errorf("Expected no tail for an empty log, got %+v", tail)
+		}
+	} else if !ok || tail.Index != m || tail.TagV != records[m-1].TagV || tail.TagT != records[m-1].TagT ||
+		tail.TreeRoot != MerkleRoot(records, false) {
+		t.Errorf("Tail %+v does not match record %d", tail, m)
+		return
+	}
+
+	anchors, err := store.ListAnchors()
+	if err != nil {
+		t.Errorf("ListAnchors after crash: %v", err)
+		return
+	}
+	for _, a := range anchors {
+		if a.Index > m {
+			t.Errorf("Anchor %d survived for an uncommitted record", a.Index)
+			return
+		}
+	}
+
+	if m == n-1 {
+		if err := store.Append(ref.rec, ref.tail, ref.anchor); err != nil {
+			t.Errorf("Re-appending record %d after crash: %v", n, err)
+			return
+		}
+	}
+
+	records = readAllRecords(t, store)
+	anchors, _ = store.ListAnchors()
+	tail, _, _ = store.Tail()
+	if !reflect.DeepEqual(records, wantRecords) || !reflect.DeepEqual(anchors, wantAnchors) || tail != wantTail {
+		t.Error("Store state after recovery differs from an uninterrupted append")
+		return
+	}
+	var zeroTag [32]byte
+	if _, err := VerifyFrom(records, 0, a0, zeroTag); err != nil {
+		t.Errorf("V-chain after recovery: %v", err)
+	}
+	last := wantRecords[n-1]
+	var inTime []Record
+	for r, err := range store.(TimeStore).IterTime(time.Unix(0, last.TS), time.Unix(0, last.TS+1)) {
+		if err != nil {
+			t.Errorf("IterTime after recovery: %v", err)
+			return
+		}
+		inTime = append(inTime, r)
+	}
+	if len(inTime) == 0 || inTime[len(inTime)-1].Index != n {
+		t.Errorf("IterTime after recovery missed record %d", n)
+	}
+}
+
+// TestFileStore_ResumesInterruptedPrune stops a prune after its first file
+// rewrite and checks that reopening the store completes it.
+func TestFileStore_ResumesInterruptedPrune(t *testing.T) {
+	for name, opts := range map[string]FileStoreOptions{
+		"single":    {},
+		"segmented": {SegmentMaxBytes: 512},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "securelog-prune-crash-*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tmpDir)
+
+			store, err := openFileStore(tmpDir, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			logger, err := New(Config{AnchorEvery: 5}, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 30; i++ {
+				if _, err := logger.Append([]byte(fmt.Sprintf("entry %d", i)), time.Now()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tail, _, err := store.Tail()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Crash after journaling and rewriting the data file holding
+			// index 20, before the manifest and anchors follow.
+			journal := filepath.Join(tmpDir, pruneFileName)
+			if err := os.WriteFile(journal, []byte{0, 0, 0, 0, 0, 0, 0, 20}, 0600); err != nil {
+				t.Fatal(err)
+			}
+			store.mu.Lock()
+			err = store.rewriteSegmentLocked(store.segmentForLocked(20), func(r *Record) bool { return r.Index >= 20 })
+			store.mu.Unlock()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if anchors, _ := store.ListAnchors(); anchors[0].Index != 5 {
+				t.Fatalf("Anchors already pruned: %+v", anchors)
+			}
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			reopened, err := openFileStore(tmpDir, opts)
+			if err != nil {
+				t.Fatalf("Reopening after an interrupted prune: %v", err)
+			}
+			defer reopened.Close()
+			if _, err := os.Stat(journal); !os.IsNotExist(err) {
+				t.Errorf("Prune journal left behind: %v", err)
+			}
+			records := readAllRecords(t, reopened)
+			if len(records) != 11 || records[0].Index != 20 {
+				t.Fatalf("After resuming the prune the store holds %d records from %d", len(records), records[0].Index)
+			}
+			if anchors, _ := reopened.ListAnchors(); len(anchors) != 3 || anchors[0].Index != 20 {
+				t.Errorf("After resuming the prune the store holds anchors %+v", anchors)
+			}
+			if got, _, _ := reopened.Tail(); got != tail {
+				t.Errorf("Tail after resuming the prune = %+v, want %+v", got, tail)
+			}
+			if err := NewSemiTrustedVerifier(reopened).VerifyFromAnchor(mustAnchor(t, reopened, 20)); err != nil {
+				t.Errorf("VerifyFromAnchor after resuming the prune: %v", err)
+			}
+
+			// A damaged journal is reported rather than guessed at.
+			if err := reopened.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(journal, []byte{1, 2, 3}, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := openFileStore(tmpDir, opts); !errors.Is(err, ErrStorageCorruption) {
+				t.Errorf("Expected ErrStorageCorruption for a damaged journal, got: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package securelog
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// A segmented file store spreads its records over rolling data files instead
+// of a single logs.dat. Every segment is a data file and an offset index in
+// the logs.dat and logs.idx formats:
+//
+//	logs-000001.dat, logs-000001.idx
+//	logs-000002.dat, logs-000002.idx
+//	...
+//
+// segments.gob lists the segments in order. Only the last one is active;
+// earlier segments are sealed, with their index range and boundary tags fixed
+// in the manifest, and may be moved to another directory (cold storage) with
+// MoveSegment. A legacy logs.dat becomes segment 0 when the store is first
+// opened with segmentation enabled. anchors.idx, tail.dat and tree.dat stay
+// store-wide.
+const manifestFileName = "segments.gob"
+
+var (
+	// ErrNotSegmented indicates a segment operation on a store opened without segmentation.
+	ErrNotSegmented = errors.New("file store is not segmented")
+	// ErrSegmentNotSealed indicates an operation that requires a sealed segment.
+	ErrSegmentNotSealed = errors.New("segment is not sealed")
+)
+
+// FileStoreOptions configures OpenFileStoreWithOptions. The zero value keeps
+// the single-file layout of OpenFileStore.
+type FileStoreOptions struct {
+	SegmentMaxBytes int64         // roll to a new segment before the active one would exceed this size (0=no limit)
+	SegmentMaxAge   time.Duration // roll to a new segment once the active one is this old (0=no limit)
+	LogID           string        // recorded in the file headers of a new store and checked on open (see FileFormat)
+	Check           CheckMode     // check (and repair) the store before returning it
+	Compress        bool          // compress messages below the MAC layer (format version 3 and later; see CompressionStats)
+
+	// ReadOnly opens an existing store for reading only, e.g. for verifiers.
+	// Nothing is created, recovered or locked, and write methods return
+	// ErrReadOnly. Segment limits are ignored.
+	ReadOnly bool
+}
+
+func (o FileStoreOptions) segmented() bool {
+	return !o.ReadOnly && (o.SegmentMaxBytes > 0 || o.SegmentMaxAge > 0)
+}
+
+// openFile opens a store file for reading and writing, creating it if needed.
+// Read-only stores open it for reading only, and a missing file reads as empty.
+func (o FileStoreOptions) openFile(path string, flag int) (*os.File, error) {
+	if !o.ReadOnly {
+		return os.OpenFile(path, os.O_RDWR|os.O_CREATE|flag, 0600)
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return os.Open(os.DevNull)
+	}
+	return f, err
+}
+
+// SegmentInfo describes one data file of a segmented file store.
+type SegmentInfo struct {
+	Seq     uint64
+	First   uint64   // index of the first record (0 while empty)
+	Last    uint64   // index of the last record (0 while empty)
+	EndTagV [32]byte // μ_V,Last - with the key of an anchor at or before First, the next segment verifies on its own
+	EndTagT [32]byte // μ_T,Last (boundary tags are fixed when the segment is sealed)
+	Created time.Time
+	Sealed  bool
+	Dir     string // directory holding the segment files; empty for the store directory
+}
+
+// SegmentedStore is implemented by file stores that roll their data files.
+type SegmentedStore interface {
+	// Segments lists the segments in order; the last one is active.
+	Segments() ([]SegmentInfo, error)
+	// SealSegment seals the active segment and starts a new one. It does
+	// nothing while the active segment is empty.
+	SealSegment() error
+	// MoveSegment moves the files of sealed segment seq to dir. Readers
+	// keep finding them there as long as dir is accessible.
+	MoveSegment(seq uint64, dir string) error
+}
+
+// OpenFileStoreWithOptions creates or opens a POSIX file-based store in dir.
+// With SegmentMaxBytes or SegmentMaxAge set, records are written to rolling
+// segment files. A store that already has a segment manifest is always opened
+// segmented; without limits it then only rolls on SealSegment.
+func OpenFileStoreWithOptions(dir string, opts FileStoreOptions) (Store, error) {
+	return openFileStore(dir, opts)
+}
+
+// segmentFileNames returns the data and index file names of segment seq.
+func segmentFileNames(seq uint64) (data, index string) {
+	if seq == 0 {
+		return logsFileName, indexFileName
+	}
+	return fmt.Sprintf("logs-%06d.dat", seq), fmt.Sprintf("logs-%06d.idx", seq)
+}
+
+// segmentPaths returns the paths of seg's data and index files.
+func (s *fileStore) segmentPaths(seg SegmentInfo) (data, index string) {
+	return segmentPathsIn(s.dir, seg)
+}
+
+// segmentPathsIn returns the paths of seg's data and index files for a store in dir.
+func segmentPathsIn(dir string, seg SegmentInfo) (data, index string) {
+	if seg.Dir != "" {
+		dir = seg.Dir
+	}
+	d, i := segmentFileNames(seg.Seq)
+	return filepath.Join(dir, d), filepath.Join(dir, i)
+}
+
+// loadManifest reads segments.gob from dir; found is false if there is none.
+func loadManifest(dir string) (segs []SegmentInfo, found bool, err error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("read segment manifest: %w", err)
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&segs); err != nil {
+		return nil, false, fmt.Errorf("decode segment manifest: %w", err)
+	}
+	if len(segs) == 0 {
+		return nil, false, errors.New("segment manifest is empty")
+	}
+	return segs, true, nil
+}
+
+// saveManifestLocked atomically replaces segments.gob with segs and adopts them.
+func (s *fileStore) saveManifestLocked(segs []SegmentInfo) error {
+	if s.segmented {
+		path := filepath.Join(s.dir, manifestFileName)
+		err := replaceFile(s.dir, path, func(w io.Writer) error {
+			return gob.NewEncoder(w).Encode(segs)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	s.segments = segs
+	return nil
+}
+
+// activeLocked returns the active segment.
+func (s *fileStore) activeLocked() SegmentInfo {
+	return s.segments[len(s.segments)-1]
+}
+
+// baseIndexLocked returns the index of the last record before the active segment.
+func (s *fileStore) baseIndexLocked() uint64 {
+	if len(s.segments) < 2 {
+		return 0
+	}
+	return s.segments[len(s.segments)-2].Last
+}
+
+// openActiveLocked opens the data and index files of the active segment and
+// brings its index up to date.
+func (s *fileStore) openActiveLocked() error {
+	dataPath, indexPath := s.segmentPaths(s.activeLocked())
+	logFile, err := s.opts.openFile(dataPath, os.O_APPEND)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	indexFile, err := s.opts.openFile(indexPath, 0)
+	if err != nil {
+		_ = logFile.Close()
+		return fmt.Errorf("open index file: %w", err)
+	}
+	s.logFile, s.indexFile = logFile, indexFile
+	if s.opts.ReadOnly {
+		return s.checkIndexLocked()
+	}
+	if err := s.prepareFileLocked(logFile, kindRecords); err != nil {
+		return err
+	}
+	return s.recoverIndexLocked()
+}
+
+// rollIfDueLocked seals the active segment before a record of n bytes is
+// appended at end when the segment has reached its size or age limit.
+// Empty segments are never rolled.
+func (s *fileStore) rollIfDueLocked(n, end int64) (bool, error) {
+	if !s.segmented || end == s.hdrLen {
+		return false, nil
+	}
+	tooBig := s.opts.SegmentMaxBytes > 0 && end+n > s.opts.SegmentMaxBytes
+	tooOld := s.opts.SegmentMaxAge > 0 && time.Since(s.activeLocked().Created) >= s.opts.SegmentMaxAge
+	if !tooBig && !tooOld {
+		return false, nil
+	}
+	return true, s.sealActiveLocked()
+}
+
+// sealActiveLocked fixes the range and boundary tags of the active segment in
+// the manifest and continues in a fresh segment.
+func (s *fileStore) sealActiveLocked() error {
+	first, _, err := s.readIndexEntryLocked(0)
+	if err != nil {
+		return err
+	}
+	last, _, err := s.lastRecordLocked()
+	if err != nil {
+		return err
+	}
+	tail, ok, err := s.readTailLocked()
+	if err != nil {
+		return err
+	}
+	if !ok || tail.Index != last {
+		return fmt.Errorf("tail state does not match last record %d", last)
+	}
+	if err := s.indexFile.Sync(); err != nil {
+		return fmt.Errorf("sync index file: %w", err)
+	}
+
+	segs := slices.Clone(s.segments)
+	act := &segs[len(segs)-1]
+	act.First, act.Last = first, last
+	act.EndTagV, act.EndTagT = tail.TagV, tail.TagT
+	act.Sealed = true
+	segs = append(segs, SegmentInfo{Seq: act.Seq + 1, Created: time.Now()})
+	if err := s.saveManifestLocked(segs); err != nil {
+		return err
+	}
+
+	oldLog, oldIndex := s.logFile, s.indexFile
+	if err := s.openActiveLocked(); err != nil {
+		return err
+	}
+	_ = oldLog.Close()
+	_ = oldIndex.Close()
+	return nil
+}
+
+// Segments lists the segments in order; the last one is active.
+func (s *fileStore) Segments() ([]SegmentInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	segs := slices.Clone(s.segments)
+	act := &segs[len(segs)-1]
+	last, _, err := s.lastRecordLocked()
+	if err != nil {
+		return nil, err
+	}
+	if last != s.baseIndexLocked() {
+		first, _, err := s.readIndexEntryLocked(0)
+		if err != nil {
+			return nil, err
+		}
+		act.First, act.Last = first, last
+	}
+	return segs, nil
+}
+
+// SealSegment seals the active segment and starts a new one.
+func (s *fileStore) SealSegment() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	if !s.segmented {
+		return ErrNotSegmented
+	}
+	if _, end, err := s.lastRecordLocked(); err != nil || end == s.hdrLen {
+		return err
+	}
+	return s.sealActiveLocked()
+}
+
+// MoveSegment moves the files of sealed segment seq to dir. The files are
+// copied and synced before the manifest points at them, and only then
+// removed from their old location.
+func (s *fileStore) MoveSegment(seq uint64, dir string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	if !s.segmented {
+		return ErrNotSegmented
+	}
+	i := slices.IndexFunc(s.segments, func(seg SegmentInfo) bool { return seg.Seq == seq })
+	if i < 0 {
+		return fmt.Errorf("segment %d not found", seq)
+	}
+	if !s.segments[i].Sealed {
+		return ErrSegmentNotSealed
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	if dir == s.dir {
+		dir = ""
+	}
+
+	segs := slices.Clone(s.segments)
+	segs[i].Dir = dir
+	srcData, srcIndex := s.segmentPaths(s.segments[i])
+	dstData, dstIndex := s.segmentPaths(segs[i])
+	if srcData == dstData {
+		return nil
+	}
+	for _, p := range [][2]string{{srcData, dstData}, {srcIndex, dstIndex}} {
+		if err := copyFile(p[0], p[1]); err != nil {
+			return err
+		}
+	}
+	if err := s.saveManifestLocked(segs); err != nil {
+		return err
+	}
+	_ = os.Remove(srcData)
+	_ = os.Remove(srcIndex)
+	return syncDir(filepath.Dir(srcData))
+}
+
+// copyFile durably copies src to dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", filepath.Base(src), err)
+	}
+	defer in.Close()
+	return replaceFile(filepath.Dir(dst), dst, func(w io.Writer) error {
+		if _, err := io.Copy(w, in); err != nil {
+			return fmt.Errorf("copy %s: %w", filepath.Base(src), err)
+		}
+		return nil
+	})
+}
+
+// segmentForLocked returns the position of the segment holding record idx.
+func (s *fileStore) segmentForLocked(idx uint64) int {
+	for i, seg := range s.segments {
+		if !seg.Sealed || idx <= seg.Last {
+			return i
+		}
+	}
+	return len(s.segments) - 1
+}
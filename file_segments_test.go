@@ -0,0 +1,215 @@
+package securelog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+func TestSegmentedFileStore(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-segments-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	entrySize := int64(headerSize + codecSize + len("entry") + tagsSize + checksumSize)
+	store, err := OpenFileStoreWithOptions(tmpDir, FileStoreOptions{SegmentMaxBytes: fileHeaderSize + 4*entrySize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := New(Config{AnchorEvery: 5}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, _ := logger.GetInitialKeys()
+	for i := 0; i < 18; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	segStore, ok := store.(SegmentedStore)
+	if !ok {
+		t.Fatal("File store should implement SegmentedStore")
+	}
+	segs, err := segStore.Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs) != 5 {
+		t.Fatalf("Expected 5 segments, got %d: %+v", len(segs), segs)
+	}
+	records := readAllRecords(t, store)
+	if len(records) != 18 {
+		t.Fatalf("Expected 18 records across segments, got %d", len(records))
+	}
+	next := uint64(1)
+	for i, seg := range segs {
+		if seg.Seq != uint64(i+1) || seg.First != next || seg.Sealed != (i < len(segs)-1) {
+			t.Fatalf("Unexpected segment %d: %+v", i, seg)
+		}
+		if seg.Sealed && (seg.EndTagV != records[seg.Last-1].TagV || seg.EndTagT != records[seg.Last-1].TagT) {
+			t.Errorf("Segment %d boundary tags do not match record %d", seg.Seq, seg.Last)
+		}
+		dataName, _ := segmentFileNames(seg.Seq)
+		if _, err := os.Stat(filepath.Join(tmpDir, dataName)); err != nil {
+			t.Errorf("Missing segment file: %v", err)
+		}
+		next = seg.Last + 1
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, logsFileName)); !errors.Is(err, os.ErrNotExist) {
+		t.Error("A new segmented store should not create logs.dat")
+	}
+
+	var zeroTag [32]byte
+	if _, err := VerifyFrom(records, 0, a0, zeroTag); err != nil {
+		t.Fatalf("V-chain across segments failed: %v", err)
+	}
+	var got []uint64
+	for r, err := range RecordRange(store, 3, 10) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r.Index)
+	}
+	if len(got) != 8 || got[0] != 3 || got[7] != 10 {
+		t.Errorf("RecordRange(3, 10) across segments yielded %v", got)
+	}
+	if err := NewSemiTrustedVerifier(store).VerifyFromAnchor(mustAnchor(t, store, 10)); err != nil {
+		t.Fatalf("VerifyFromAnchor across segments failed: %v", err)
+	}
+
+	// Sealed segments can move to cold storage and stay readable.
+	coldDir := filepath.Join(tmpDir, "cold")
+	if err := segStore.MoveSegment(1, coldDir); err != nil {
+		t.Fatalf("MoveSegment failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(coldDir, "logs-000001.dat")); err != nil {
+		t.Errorf("Segment not moved: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "logs-000001.dat")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Moved segment still in the store directory")
+	}
+	if got := readAllRecords(t, store); len(got) != 18 {
+		t.Errorf("Expected 18 records after move, got %d", len(got))
+	}
+	if err := segStore.MoveSegment(segs[len(segs)-1].Seq, coldDir); !errors.Is(err, ErrSegmentNotSealed) {
+		t.Errorf("Expected ErrSegmentNotSealed for the active segment, got: %v", err)
+	}
+	if err := store.(*fileStore).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The manifest keeps the store segmented; without limits it only rolls on demand.
+	store, err = OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+	segStore = store.(SegmentedStore)
+	logger.store = store
+	for i := 0; i < 4; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if segs, _ := segStore.Segments(); len(segs) != 5 {
+		t.Fatalf("Store without limits should not roll, got %d segments", len(segs))
+	}
+	if err := segStore.SealSegment(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	segs, _ = segStore.Segments()
+	if len(segs) != 6 || segs[5].First != 23 || segs[5].Last != 23 {
+		t.Fatalf("Unexpected segments after SealSegment: %+v", segs)
+	}
+
+	// Pruning drops whole segments and trims the one holding the prune point.
+	if err := store.(Pruner).Prune(10); err != nil {
+		t.Fatal(err)
+	}
+	segs, _ = segStore.Segments()
+	if segs[0].First != 10 {
+		t.Errorf("Expected first segment to start at 10, got %+v", segs[0])
+	}
+	if _, err := os.Stat(filepath.Join(coldDir, "logs-000001.dat")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Pruned segment in cold storage was not deleted")
+	}
+	records = readAllRecords(t, store)
+	if len(records) != 14 || records[0].Index != 10 {
+		t.Fatalf("Expected records 10..23 after prune, got %d from %d", len(records), records[0].Index)
+	}
+	if err := NewSemiTrustedVerifier(store).VerifyFromAnchor(mustAnchor(t, store, 10)); err != nil {
+		t.Fatalf("VerifyFromAnchor after prune failed: %v", err)
+	}
+}
+
+func TestSegmentedFileStore_Upgrade(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-segments-legacy-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, _ := logger.GetInitialKeys()
+	if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.(SegmentedStore).SealSegment(); !errors.Is(err, ErrNotSegmented) {
+		t.Errorf("Expected ErrNotSegmented, got: %v", err)
+	}
+	if err := store.(*fileStore).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// An existing logs.dat becomes segment 0; age limits roll every append.
+	store, err = OpenFileStoreWithOptions(tmpDir, FileStoreOptions{SegmentMaxAge: time.Nanosecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+	logger.store = store
+	for i := 0; i < 3; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	segs, err := store.(SegmentedStore).Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs) != 4 || segs[0].Seq != 0 || segs[0].Last != 1 || segs[3].First != 4 {
+		t.Fatalf("Unexpected segments: %+v", segs)
+	}
+	var zeroTag [32]byte
+	if _, err := VerifyFrom(readAllRecords(t, store), 0, a0, zeroTag); err != nil {
+		t.Fatalf("V-chain after upgrade failed: %v", err)
+	}
+}
+
+func mustAnchor(t *testing.T, store Store, idx uint64) Anchor {
+	t.Helper()
+	a, found, err := store.AnchorAt(idx)
+	if err != nil || !found {
+		t.Fatalf("AnchorAt(%d): found=%v err=%v", idx, found, err)
+	}
+	return a
+}
@@ -5,26 +5,39 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
+	"iter"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"syscall"
+	"time"
 )
 
 // fileStore implements Store using POSIX files with append-only semantics.
 // File format:
 //   - logs.dat: main log file with entries
 //   - anchors.idx: anchor index file
+//   - tree.dat: Merkle tree state, one fixed-size entry per record
+//   - logs.idx: offset index into logs.dat (see indexFileName)
+//   - times.idx: sparse time index over all records (see timesFileName)
+//
+// Segmented stores replace logs.dat and logs.idx with rolling segment files
+// (see OpenFileStoreWithOptions).
 //
 // Entry format in logs.dat:
 //
 //	[8]byte: index (uint64)
 //	[8]byte: timestamp (int64)
-//	[4]byte: msg length (uint32)
+//	[4]byte: msg length (uint32), including the codec byte
+//	[1]byte: msg codec (format version 3 and later; see compressMsg)
 //	[n]byte: msg data
 //	[32]byte: tagV (μ_V,i)
 //	[32]byte: tagT (μ_T,i)
+//	[4]byte: CRC32C of the above (format version 2 and later)
 //
 // Anchor format in anchors.idx:
 //
@@ -38,11 +51,34 @@ import (
 //	[8]byte: index (uint64)
 //	[32]byte: tagV
 //	[32]byte: tagT
+//
+// Tree entry format in tree.dat (entry i-1 belongs to record i):
+//
+//	[32]byte: leaf hash (see LeafHash)
+//	[32]byte: Merkle root over records 1..i
+//
+// The tree root of the tail and of each anchor is read from tree.dat, so the
+// other files keep their original layout.
+//
+// In stores of format version 1 and later, logs.dat, anchors.idx and tail.dat
+// start with a file header (see FileFormat) and the entries follow it.
 type fileStore struct {
 	dir        string
-	logFile    *os.File
+	opts       FileStoreOptions
+	format     FileFormat
+	hdrLen     int64         // size of the file headers: 0 for version 0, else fileHeaderSize
+	sumLen     int64         // size of the record checksums: 0 before version 2, else checksumSize
+	packed     bool          // messages carry a codec byte: format version 3 and later
+	segmented  bool          // records are spread over segment files listed in segments.gob
+	segments   []SegmentInfo // in order; the last one is active (a single segment 0 when not segmented)
+	logFile    *os.File      // data file of the active segment
+	indexFile  *os.File      // offset index of the active segment
 	anchorFile *os.File
 	tailFile   *os.File
+	treeFile   *os.File
+	timesFile  *os.File
+	indexStale bool // read-only store whose index does not match the log; reads scan it instead
+	compressed compressionCounter
 	mu         sync.RWMutex
 }
 
@@ -50,53 +86,163 @@ const (
 	logsFileName    = "logs.dat"
 	anchorsFileName = "anchors.idx"
 	tailFileName    = "tail.dat"
+	treeFileName    = "tree.dat"
+	pruneFileName   = "prune.journal"
 	headerSize      = 8 + 8 + 4        // idx + ts + msgLen
 	tagsSize        = 32 + 32          // tagV + tagT
 	anchorEntrySize = 8 + 32 + 32 + 32 // idx + key + tagV + tagT
 	tailEntrySize   = 8 + 32 + 32      // idx + tagV + tagT
+	treeEntrySize   = 32 + 32          // leaf + root
+	checksumSize    = 4                // CRC32C
 )
 
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
 // OpenFileStore creates or opens a POSIX file-based store in the given directory.
 func OpenFileStore(dir string) (Store, error) {
-	if err := os.MkdirAll(dir, 0700); err != nil {
+	return openFileStore(dir, FileStoreOptions{})
+}
+
+func openFileStore(dir string, opts FileStoreOptions) (*fileStore, error) {
+	if opts.ReadOnly {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("open store directory: %w", err)
+		}
+	} else if err := os.MkdirAll(dir, 0700); err != nil {
 		return nil, fmt.Errorf("create directory: %w", err)
 	}
 
-	logPath := filepath.Join(dir, logsFileName)
-	logFile, err := os.OpenFile(logPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	segments, segmented, err := loadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	created := false
+	if !segmented {
+		// Without a manifest the store is the single logs.dat, i.e. segment 0.
+		segments = []SegmentInfo{{Seq: 0, Created: time.Now()}}
+		if opts.segmented() {
+			segmented, created = true, true
+			if info, err := os.Stat(filepath.Join(dir, logsFileName)); err != nil || info.Size() == 0 {
+				segments[0].Seq = 1
+			}
+		}
+	}
+
+	format, err := detectFormat(formatPaths(dir, segments), opts.LogID)
 	if err != nil {
-		return nil, fmt.Errorf("open log file: %w", err)
+		return nil, err
 	}
 
 	anchorPath := filepath.Join(dir, anchorsFileName)
-	anchorFile, err := os.OpenFile(anchorPath, os.O_RDWR|os.O_CREATE, 0600)
+	anchorFile, err := opts.openFile(anchorPath, 0)
 	if err != nil {
-		_ = logFile.Close()
 		return nil, fmt.Errorf("open anchor file: %w", err)
 	}
 
 	tailPath := filepath.Join(dir, tailFileName)
-	tailFile, err := os.OpenFile(tailPath, os.O_RDWR|os.O_CREATE, 0600)
+	tailFile, err := opts.openFile(tailPath, 0)
 	if err != nil {
-		_ = logFile.Close()
 		_ = anchorFile.Close()
 		return nil, fmt.Errorf("open tail file: %w", err)
 	}
 
-	return &fileStore{
+	treePath := filepath.Join(dir, treeFileName)
+	treeFile, err := opts.openFile(treePath, 0)
+	if err != nil {
+		_ = anchorFile.Close()
+		_ = tailFile.Close()
+		return nil, fmt.Errorf("open tree file: %w", err)
+	}
+
+	timesFile, err := opts.openFile(filepath.Join(dir, timesFileName), 0)
+	if err != nil {
+		_ = anchorFile.Close()
+		_ = tailFile.Close()
+		_ = treeFile.Close()
+		return nil, fmt.Errorf("open time index: %w", err)
+	}
+
+	s := &fileStore{
 		dir:        dir,
-		logFile:    logFile,
+		opts:       opts,
+		format:     format,
+		segmented:  segmented,
+		segments:   segments,
 		anchorFile: anchorFile,
 		tailFile:   tailFile,
-	}, nil
+		treeFile:   treeFile,
+		timesFile:  timesFile,
+	}
+	if format.Version > 0 {
+		s.hdrLen = fileHeaderSize
+	}
+	if format.Version >= 2 {
+		s.sumLen = checksumSize
+	}
+	s.packed = format.Version >= 3
+	if opts.Compress && !opts.ReadOnly && !s.packed {
+		_ = anchorFile.Close()
+		_ = tailFile.Close()
+		_ = treeFile.Close()
+		_ = timesFile.Close()
+		return nil, fmt.Errorf("%w: compression needs format version 3 (run MigrateFileStore)", ErrUnsupportedFormat)
+	}
+	if !opts.ReadOnly {
+		err = s.prepareFileLocked(anchorFile, kindAnchors)
+		if err == nil {
+			err = s.prepareFileLocked(tailFile, kindTail)
+		}
+	}
+	if err == nil {
+		err = s.openActiveLocked()
+	}
+	if err == nil && !opts.ReadOnly {
+		err = s.recoverLocked()
+	}
+	if err == nil && !opts.ReadOnly {
+		err = s.resumePruneLocked()
+	}
+	if err != nil {
+		if s.logFile != nil {
+			_ = s.logFile.Close()
+			_ = s.indexFile.Close()
+		}
+		_ = anchorFile.Close()
+		_ = tailFile.Close()
+		_ = treeFile.Close()
+		_ = timesFile.Close()
+		return nil, err
+	}
+	if created {
+		if err := s.saveManifestLocked(segments); err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+	}
+	if !opts.ReadOnly {
+		if err := s.recoverTimes(); err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+	}
+	if err := checkOnOpen(s, opts.Check); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
 }
 
-// Append writes a record to the log file atomically.
+// Append writes a record to the log file atomically. The record write is the
+// commit point: tree and anchor entries are written before it and the tail
+// after it, so a crash at any point is repaired on open (see recoverLocked).
 func (s *fileStore) Append(r Record, tail TailState, anchor *Anchor) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	lastIdx, err := s.getLastIndexLocked()
+	if s.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	lastIdx, end, err := s.lastRecordLocked()
 	if err != nil {
 		return err
 	}
@@ -104,18 +250,37 @@ func (s *fileStore) Append(r Record, tail TailState, anchor *Anchor) error {
 	if lastIdx != r.Index-1 {
 		return fmt.Errorf("non-contiguous append: have %d, got %d", lastIdx, r.Index)
 	}
+	stored := r
+	stored.Msg = s.packMsg(r.Msg)
+
+	rolled, err := s.rollIfDueLocked(entrySize(len(stored.Msg), s.sumLen), end)
+	if err != nil {
+		return fmt.Errorf("roll segment: %w", err)
+	}
+	if rolled {
+		end = s.hdrLen
+	}
 
 	if err := syscall.Flock(int(s.logFile.Fd()), syscall.LOCK_EX); err != nil {
 		return fmt.Errorf("lock log file: %w", err)
 	}
 	defer syscall.Flock(int(s.logFile.Fd()), syscall.LOCK_UN)
 
-	if err := s.writeRecordLocked(r); err != nil {
+	info, err := s.logFile.Stat()
+	if err != nil {
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	if info.Size() != end {
+		return fmt.Errorf("log file has %d unindexed bytes after record %d", info.Size()-end, lastIdx)
+	}
+
+	// Drop tree and anchor entries left behind by an earlier failed append.
+	if err := s.trimBeyondLocked(lastIdx); err != nil {
 		return err
 	}
 
-	if err := s.logFile.Sync(); err != nil {
-		return fmt.Errorf("sync log file: %w", err)
+	if err := s.writeTreeLocked(r, tail); err != nil {
+		return err
 	}
 
 	if anchor != nil {
@@ -124,15 +289,90 @@ func (s *fileStore) Append(r Record, tail TailState, anchor *Anchor) error {
 		}
 	}
 
+	if err := s.writeRecordLocked(stored); err != nil {
+		_ = s.logFile.Truncate(end)
+		return err
+	}
+	if s.packed {
+		s.compressed.add(len(r.Msg), len(stored.Msg)-codecSize, stored.Msg[0])
+	}
+
+	if err := s.logFile.Sync(); err != nil {
+		return fmt.Errorf("sync log file: %w", err)
+	}
+
+	if err := s.appendIndexLocked(r.Index, end); err != nil {
+		return err
+	}
+
+	if err := s.writeTimeLocked(r); err != nil {
+		return err
+	}
+
 	return s.writeTailLocked(tail)
 }
 
 // writeRecordLocked writes a single record to the log file (caller must hold lock).
 func (s *fileStore) writeRecordLocked(r Record) error {
+	buf := encodeRecord(r, s.sumLen)
+
+	n, err := s.logFile.Write(buf)
+	if err != nil {
+		return fmt.Errorf("write record: %w", err)
+	}
+	if n != len(buf) {
+		return fmt.Errorf("incomplete write: %d of %d bytes", n, len(buf))
+	}
+
+	return nil
+}
+
+// codecSize is the size of the codec byte before every message in format
+// version 3 and later.
+const codecSize = 1
+
+// packMsg returns msg as stored: with a codec byte in stores of format
+// version 3 and later, and compressed if the store compresses and that saves
+// space.
+func (s *fileStore) packMsg(msg []byte) []byte {
+	if !s.packed {
+		return msg
+	}
+	codec, data := codecRaw, msg
+	if s.opts.Compress {
+		codec, data = compressMsg(msg)
+	}
+	return append([]byte{codec}, data...)
+}
+
+// unpackMsg returns the message stored as stored, undoing packMsg.
+func unpackMsg(stored []byte, packed bool) ([]byte, error) {
+	if !packed {
+		return stored, nil
+	}
+	if len(stored) == 0 {
+		return nil, fmt.Errorf("%w: message without codec byte", ErrStorageCorruption)
+	}
+	return decompressMsg(stored[0], stored[codecSize:])
+}
+
+// CompressionStats reports the messages appended since the store was opened.
+// Stores of format versions before 3 store every message as is.
+func (s *fileStore) CompressionStats() CompressionStats {
+	return s.compressed.get()
+}
+
+// entrySize returns the size of a logs.dat entry with a message of msgLen bytes.
+func entrySize(msgLen int, sumLen int64) int64 {
+	return int64(headerSize+msgLen+tagsSize) + sumLen
+}
+
+// encodeRecord serialises a record in the logs.dat entry format, with a
+// checksum if sumLen is not 0.
+func encodeRecord(r Record, sumLen int64) []byte {
 	msgLen := uint32(len(r.Msg))
-	totalSize := headerSize + int(msgLen) + tagsSize
 
-	buf := make([]byte, totalSize)
+	buf := make([]byte, entrySize(int(msgLen), sumLen))
 	offset := 0
 
 	binary.BigEndian.PutUint64(buf[offset:], r.Index)
@@ -151,15 +391,335 @@ func (s *fileStore) writeRecordLocked(r Record) error {
 	offset += 32
 
 	copy(buf[offset:], r.TagT[:])
+	offset += 32
 
-	n, err := s.logFile.Write(buf)
+	if sumLen > 0 {
+		binary.BigEndian.PutUint32(buf[offset:], crc32.Checksum(buf[:offset], castagnoli))
+	}
+
+	return buf
+}
+
+// errEntryBounds marks a logs.dat entry whose length field runs past the end
+// of its file: either the last entry was torn by a crash or the field is damaged.
+var errEntryBounds = fmt.Errorf("%w: entry runs past the end of the file", ErrStorageCorruption)
+
+// readRecord reads one logs.dat entry, of which avail bytes are left in the
+// file. It returns io.EOF only at a clean entry boundary. A message length
+// beyond avail is not read but reported with an error wrapping
+// errEntryBounds. If the entry's checksum does not match, the record is
+// returned together with an error wrapping ErrStorageCorruption.
+func readRecord(reader io.Reader, sumLen, avail int64) (Record, error) {
+	var hdr [headerSize]byte
+	if _, err := io.ReadFull(reader, hdr[:]); err != nil {
+		return Record{}, err
+	}
+	idx := binary.BigEndian.Uint64(hdr[0:8])
+	msgLen := binary.BigEndian.Uint32(hdr[16:20])
+	if size := entrySize(int(msgLen), sumLen); size > avail {
+		return Record{}, fmt.Errorf("%w: record %d needs %d bytes, %d left", errEntryBounds, idx, size, avail)
+	}
+	r := Record{
+		Index: idx,
+		TS:    int64(binary.BigEndian.Uint64(hdr[8:16])),
+		Msg:   make([]byte, msgLen),
+	}
+	if _, err := io.ReadFull(reader, r.Msg); err != nil {
+		return Record{}, noEOF(err)
+	}
+	if _, err := io.ReadFull(reader, r.TagV[:]); err != nil {
+		return Record{}, noEOF(err)
+	}
+	if _, err := io.ReadFull(reader, r.TagT[:]); err != nil {
+		return Record{}, noEOF(err)
+	}
+	if sumLen == 0 {
+		return r, nil
+	}
+
+	var sum [checksumSize]byte
+	if _, err := io.ReadFull(reader, sum[:]); err != nil {
+		return Record{}, noEOF(err)
+	}
+	crc := crc32.Update(0, castagnoli, hdr[:])
+	crc = crc32.Update(crc, castagnoli, r.Msg)
+	crc = crc32.Update(crc, castagnoli, r.TagV[:])
+	crc = crc32.Update(crc, castagnoli, r.TagT[:])
+	if binary.BigEndian.Uint32(sum[:]) != crc {
+		return r, fmt.Errorf("%w: checksum mismatch in record %d", ErrStorageCorruption, r.Index)
+	}
+	return r, nil
+}
+
+// noEOF turns io.EOF inside an entry into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ReplaceMsg replaces the stored message of record idx, keeping its index,
+// timestamp and tags. It is used for redaction.
+func (s *fileStore) ReplaceMsg(idx uint64, msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	found := false
+	err := s.rewriteSegmentLocked(s.segmentForLocked(idx), func(r *Record) bool {
+		if r.Index == idx {
+			r.Msg = s.packMsg(msg)
+			found = true
+		}
+		return true
+	})
 	if err != nil {
-		return fmt.Errorf("write record: %w", err)
+		return err
 	}
-	if n != len(buf) {
-		return fmt.Errorf("incomplete write: %d of %d bytes", n, len(buf))
+	if !found {
+		return fmt.Errorf("record %d not found", idx)
+	}
+	return nil
+}
+
+// Prune deletes records and anchors with index below beforeIndex. tree.dat is
+// kept, since leaf hashes carry no payload and are needed for proofs. Sealed
+// segments that lie entirely before beforeIndex are deleted as a whole.
+//
+// Pruning touches several files, so the prune point is journaled first and
+// the journal removed once every file is rewritten. Each step can be
+// repeated, and opening the store completes a prune a crash interrupted.
+func (s *fileStore) Prune(beforeIndex uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	journal := filepath.Join(s.dir, pruneFileName)
+	err := replaceFile(s.dir, journal, func(w io.Writer) error {
+		return binary.Write(w, binary.BigEndian, beforeIndex)
+	})
+	if err != nil {
+		return fmt.Errorf("journal prune: %w", err)
+	}
+	if err := s.pruneLocked(beforeIndex); err != nil {
+		return err
+	}
+	return s.clearPruneJournalLocked()
+}
+
+// resumePruneLocked completes a prune left unfinished by a crash.
+func (s *fileStore) resumePruneLocked() error {
+	buf, err := os.ReadFile(filepath.Join(s.dir, pruneFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read prune journal: %w", err)
+	}
+	if len(buf) != 8 {
+		return fmt.Errorf("%w: prune journal holds %d bytes", ErrStorageCorruption, len(buf))
+	}
+	if err := s.pruneLocked(binary.BigEndian.Uint64(buf)); err != nil {
+		return fmt.Errorf("resume prune: %w", err)
+	}
+	return s.clearPruneJournalLocked()
+}
+
+func (s *fileStore) clearPruneJournalLocked() error {
+	if err := os.Remove(filepath.Join(s.dir, pruneFileName)); err != nil {
+		return fmt.Errorf("remove prune journal: %w", err)
+	}
+	return syncDir(s.dir)
+}
+
+// pruneLocked drops everything before beforeIndex. It is idempotent.
+func (s *fileStore) pruneLocked(beforeIndex uint64) error {
+	i := s.segmentForLocked(beforeIndex)
+	if err := s.rewriteSegmentLocked(i, func(r *Record) bool { return r.Index >= beforeIndex }); err != nil {
+		return err
+	}
+	dropped := s.segments[:i]
+	segs := slices.Clone(s.segments[i:])
+	if segs[0].Sealed {
+		segs[0].First = beforeIndex
+	}
+	if err := s.saveManifestLocked(segs); err != nil {
+		return err
+	}
+	for _, seg := range dropped {
+		dataPath, indexPath := s.segmentPaths(seg)
+		_ = os.Remove(dataPath)
+		_ = os.Remove(indexPath)
+	}
+	return s.rewriteAnchorsLocked(func(a Anchor) bool { return a.Index >= beforeIndex })
+}
+
+// rewriteSegmentLocked rewrites the data file of segment i through keep, which
+// may modify a record in place and reports whether to retain it, and rebuilds
+// its index. Entries are variable-length, so the result is written to a
+// temporary file and renamed into place.
+func (s *fileStore) rewriteSegmentLocked(i int, keep func(r *Record) bool) error {
+	active := i == len(s.segments)-1
+	locked := false
+	lockFd := int(s.logFile.Fd())
+	if active {
+		if err := syscall.Flock(lockFd, syscall.LOCK_EX); err != nil {
+			return fmt.Errorf("lock log file: %w", err)
+		}
+		locked = true
+	}
+	defer func() {
+		if locked {
+			_ = syscall.Flock(lockFd, syscall.LOCK_UN)
+		}
+	}()
+
+	logPath, indexPath := s.segmentPaths(s.segments[i])
+	dir := filepath.Dir(logPath)
+	src, err := os.Open(logPath)
+	if err != nil {
+		return fmt.Errorf("open log file for reading: %w", err)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	if _, err := src.Seek(s.hdrLen, io.SeekStart); err != nil {
+		return fmt.Errorf("seek log file: %w", err)
 	}
 
+	reader := bufio.NewReader(src)
+	avail := info.Size() - s.hdrLen
+	var index []byte
+	err = replaceFile(dir, logPath, func(w io.Writer) error {
+		if _, err := w.Write(s.fileHeader(kindRecords)); err != nil {
+			return fmt.Errorf("write log file header: %w", err)
+		}
+		off := s.hdrLen
+		for {
+			// A corrupt record is not rewritten: that would give it a
+			// fresh checksum and hide the corruption.
+			r, err := readRecord(reader, s.sumLen, avail)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("read record: %w", err)
+			}
+			avail -= entrySize(len(r.Msg), s.sumLen)
+			if !keep(&r) {
+				continue
+			}
+			buf := encodeRecord(r, s.sumLen)
+			if _, err := w.Write(buf); err != nil {
+				return fmt.Errorf("write record: %w", err)
+			}
+			index = append(index, encodeIndexEntry(r.Index, off)...)
+			off += int64(len(buf))
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Offsets have changed. Should this fail, OpenFileStore rebuilds the
+	// index of the active segment.
+	err = replaceFile(dir, indexPath, func(w io.Writer) error {
+		_, err := w.Write(index)
+		return err
+	})
+	if err != nil || !active {
+		return err
+	}
+
+	oldLog, oldIndex := s.logFile, s.indexFile
+	if err := s.openActiveLocked(); err != nil {
+		return err
+	}
+	locked = false
+	_ = syscall.Flock(lockFd, syscall.LOCK_UN)
+	_ = oldLog.Close()
+	_ = oldIndex.Close()
+	return nil
+}
+
+// rewriteAnchorsLocked rewrites anchors.idx keeping only anchors accepted by keep.
+func (s *fileStore) rewriteAnchorsLocked(keep func(Anchor) bool) error {
+	anchors, err := s.listAnchorsLocked()
+	if err != nil {
+		return err
+	}
+
+	anchorPath := filepath.Join(s.dir, anchorsFileName)
+	err = replaceFile(s.dir, anchorPath, func(w io.Writer) error {
+		if _, err := w.Write(s.fileHeader(kindAnchors)); err != nil {
+			return fmt.Errorf("write anchor file header: %w", err)
+		}
+		for _, a := range anchors {
+			if !keep(a) {
+				continue
+			}
+			if _, err := w.Write(encodeAnchor(a)); err != nil {
+				return fmt.Errorf("write anchor: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	anchorFile, err := os.OpenFile(anchorPath, os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("reopen anchor file: %w", err)
+	}
+	_ = s.anchorFile.Close()
+	s.anchorFile = anchorFile
+	return nil
+}
+
+// replaceFile atomically replaces path with the content produced by fill.
+func replaceFile(dir, path string, fill func(io.Writer) error) error {
+	tmpPath := path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+	defer os.Remove(tmpPath)
+	defer tmp.Close()
+
+	writer := bufio.NewWriter(tmp)
+	if err := fill(writer); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("flush temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("sync temporary file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("replace %s: %w", filepath.Base(path), err)
+	}
+	return syncDir(dir)
+}
+
+// syncDir fsyncs a directory so a rename within it is durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("open directory: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync directory: %w", err)
+	}
 	return nil
 }
 
@@ -170,19 +730,7 @@ func (s *fileStore) writeAnchorLocked(a Anchor) error {
 	}
 	defer syscall.Flock(int(s.anchorFile.Fd()), syscall.LOCK_UN)
 
-	buf := make([]byte, anchorEntrySize)
-	offset := 0
-
-	binary.BigEndian.PutUint64(buf[offset:], a.Index)
-	offset += 8
-
-	copy(buf[offset:], a.Key[:])
-	offset += 32
-
-	copy(buf[offset:], a.TagV[:])
-	offset += 32
-
-	copy(buf[offset:], a.TagT[:])
+	buf := encodeAnchor(a)
 
 	if _, err := s.anchorFile.Seek(0, io.SeekEnd); err != nil {
 		return fmt.Errorf("seek anchor file: %w", err)
@@ -199,136 +747,179 @@ func (s *fileStore) writeAnchorLocked(a Anchor) error {
 	return nil
 }
 
-// getLastIndexLocked returns the index of the last record (0 if empty).
-func (s *fileStore) getLastIndexLocked() (uint64, error) {
-	info, err := s.logFile.Stat()
-	if err != nil {
-		return 0, fmt.Errorf("stat log file: %w", err)
-	}
+// encodeAnchor serialises an anchor in the anchors.idx entry format.
+func encodeAnchor(a Anchor) []byte {
+	buf := make([]byte, anchorEntrySize)
+	offset := 0
 
-	if info.Size() == 0 {
-		return 0, nil
-	}
+	binary.BigEndian.PutUint64(buf[offset:], a.Index)
+	offset += 8
 
-	// Seek to beginning and read all records to find last index
-	// TODO: This is inefficient but simple; could be optimized with index
-	if _, err := s.logFile.Seek(0, io.SeekStart); err != nil {
-		return 0, fmt.Errorf("seek to start: %w", err)
-	}
+	copy(buf[offset:], a.Key[:])
+	offset += 32
 
-	reader := bufio.NewReader(s.logFile)
-	var lastIdx uint64
+	copy(buf[offset:], a.TagV[:])
+	offset += 32
 
-	for {
-		var idxBuf [8]byte
-		if _, err := io.ReadFull(reader, idxBuf[:]); err != nil {
-			if err == io.EOF {
-				break
-			}
-			return 0, fmt.Errorf("read index: %w", err)
-		}
-		lastIdx = binary.BigEndian.Uint64(idxBuf[:])
+	copy(buf[offset:], a.TagT[:])
 
-		if _, err := io.CopyN(io.Discard, reader, 8); err != nil {
-			return 0, fmt.Errorf("skip timestamp: %w", err)
-		}
+	return buf
+}
 
-		var lenBuf [4]byte
-		if _, err := io.ReadFull(reader, lenBuf[:]); err != nil {
-			return 0, fmt.Errorf("read msg length: %w", err)
-		}
-		msgLen := binary.BigEndian.Uint32(lenBuf[:])
+// decodeAnchor parses an anchors.idx entry. The tree root is not part of it.
+func decodeAnchor(buf []byte) Anchor {
+	var a Anchor
+	a.Index = binary.BigEndian.Uint64(buf[0:8])
+	copy(a.Key[:], buf[8:40])
+	copy(a.TagV[:], buf[40:72])
+	copy(a.TagT[:], buf[72:104])
+	return a
+}
+
+// Iter returns a channel that yields records starting from startIdx.
+func (s *fileStore) Iter(startIdx uint64) (<-chan Record, func() error, error) {
+	out, done := seqChan(s.All(startIdx))
+	return out, done, nil
+}
+
+// All yields the records with Index >= start.
+func (s *fileStore) All(start uint64) iter.Seq2[Record, error] {
+	return s.Range(start, math.MaxUint64)
+}
+
+// Range yields the records with start <= Index <= end, moving on through the
+// segments as needed.
+func (s *fileStore) Range(start, end uint64) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		s.mu.RLock()
+		segs := slices.Clone(s.segments[s.segmentForLocked(start):])
+		s.mu.RUnlock()
 
-		skipSize := int64(msgLen) + tagsSize
-		if _, err := io.CopyN(io.Discard, reader, skipSize); err != nil {
-			return 0, fmt.Errorf("skip msg and tags: %w", err)
+		for _, seg := range segs {
+			if seg.Sealed && seg.First > end {
+				return
+			}
+			file, off, size, ok, err := s.openSegmentForReading(seg.Seq, start)
+			if err != nil {
+				yield(Record{}, err)
+				return
+			}
+			if !ok {
+				continue
+			}
+			for r, err := range readRecords(file, off, size, s.sumLen, s.packed, start, end) {
+				if !yield(r, err) || err != nil {
+					return
+				}
+			}
 		}
 	}
-
-	return lastIdx, nil
 }
 
-// Iter returns a channel that yields records starting from startIdx.
-func (s *fileStore) Iter(startIdx uint64) (<-chan Record, func() error, error) {
+// openSegmentForReading opens a separate read handle on the data file of
+// segment seq, so readers keep a consistent view even if it is rewritten or
+// moved while they run, and returns the offset of the first record with
+// Index >= start and the size of the file when it was opened; records
+// appended later are not read. ok is false if the segment holds no such
+// record or no longer exists.
+func (s *fileStore) openSegmentForReading(seq, start uint64) (file *os.File, off, size int64, ok bool, err error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	logPath := filepath.Join(s.dir, logsFileName)
-	file, err := os.Open(logPath)
+	i := slices.IndexFunc(s.segments, func(seg SegmentInfo) bool { return seg.Seq == seq })
+	if i < 0 {
+		return nil, 0, 0, false, nil
+	}
+	seg := s.segments[i]
+	dataPath, indexPath := s.segmentPaths(seg)
+
+	off = s.hdrLen
+	switch {
+	case i == len(s.segments)-1:
+		off, err = s.seekLocked(start)
+	case start > seg.Last:
+		return nil, 0, 0, false, nil
+	case start > seg.First:
+		off, err = seekSealed(indexPath, start)
+	}
+	if err != nil {
+		return nil, 0, 0, false, err
+	}
+
+	file, err = os.Open(dataPath)
+	if err != nil {
+		return nil, 0, 0, false, fmt.Errorf("open log file for reading: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, 0, 0, false, fmt.Errorf("stat log file: %w", err)
+	}
+	return file, off, info.Size(), true, nil
+}
+
+// seekSealed returns the offset of record start in a sealed segment, which
+// holds it, using the segment's index file.
+func seekSealed(indexPath string, start uint64) (int64, error) {
+	f, err := os.Open(indexPath)
 	if err != nil {
-		return nil, nil, fmt.Errorf("open log file for reading: %w", err)
+		return 0, fmt.Errorf("open index file: %w", err)
 	}
+	defer f.Close()
 
-	out := make(chan Record, 64)
-	done := make(chan struct{})
+	pos, n, err := searchEntries(f, 0, indexEntrySize, start)
+	if err != nil {
+		return 0, err
+	}
+	if pos == n {
+		return 0, fmt.Errorf("index of %s does not cover record %d", filepath.Base(indexPath), start)
+	}
+	var buf [8]byte
+	if _, err := f.ReadAt(buf[:], pos*indexEntrySize+8); err != nil {
+		return 0, fmt.Errorf("read index entry: %w", err)
+	}
+	return int64(binary.BigEndian.Uint64(buf[:])), nil
+}
 
-	go func() {
-		defer close(out)
+// readRecords yields the records in the first size bytes of file from offset
+// on with start <= Index <= end, unpacking their messages if packed, and
+// closes file when iteration ends.
+func readRecords(file *os.File, offset, size, sumLen int64, packed bool, start, end uint64) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
 		defer file.Close()
 
-		reader := bufio.NewReader(file)
+		reader := bufio.NewReader(io.NewSectionReader(file, offset, max(size-offset, 0)))
+		var last uint64
 
 		for {
-			select {
-			case <-done:
+			r, err := readRecord(reader, sumLen, size-offset)
+			if err == io.EOF {
 				return
-			default:
-			}
-
-			var idxBuf [8]byte
-			if _, err := io.ReadFull(reader, idxBuf[:]); err != nil {
-				if err == io.EOF {
-					return
-				}
-				return // Error reading
 			}
-			idx := binary.BigEndian.Uint64(idxBuf[:])
-
-			var tsBuf [8]byte
-			if _, err := io.ReadFull(reader, tsBuf[:]); err != nil {
+			if err != nil {
+				yield(Record{}, &StoreReadError{Offset: offset, Index: last, Err: fmt.Errorf("%s: %w", filepath.Base(file.Name()), err)})
 				return
 			}
-			ts := int64(binary.BigEndian.Uint64(tsBuf[:]))
+			at := offset
+			offset += entrySize(len(r.Msg), sumLen)
+			prev := last
+			last = r.Index
 
-			var lenBuf [4]byte
-			if _, err := io.ReadFull(reader, lenBuf[:]); err != nil {
-				return
+			if r.Index < start {
+				continue
 			}
-			msgLen := binary.BigEndian.Uint32(lenBuf[:])
-
-			msg := make([]byte, msgLen)
-			if _, err := io.ReadFull(reader, msg); err != nil {
+			if r.Index > end {
 				return
 			}
-
-			var tagV [32]byte
-			if _, err := io.ReadFull(reader, tagV[:]); err != nil {
+			if r.Msg, err = unpackMsg(r.Msg, packed); err != nil {
+				yield(Record{}, &StoreReadError{Offset: at, Index: prev, Err: fmt.Errorf("%s: record %d: %w", filepath.Base(file.Name()), r.Index, err)})
 				return
 			}
-
-			var tagT [32]byte
-			if _, err := io.ReadFull(reader, tagT[:]); err != nil {
+			if !yield(r, nil) {
 				return
 			}
-
-			if idx >= startIdx {
-				out <- Record{
-					Index: idx,
-					TS:    ts,
-					Msg:   msg,
-					TagV:  tagV,
-					TagT:  tagT,
-				}
-			}
 		}
-	}()
-
-	cleanup := func() error {
-		close(done)
-		return nil
 	}
-
-	return out, cleanup, nil
 }
 
 // AnchorAt retrieves the anchor at index i.
@@ -340,43 +931,44 @@ func (s *fileStore) AnchorAt(i uint64) (Anchor, bool, error) {
 	return a, found, err
 }
 
-// readAnchorLocked searches for an anchor with the given index.
+// readAnchorLocked binary-searches anchors.idx, which is ordered by index,
+// for the anchor with the given index.
 func (s *fileStore) readAnchorLocked(targetIdx uint64) (Anchor, bool, error) {
 	var zero Anchor
 
-	if _, err := s.anchorFile.Seek(0, io.SeekStart); err != nil {
-		return zero, false, fmt.Errorf("seek anchor file: %w", err)
+	pos, n, err := searchEntries(s.anchorFile, s.hdrLen, anchorEntrySize, targetIdx)
+	if err != nil {
+		return zero, false, err
+	}
+	if pos == n {
+		return zero, false, nil // Not found
 	}
 
-	reader := bufio.NewReader(s.anchorFile)
-
-	for {
-		buf := make([]byte, anchorEntrySize)
-		if _, err := io.ReadFull(reader, buf); err != nil {
-			if err == io.EOF {
-				return zero, false, nil // Not found
-			}
-			return zero, false, fmt.Errorf("read anchor: %w", err)
-		}
-
-		idx := binary.BigEndian.Uint64(buf[0:8])
-		if idx == targetIdx {
-			var anchor Anchor
-			anchor.Index = idx
-			copy(anchor.Key[:], buf[8:40])
-			copy(anchor.TagV[:], buf[40:72])
-			copy(anchor.TagT[:], buf[72:104])
-			return anchor, true, nil
-		}
+	buf := make([]byte, anchorEntrySize)
+	if _, err := s.anchorFile.ReadAt(buf, s.hdrLen+pos*anchorEntrySize); err != nil {
+		return zero, false, fmt.Errorf("read anchor: %w", err)
+	}
+	anchor := decodeAnchor(buf)
+	if anchor.Index != targetIdx {
+		return zero, false, nil // Not found
 	}
+	root, _, err := s.readTreeRootLocked(anchor.Index)
+	if err != nil {
+		return zero, false, err
+	}
+	anchor.TreeRoot = root
+	return anchor, true, nil
 }
 
 // ListAnchors returns all anchors in the store.
 func (s *fileStore) ListAnchors() ([]Anchor, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return s.listAnchorsLocked()
+}
 
-	if _, err := s.anchorFile.Seek(0, io.SeekStart); err != nil {
+func (s *fileStore) listAnchorsLocked() ([]Anchor, error) {
+	if _, err := s.anchorFile.Seek(s.hdrLen, io.SeekStart); err != nil {
 		return nil, fmt.Errorf("seek anchor file: %w", err)
 	}
 
@@ -392,12 +984,12 @@ func (s *fileStore) ListAnchors() ([]Anchor, error) {
 			return nil, fmt.Errorf("read anchor: %w", err)
 		}
 
-		idx := binary.BigEndian.Uint64(buf[0:8])
-		var anchor Anchor
-		anchor.Index = idx
-		copy(anchor.Key[:], buf[8:40])
-		copy(anchor.TagV[:], buf[40:72])
-		copy(anchor.TagT[:], buf[72:104])
+		anchor := decodeAnchor(buf)
+		root, _, err := s.readTreeRootLocked(anchor.Index)
+		if err != nil {
+			return nil, err
+		}
+		anchor.TreeRoot = root
 
 		anchors = append(anchors, anchor)
 	}
@@ -414,7 +1006,7 @@ func (s *fileStore) Tail() (TailState, bool, error) {
 
 func (s *fileStore) readTailLocked() (TailState, bool, error) {
 	var tail TailState
-	if _, err := s.tailFile.Seek(0, io.SeekStart); err != nil {
+	if _, err := s.tailFile.Seek(s.hdrLen, io.SeekStart); err != nil {
 		return tail, false, fmt.Errorf("seek tail file: %w", err)
 	}
 	buf := make([]byte, tailEntrySize)
@@ -427,21 +1019,23 @@ func (s *fileStore) readTailLocked() (TailState, bool, error) {
 	tail.Index = binary.BigEndian.Uint64(buf[0:8])
 	copy(tail.TagV[:], buf[8:40])
 	copy(tail.TagT[:], buf[40:72])
+	entry, err := s.readTreeEntryLocked(tail.Index)
+	if err != nil {
+		return tail, false, err
+	}
+	copy(tail.Leaf[:], entry[0:32])
+	copy(tail.TreeRoot[:], entry[32:64])
 	return tail, true, nil
 }
 
+// writeTailLocked overwrites tail.dat in place. It is never truncated, so a
+// crash leaves at worst a stale or torn entry, which recoverLocked rewrites.
 func (s *fileStore) writeTailLocked(tail TailState) error {
-	if err := s.tailFile.Truncate(0); err != nil {
-		return fmt.Errorf("truncate tail file: %w", err)
-	}
-	if _, err := s.tailFile.Seek(0, io.SeekStart); err != nil {
-		return fmt.Errorf("seek tail file: %w", err)
-	}
 	buf := make([]byte, tailEntrySize)
 	binary.BigEndian.PutUint64(buf[0:8], tail.Index)
 	copy(buf[8:40], tail.TagV[:])
 	copy(buf[40:72], tail.TagT[:])
-	if _, err := s.tailFile.Write(buf); err != nil {
+	if _, err := s.tailFile.WriteAt(buf, s.hdrLen); err != nil {
 		return fmt.Errorf("write tail: %w", err)
 	}
 	if err := s.tailFile.Sync(); err != nil {
@@ -450,6 +1044,97 @@ func (s *fileStore) writeTailLocked(tail TailState) error {
 	return nil
 }
 
+// writeTreeLocked records r's leaf hash and the tree root after r, both
+// taken from tail. Stores created before tree.dat existed have no entries
+// for their earlier records; tree state is then not kept for that store
+// rather than left with holes.
+func (s *fileStore) writeTreeLocked(r Record, tail TailState) error {
+	info, err := s.treeFile.Stat()
+	if err != nil {
+		return fmt.Errorf("stat tree file: %w", err)
+	}
+	off := int64(r.Index-1) * treeEntrySize
+	if info.Size() != off {
+		return nil
+	}
+
+	buf := make([]byte, treeEntrySize)
+	leaf := appendedLeaf(r, tail)
+	copy(buf[0:32], leaf[:])
+	copy(buf[32:64], tail.TreeRoot[:])
+	if _, err := s.treeFile.WriteAt(buf, off); err != nil {
+		return fmt.Errorf("write tree entry: %w", err)
+	}
+	if err := s.treeFile.Sync(); err != nil {
+		return fmt.Errorf("sync tree file: %w", err)
+	}
+	return nil
+}
+
+// readTreeRootLocked returns the tree root stored for record idx, if any.
+func (s *fileStore) readTreeRootLocked(idx uint64) ([32]byte, bool, error) {
+	var root [32]byte
+	if idx == 0 {
+		return root, false, nil
+	}
+	_, err := s.treeFile.ReadAt(root[:], int64(idx-1)*treeEntrySize+32)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return [32]byte{}, false, nil
+	}
+	if err != nil {
+		return root, false, fmt.Errorf("read tree root: %w", err)
+	}
+	return root, true, nil
+}
+
+// readTreeEntryLocked returns the tree entry stored for record idx, or a
+// zero entry if there is none.
+func (s *fileStore) readTreeEntryLocked(idx uint64) ([treeEntrySize]byte, error) {
+	var entry [treeEntrySize]byte
+	if idx == 0 {
+		return entry, nil
+	}
+	_, err := s.treeFile.ReadAt(entry[:], int64(idx-1)*treeEntrySize)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return [treeEntrySize]byte{}, nil
+	}
+	if err != nil {
+		return entry, fmt.Errorf("read tree entry: %w", err)
+	}
+	return entry, nil
+}
+
+// LeafHashes returns the stored Merkle leaf hashes for records in [from, to].
+// The result stops early where tree.dat does not cover the range.
+func (s *fileStore) LeafHashes(from, to uint64) ([][32]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if from == 0 || to < from {
+		return nil, nil
+	}
+	info, err := s.treeFile.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat tree file: %w", err)
+	}
+	if stored := uint64(info.Size() / treeEntrySize); to > stored {
+		to = stored
+	}
+	if to < from {
+		return nil, nil
+	}
+
+	buf := make([]byte, (to-from+1)*treeEntrySize)
+	if _, err := s.treeFile.ReadAt(buf, int64(from-1)*treeEntrySize); err != nil {
+		return nil, fmt.Errorf("read tree entries: %w", err)
+	}
+	leaves := make([][32]byte, to-from+1)
+	for i := range leaves {
+		copy(leaves[i][:], buf[i*treeEntrySize:])
+	}
+	return leaves, nil
+}
+
 // Close closes the file store.
 func (s *fileStore) Close() error {
 	s.mu.Lock()
@@ -469,6 +1154,18 @@ func (s *fileStore) Close() error {
 		errs = append(errs, fmt.Errorf("close tail file: %w", err))
 	}
 
+	if err := s.treeFile.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close tree file: %w", err))
+	}
+
+	if err := s.indexFile.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close index file: %w", err))
+	}
+
+	if err := s.timesFile.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close time index: %w", err))
+	}
+
 	if len(errs) > 0 {
 		return errors.Join(errs...)
 	}
@@ -0,0 +1,186 @@
+package securelog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"time"
+)
+
+// times.idx is a sparse time index over the whole store: one entry per block
+// of timeBlockSize consecutive records, holding the smallest and largest
+// timestamp in the block, so a time-range query only reads the blocks that can
+// hold a match. Timestamps need not be monotonic.
+//
+//	[8]byte: smallest timestamp (int64)
+//	[8]byte: largest timestamp (int64)
+//
+// Entry b covers records b*timeBlockSize+1 .. (b+1)*timeBlockSize; an entry
+// with smallest > largest covers no records, e.g. after pruning. Like logs.idx
+// it is derived from the log: it is not synced on append and OpenFileStore
+// brings it up to date. Queries scan the blocks it does not cover yet.
+const (
+	timesFileName = "times.idx"
+	timeEntrySize = 8 + 8 // smallest + largest timestamp
+	timeBlockSize = 64
+)
+
+type timeEntry struct{ lo, hi int64 }
+
+var emptyTimeEntry = timeEntry{lo: math.MaxInt64, hi: math.MinInt64}
+
+func (e timeEntry) add(ts int64) timeEntry {
+	return timeEntry{lo: min(e.lo, ts), hi: max(e.hi, ts)}
+}
+
+func (e timeEntry) encode() []byte {
+	buf := make([]byte, timeEntrySize)
+	binary.BigEndian.PutUint64(buf[0:8], uint64(e.lo))
+	binary.BigEndian.PutUint64(buf[8:16], uint64(e.hi))
+	return buf
+}
+
+func decodeTimeEntry(buf []byte) timeEntry {
+	return timeEntry{
+		lo: int64(binary.BigEndian.Uint64(buf[0:8])),
+		hi: int64(binary.BigEndian.Uint64(buf[8:16])),
+	}
+}
+
+// writeTimeLocked widens the entry of r's block to r.TS. An index that lags
+// behind the log is left alone until the store is next opened.
+func (s *fileStore) writeTimeLocked(r Record) error {
+	info, err := s.timesFile.Stat()
+	if err != nil {
+		return fmt.Errorf("stat time index: %w", err)
+	}
+	off := int64((r.Index-1)/timeBlockSize) * timeEntrySize
+	e := emptyTimeEntry
+	switch info.Size() {
+	case off:
+	case off + timeEntrySize:
+		buf := make([]byte, timeEntrySize)
+		if _, err := s.timesFile.ReadAt(buf, off); err != nil {
+			return fmt.Errorf("read time index: %w", err)
+		}
+		e = decodeTimeEntry(buf)
+	default:
+		return nil
+	}
+	if _, err := s.timesFile.WriteAt(e.add(r.TS).encode(), off); err != nil {
+		return fmt.Errorf("write time index: %w", err)
+	}
+	return nil
+}
+
+// readTimesLocked returns the entries of times.idx.
+func (s *fileStore) readTimesLocked() ([]timeEntry, error) {
+	info, err := s.timesFile.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat time index: %w", err)
+	}
+	buf := make([]byte, info.Size()/timeEntrySize*timeEntrySize)
+	if _, err := s.timesFile.ReadAt(buf, 0); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("read time index: %w", err)
+	}
+	entries := make([]timeEntry, 0, len(buf)/timeEntrySize)
+	for off := 0; off < len(buf); off += timeEntrySize {
+		entries = append(entries, decodeTimeEntry(buf[off:]))
+	}
+	return entries, nil
+}
+
+// recoverTimes brings times.idx up to date with the log. The last entry is
+// always recomputed, since a crash can leave it behind its block, and missing
+// ones are added. A record that cannot be read ends the rebuild; queries scan
+// the blocks left uncovered. It runs on open, before the store is shared.
+func (s *fileStore) recoverTimes() error {
+	last, _, err := s.lastRecordLocked()
+	if err != nil {
+		return err
+	}
+	blocks := int64((last + timeBlockSize - 1) / timeBlockSize)
+	info, err := s.timesFile.Stat()
+	if err != nil {
+		return fmt.Errorf("stat time index: %w", err)
+	}
+	n := min(info.Size()/timeEntrySize, blocks)
+	from := max(n-1, 0)
+	if err := s.timesFile.Truncate(from * timeEntrySize); err != nil {
+		return fmt.Errorf("truncate time index: %w", err)
+	}
+	if from == blocks {
+		return nil
+	}
+
+	var buf []byte
+	block, e := from, emptyTimeEntry
+	for r, err := range s.Range(uint64(from)*timeBlockSize+1, last) {
+		if err != nil {
+			break
+		}
+		for b := (int64(r.Index) - 1) / timeBlockSize; block < b; block++ {
+			buf = append(buf, e.encode()...)
+			e = emptyTimeEntry
+		}
+		e = e.add(r.TS)
+		if r.Index == last {
+			buf = append(buf, e.encode()...)
+		}
+	}
+	if _, err := s.timesFile.WriteAt(buf, from*timeEntrySize); err != nil {
+		return fmt.Errorf("write time index: %w", err)
+	}
+	if err := s.timesFile.Sync(); err != nil {
+		return fmt.Errorf("sync time index: %w", err)
+	}
+	return nil
+}
+
+// IterTime yields the records with from <= TS < to in index order, reading
+// only the blocks of records that times.idx does not rule out.
+func (s *fileStore) IterTime(from, to time.Time) iter.Seq2[Record, error] {
+	lo, hi := from.UnixNano(), to.UnixNano()
+	return func(yield func(Record, error) bool) {
+		s.mu.RLock()
+		entries, err := s.readTimesLocked()
+		s.mu.RUnlock()
+		if err != nil {
+			yield(Record{}, err)
+			return
+		}
+
+		// The last entry may lag behind the log, and records after it have
+		// none, so everything from its block on is scanned.
+		var spans [][2]uint64
+		add := func(first, last uint64) {
+			if n := len(spans); n > 0 && spans[n-1][1]+1 == first {
+				spans[n-1][1] = last
+				return
+			}
+			spans = append(spans, [2]uint64{first, last})
+		}
+		tail := max(len(entries)-1, 0)
+		for b, e := range entries[:tail] {
+			if e.hi >= lo && e.lo < hi {
+				add(uint64(b)*timeBlockSize+1, uint64(b+1)*timeBlockSize)
+			}
+		}
+		add(uint64(tail)*timeBlockSize+1, math.MaxUint64)
+
+		for _, span := range spans {
+			for r, err := range s.Range(span[0], span[1]) {
+				if err != nil {
+					yield(Record{}, err)
+					return
+				}
+				if r.TS >= lo && r.TS < hi && !yield(r, nil) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package securelog
 
 import (
+	"bytes"
+	"errors"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -244,3 +248,503 @@ func TestFileStore_CustomInitialKeys(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestFileStore_IterReadError(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-iter-err-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	logger, err := New(Config{AnchorEvery: 5}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Stopping early is not an error and does not block.
+	ch, done, err := store.Iter(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+	if err := done(); err != nil {
+		t.Errorf("Expected nil from done after stopping early, got: %v", err)
+	}
+
+	// Cut the last record in half.
+	entrySize := int64(headerSize + codecSize + len("entry") + tagsSize + checksumSize)
+	logPath := filepath.Join(tmpDir, logsFileName)
+	if err := os.Truncate(logPath, fileHeaderSize+9*entrySize+entrySize/2); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, done, err = store.Iter(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for range ch {
+		count++
+	}
+	err = done()
+	if count != 9 {
+		t.Errorf("Expected 9 readable records, got %d", count)
+	}
+	var readErr *StoreReadError
+	if !errors.As(err, &readErr) {
+		t.Fatalf("Expected *StoreReadError, got: %v", err)
+	}
+	// The cut record's length runs past the end of the file.
+	if readErr.Offset != fileHeaderSize+9*entrySize || readErr.Index != 9 || !errors.Is(err, ErrStorageCorruption) {
+		t.Errorf("Unexpected read error: %+v", readErr)
+	}
+
+	// Verifiers report the storage fault rather than a tag mismatch.
+	anchor, found, err := store.AnchorAt(5)
+	if err != nil || !found {
+		t.Fatalf("AnchorAt(5): found=%v err=%v", found, err)
+	}
+	err = NewSemiTrustedVerifier(store).VerifyFromAnchor(anchor)
+	if !errors.As(err, &readErr) || errors.Is(err, ErrTagMismatch) {
+		t.Errorf("Expected store read error from verifier, got: %v", err)
+	}
+}
+
+func TestFileStore_LengthOutOfBounds(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-length-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A damaged length field in record 4 claims 4 GiB; it is reported
+	// without allocating or reading that much.
+	entrySize := int64(headerSize + codecSize + len("entry") + tagsSize + checksumSize)
+	f, err := os.OpenFile(filepath.Join(tmpDir, logsFileName), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, fileHeaderSize+3*entrySize+16)
+	_ = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []uint64
+	var readErr *StoreReadError
+	for r, err := range AllRecords(store, 1) {
+		if err != nil {
+			if !errors.As(err, &readErr) || !errors.Is(err, ErrStorageCorruption) || readErr.Index != 3 {
+				t.Errorf("Expected a corruption read error after record 3, got: %v", err)
+			}
+			break
+		}
+		got = append(got, r.Index)
+	}
+	if !reflect.DeepEqual(got, []uint64{1, 2, 3}) || readErr == nil {
+		t.Errorf("Read %v before the damaged record (err=%v)", got, readErr)
+	}
+}
+
+func TestFileStore_OffsetIndex(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-index-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := New(Config{AnchorEvery: 4}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tailPath := filepath.Join(tmpDir, tailFileName)
+	var tail19 []byte
+	for i := 0; i < 20; i++ {
+		if i == 19 {
+			if tail19, err = os.ReadFile(tailPath); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkFrom := func(t *testing.T, st Store, start uint64, want int) {
+		t.Helper()
+		var got []uint64
+		for r, err := range AllRecords(st, start) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, r.Index)
+		}
+		if len(got) != want || (want > 0 && got[0] != start) {
+			t.Fatalf("AllRecords(%d) yielded %v", start, got)
+		}
+	}
+	checkFrom(t, store, 1, 20)
+	checkFrom(t, store, 13, 8)
+	checkFrom(t, store, 21, 0)
+
+	for _, idx := range []uint64{4, 12, 20} {
+		if a, found, err := store.AnchorAt(idx); err != nil || !found || a.Index != idx {
+			t.Errorf("AnchorAt(%d): %+v found=%v err=%v", idx, a, found, err)
+		}
+	}
+	for _, idx := range []uint64{0, 5, 21} {
+		if _, found, err := store.AnchorAt(idx); err != nil || found {
+			t.Errorf("AnchorAt(%d) should not find an anchor (err=%v)", idx, err)
+		}
+	}
+	if err := store.(*fileStore).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	indexPath := filepath.Join(tmpDir, indexFileName)
+	for name, damage := range map[string]func() error{
+		"missing": func() error { return os.Remove(indexPath) },
+		"lagging": func() error { return os.Truncate(indexPath, 7*indexEntrySize) },
+		"torn":    func() error { return os.Truncate(indexPath, 7*indexEntrySize+3) },
+		"stale": func() error {
+			return os.WriteFile(indexPath, append(encodeIndexEntry(1, fileHeaderSize), encodeIndexEntry(2, fileHeaderSize+5)...), 0600)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := damage(); err != nil {
+				t.Fatal(err)
+			}
+			st, err := OpenFileStore(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer st.(*fileStore).Close()
+
+			info, err := os.Stat(indexPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() != 20*indexEntrySize {
+				t.Fatalf("Recovered index has %d bytes", info.Size())
+			}
+			checkFrom(t, st, 9, 12)
+			idx, _, err := st.(*fileStore).lastRecordLocked()
+			if err != nil || idx != 20 {
+				t.Fatalf("lastRecordLocked = %d, %v", idx, err)
+			}
+		})
+	}
+
+	// A short record is only a torn write if it is the last one and was
+	// never committed. Otherwise it is corruption and logs.dat is kept.
+	entrySize := int64(headerSize + codecSize + len("entry") + tagsSize + checksumSize)
+	logPath := filepath.Join(tmpDir, logsFileName)
+	logData, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, damage := range map[string]func() error{
+		// The length of record 10 now runs past the end of the file, found
+		// while rebuilding the index.
+		"length": func() error {
+			if err := os.Remove(indexPath); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(logPath, os.O_WRONLY, 0)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = f.WriteAt([]byte{0x7f, 0, 0, 0}, fileHeaderSize+9*entrySize+16)
+			return err
+		},
+		// Record 20 is cut short although tail.dat says it was committed.
+		"committed": func() error { return os.Truncate(logPath, fileHeaderSize+19*entrySize+10) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := damage(); err != nil {
+				t.Fatal(err)
+			}
+			damaged, err := os.ReadFile(logPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := OpenFileStore(tmpDir); !errors.Is(err, ErrStorageCorruption) {
+				t.Fatalf("Expected ErrStorageCorruption, got: %v", err)
+			}
+			if got, _ := os.ReadFile(logPath); !bytes.Equal(got, damaged) {
+				t.Error("Opening changed logs.dat")
+			}
+			if err := os.WriteFile(logPath, logData, 0600); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	// A torn final record is truncated on open and the tail rolled back to
+	// the last complete record.
+	if err := os.Truncate(logPath, fileHeaderSize+19*entrySize+10); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tailPath, tail19, 0600); err != nil {
+		t.Fatal(err)
+	}
+	st, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.(*fileStore).Close()
+	if info, err := os.Stat(logPath); err != nil || info.Size() != fileHeaderSize+19*entrySize {
+		t.Fatalf("Torn record not truncated: %v", err)
+	}
+	tail, ok, err := st.Tail()
+	if err != nil || !ok || tail.Index != 19 {
+		t.Fatalf("Expected tail at 19, got %+v (ok=%v, err=%v)", tail, ok, err)
+	}
+	r := Record{Index: 20, Msg: []byte("entry")}
+	if err := st.Append(r, TailState{Index: 20}, nil); err != nil {
+		t.Errorf("Append after recovery failed: %v", err)
+	}
+}
+
+func TestFileStore_Checksums(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-checksum-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := New(Config{AnchorEvery: 5}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.(*fileStore).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a bit in the message of record 7, as failing media would.
+	entrySize := int64(headerSize + codecSize + len("entry") + tagsSize + checksumSize)
+	logPath := filepath.Join(tmpDir, logsFileName)
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[fileHeaderSize+6*entrySize+headerSize] ^= 0x04
+	if err := os.WriteFile(logPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(tmpDir, indexFileName)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The store still opens and indexes past the corrupt record.
+	store, err = OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatalf("OpenFileStore with a corrupt record failed: %v", err)
+	}
+	defer store.(*fileStore).Close()
+	if got := readAllRecordsFrom(t, store, 8); len(got) != 3 || got[0].Index != 8 {
+		t.Errorf("Expected records 8..10 past the corrupt one, got %d", len(got))
+	}
+
+	count := 0
+	var readErr *StoreReadError
+	for _, err := range AllRecords(store, 1) {
+		if err != nil {
+			if !errors.As(err, &readErr) {
+				t.Fatalf("Expected *StoreReadError, got: %v", err)
+			}
+			break
+		}
+		count++
+	}
+	if count != 6 || readErr == nil || readErr.Offset != fileHeaderSize+6*entrySize || readErr.Index != 6 {
+		t.Fatalf("Expected 6 records and an error at record 7, got %d and %v", count, readErr)
+	}
+	if !errors.Is(readErr, ErrStorageCorruption) {
+		t.Errorf("Expected ErrStorageCorruption, got: %v", readErr)
+	}
+
+	// Verifiers report corruption, not tampering.
+	err = NewSemiTrustedVerifier(store).VerifyFromAnchor(mustAnchor(t, store, 5))
+	if !errors.Is(err, ErrStorageCorruption) || errors.Is(err, ErrTagMismatch) {
+		t.Errorf("Expected ErrStorageCorruption from verifier, got: %v", err)
+	}
+
+	// Rewriting the file would give the corrupt record a fresh checksum.
+	if err := store.(RedactableStore).ReplaceMsg(2, []byte("x")); !errors.Is(err, ErrStorageCorruption) {
+		t.Errorf("Expected ReplaceMsg to refuse a corrupt log, got: %v", err)
+	}
+}
+
+func readAllRecordsFrom(t *testing.T, store Store, start uint64) []Record {
+	t.Helper()
+	var records []Record
+	for r, err := range AllRecords(store, start) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestFileStore_ReadOnly(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-readonly-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	missing := filepath.Join(tmpDir, "missing")
+	if _, err := OpenFileStoreWithOptions(missing, FileStoreOptions{ReadOnly: true}); err == nil {
+		t.Error("Expected opening a missing store read-only to fail")
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Error("Opening read-only created the store directory")
+	}
+
+	dir := filepath.Join(tmpDir, "store")
+	writer, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	logger, err := New(Config{AnchorEvery: 3}, writer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, _ := logger.GetInitialKeys()
+	for i := 0; i < 6; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reader, err := OpenFileStoreWithOptions(dir, FileStoreOptions{ReadOnly: true, SegmentMaxBytes: 1})
+	if err != nil {
+		t.Fatalf("Opening read-only failed: %v", err)
+	}
+	if got := readAllRecords(t, reader); len(got) != 6 {
+		t.Fatalf("Expected 6 records, got %d", len(got))
+	}
+	if err := NewSemiTrustedVerifier(reader).VerifyFromAnchor(mustAnchor(t, reader, 3)); err != nil {
+		t.Fatalf("VerifyFromAnchor on a read-only store failed: %v", err)
+	}
+	if tail, ok, err := reader.Tail(); err != nil || !ok || tail.Index != 6 {
+		t.Errorf("Tail = %+v, %v, %v", tail, ok, err)
+	}
+	for name, err := range map[string]error{
+		"Append":      reader.Append(Record{Index: 7}, TailState{Index: 7}, nil),
+		"ReplaceMsg":  reader.(RedactableStore).ReplaceMsg(1, []byte("x")),
+		"Prune":       reader.(Pruner).Prune(3),
+		"ResetTail":   reader.(Repairer).ResetTail(TailState{Index: 6}),
+		"SealSegment": reader.(SegmentedStore).SealSegment(),
+	} {
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s: expected ErrReadOnly, got: %v", name, err)
+		}
+	}
+
+	// The reader follows the writer.
+	if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := readAllRecordsFrom(t, reader, 5); len(got) != 3 || got[2].Index != 7 {
+		t.Errorf("Expected records 5..7 after a concurrent append, got %d records", len(got))
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A damaged index is bypassed and a torn record left alone.
+	indexPath := filepath.Join(dir, indexFileName)
+	if err := os.WriteFile(indexPath, bytes.Repeat([]byte{0xff}, 3*indexEntrySize), 0600); err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := os.OpenFile(filepath.Join(dir, logsFileName), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := logFile.Write([]byte{0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	_ = logFile.Close()
+	before := snapshotDir(t, dir)
+	reader, err = OpenFileStoreWithOptions(dir, FileStoreOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("Opening read-only with a damaged index failed: %v", err)
+	}
+	var records []Record
+	var readErr error
+	for r, err := range RecordRange(reader, 4, 100) {
+		if err != nil {
+			readErr = err
+			break
+		}
+		records = append(records, r)
+	}
+	var storeErr *StoreReadError
+	if len(records) != 4 || records[0].Index != 4 || !errors.As(readErr, &storeErr) {
+		t.Errorf("Expected records 4..7 then a read error, got %d records, %v", len(records), readErr)
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(snapshotDir(t, dir), before) {
+		t.Error("A read-only store changed its files")
+	}
+
+	// Opening for writing repairs both.
+	writer, err = OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	var zeroTag [32]byte
+	if _, err := VerifyFrom(readAllRecords(t, writer), 0, a0, zeroTag); err != nil {
+		t.Fatalf("V-chain after reopening for writing failed: %v", err)
+	}
+}
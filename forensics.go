@@ -0,0 +1,285 @@
+package securelog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// TamperKind classifies the first inconsistency found by LocateTampering.
+type TamperKind int
+
+const (
+	// TamperNone indicates every span verified.
+	TamperNone TamperKind = iota
+	// TamperModification indicates a record's content or tags were altered.
+	TamperModification
+	// TamperDeletion indicates records are missing from the span.
+	TamperDeletion
+	// TamperInsertion indicates duplicated records or records beyond the tail.
+	TamperInsertion
+	// TamperReordering indicates all records are present but out of order.
+	TamperReordering
+)
+
+func (k TamperKind) String() string {
+	switch k {
+	case TamperNone:
+		return "none"
+	case TamperModification:
+		return "modification"
+	case TamperDeletion:
+		return "deletion"
+	case TamperInsertion:
+		return "insertion"
+	case TamperReordering:
+		return "reordering"
+	default:
+		return fmt.Sprintf("TamperKind(%d)", int(k))
+	}
+}
+
+// Span is an inclusive range of log indexes.
+type Span struct {
+	From, To uint64
+}
+
+// TamperReport is the result of localising tampering between anchors.
+type TamperReport struct {
+	Kind     TamperKind
+	Span     Span     // narrowest anchor-bounded span containing the first inconsistency
+	FirstBad uint64   // first index whose tag fails to verify (modifications only)
+	Missing  []Span   // index ranges absent from Span
+	Extra    []uint64 // indexes duplicated within Span or lying beyond the tail
+	Suspect  []Span   // every span that failed verification
+	Trusted  []Span   // spans that verify from their own starting anchor
+}
+
+// Tampered reports whether any inconsistency was found.
+func (r TamperReport) Tampered() bool {
+	return r.Kind != TamperNone
+}
+
+// checkpoint is a chain state usable as a verification boundary. The tail
+// has no key and can only close a span, never open one.
+type checkpoint struct {
+	index uint64
+	key   [KeySize]byte
+	tag   [32]byte
+	keyed bool
+}
+
+// LocateTampering walks the V-chain anchor by anchor to localise tampering.
+// Each span between consecutive checkpoints (A_0, the anchors, and the tail)
+// is checked independently, so a failure in one span does not taint spans that
+// still verify from their own anchor. Pass a zero tail if it is unavailable.
+func LocateTampering(records []Record, anchors []Anchor, a0 [KeySize]byte, tail TailState) TamperReport {
+	cps := []checkpoint{{key: a0, keyed: true}}
+	for _, a := range anchors {
+		cps = append(cps, checkpoint{index: a.Index, key: a.Key, tag: a.TagV, keyed: true})
+	}
+	if tail.Index != 0 {
+		cps = append(cps, checkpoint{index: tail.Index, tag: tail.TagV})
+	}
+	return locate(records, cps, true)
+}
+
+// LocateTamperingTrusted is LocateTampering for T, using the T-chain from B_0
+// and checkpoints derived by T (see TrustedAnchor).
+func LocateTamperingTrusted(
+	records []Record, anchors []TrustedAnchor, b0 [KeySize]byte, tail TailState,
+) TamperReport {
+	cps := []checkpoint{{key: b0, keyed: true}}
+	for _, a := range anchors {
+		cps = append(cps, checkpoint{index: a.Index, key: a.Key, tag: a.TagT, keyed: true})
+	}
+	if tail.Index != 0 {
+		cps = append(cps, checkpoint{index: tail.Index, tag: tail.TagT})
+	}
+	return locate(records, cps, false)
+}
+
+// LocateTampering loads the whole log, its anchors and tail from the store and
+// localises tampering on the V-chain starting from A_0.
+func (v *SemiTrustedVerifier) LocateTampering(a0 [KeySize]byte) (TamperReport, error) {
+	recs, err := collectRecords(context.Background(), v.store, 1)
+	if err != nil {
+		return TamperReport{}, err
+	}
+	anchors, err := v.store.ListAnchors()
+	if err != nil {
+		return TamperReport{}, err
+	}
+	tail, _, err := v.store.Tail()
+	if err != nil {
+		return TamperReport{}, err
+	}
+	return LocateTampering(recs, anchors, a0, tail), nil
+}
+
+// spanResult is the outcome of checking one span.
+type spanResult struct {
+	kind     TamperKind
+	firstBad uint64
+	missing  []Span
+	extra    []uint64
+}
+
+func locate(records []Record, cps []checkpoint, useVerifierChain bool) TamperReport {
+	sort.SliceStable(cps, func(i, j int) bool { return cps[i].index < cps[j].index })
+
+	var report TamperReport
+	note := func(span Span, res spanResult) {
+		if res.kind == TamperNone {
+			report.Trusted = appendSpan(report.Trusted, span)
+			return
+		}
+		report.Suspect = appendSpan(report.Suspect, span)
+		if report.Kind == TamperNone {
+			report.Kind = res.kind
+			report.Span = span
+			report.FirstBad = res.firstBad
+			report.Missing = res.missing
+			report.Extra = res.extra
+		}
+	}
+
+	for k, cp := range cps {
+		if !cp.keyed {
+			continue
+		}
+		var next *checkpoint
+		hi := ^uint64(0)
+		if k+1 < len(cps) {
+			next = &cps[k+1]
+			hi = next.index
+		}
+		if hi <= cp.index {
+			continue
+		}
+		seg := recordsBetween(records, cp.index, hi)
+		if next == nil {
+			if len(seg) == 0 {
+				continue
+			}
+			hi = maxIndex(seg)
+		}
+		note(Span{From: cp.index + 1, To: hi}, checkSpan(seg, cp, next, hi, useVerifierChain))
+	}
+
+	// Anything past the last checkpoint that cannot open a span (the tail) was
+	// appended behind the logger's back.
+	if last := cps[len(cps)-1]; !last.keyed {
+		if extra := recordsBetween(records, last.index, ^uint64(0)); len(extra) > 0 {
+			note(Span{From: last.index + 1, To: maxIndex(extra)},
+				spanResult{kind: TamperInsertion, extra: indexesOf(extra)})
+		}
+	}
+	return report
+}
+
+// checkSpan verifies the records of span (cp.index, hi] starting from cp and,
+// when next is set, that the span ends on next's tag.
+func checkSpan(seg []Record, cp checkpoint, next *checkpoint, hi uint64, useVerifierChain bool) spanResult {
+	if res := analyseIndexes(seg, cp.index+1, hi); res.kind != TamperNone {
+		return res
+	}
+
+	lastGood := cp.index
+	// Forensics does not know the log's mode, so each record may verify as
+	// either a plain or a redactable record; a substituted message still fails.
+	co := chainOptions{eitherMode: true, onStep: func(r Record, _ [KeySize]byte, _ [32]byte) { lastGood = r.Index }}
+	final, err := verifyChain(context.Background(), seg, cp.index, cp.key, cp.tag, useVerifierChain, co)
+	if errors.Is(err, ErrTagMismatch) {
+		return spanResult{kind: TamperModification, firstBad: lastGood + 1}
+	}
+	if err != nil {
+		return spanResult{kind: TamperModification, firstBad: cp.index + 1}
+	}
+	if next != nil && !constantTimeEqual(final[:], next.tag[:]) {
+		return spanResult{kind: TamperModification, firstBad: hi}
+	}
+	return spanResult{}
+}
+
+// analyseIndexes compares the indexes present in seg with the expected
+// contiguous range [from, to] and classifies any deviation.
+func analyseIndexes(seg []Record, from, to uint64) spanResult {
+	var res spanResult
+	seen := make(map[uint64]bool, len(seg))
+	present := make([]uint64, 0, len(seg))
+	for _, r := range seg {
+		if seen[r.Index] {
+			res.extra = append(res.extra, r.Index)
+			continue
+		}
+		seen[r.Index] = true
+		present = append(present, r.Index)
+	}
+
+	sort.Slice(present, func(i, j int) bool { return present[i] < present[j] })
+	next := from
+	for _, i := range present {
+		if i > next {
+			res.missing = append(res.missing, Span{From: next, To: i - 1})
+		}
+		next = i + 1
+	}
+	if next <= to {
+		res.missing = append(res.missing, Span{From: next, To: to})
+	}
+
+	switch {
+	case len(res.extra) > 0:
+		res.kind = TamperInsertion
+	case len(res.missing) > 0:
+		res.kind = TamperDeletion
+	default:
+		for i := 1; i < len(seg); i++ {
+			if seg[i].Index < seg[i-1].Index {
+				res.kind = TamperReordering
+				break
+			}
+		}
+	}
+	return res
+}
+
+// recordsBetween returns records with lo < Index <= hi in stored order.
+func recordsBetween(records []Record, lo, hi uint64) []Record {
+	var out []Record
+	for _, r := range records {
+		if r.Index > lo && r.Index <= hi {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func maxIndex(records []Record) uint64 {
+	var m uint64
+	for _, r := range records {
+		if r.Index > m {
+			m = r.Index
+		}
+	}
+	return m
+}
+
+func indexesOf(records []Record) []uint64 {
+	out := make([]uint64, len(records))
+	for i, r := range records {
+		out[i] = r.Index
+	}
+	return out
+}
+
+// appendSpan appends s, merging it with the previous span when adjacent.
+func appendSpan(spans []Span, s Span) []Span {
+	if n := len(spans); n > 0 && spans[n-1].To+1 == s.From {
+		spans[n-1].To = s.To
+		return spans
+	}
+	return append(spans, s)
+}
@@ -0,0 +1,226 @@
+package securelog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+func TestLocateTampering(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-forensics-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	logger, err := New(Config{AnchorEvery: 10}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, b0 := logger.GetInitialKeys()
+
+	for i := 0; i < 30; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records := readAllRecords(t, store)
+	anchors, err := store.ListAnchors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tail, _, err := store.Tail()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone := func() []Record { return append([]Record(nil), records...) }
+
+	tests := []struct {
+		name     string
+		mutate   func([]Record) []Record
+		kind     TamperKind
+		span     Span
+		firstBad uint64
+		missing  []Span
+		extra    []uint64
+		trusted  []Span
+	}{
+		{
+			name:    "untouched",
+			mutate:  func(r []Record) []Record { return r },
+			kind:    TamperNone,
+			trusted: []Span{{1, 30}},
+		},
+		{
+			name: "modification",
+			mutate: func(r []Record) []Record {
+				r[14].Msg = []byte("forged")
+				return r
+			},
+			kind:     TamperModification,
+			span:     Span{11, 20},
+			firstBad: 15,
+			trusted:  []Span{{1, 10}, {21, 30}},
+		},
+		{
+			name: "deletion",
+			mutate: func(r []Record) []Record {
+				return append(r[:11], r[13:]...)
+			},
+			kind:    TamperDeletion,
+			span:    Span{11, 20},
+			missing: []Span{{12, 13}},
+			trusted: []Span{{1, 10}, {21, 30}},
+		},
+		{
+			name: "insertion",
+			mutate: func(r []Record) []Record {
+				out := append([]Record(nil), r[:5]...)
+				out = append(out, r[4])
+				return append(out, r[5:]...)
+			},
+			kind:    TamperInsertion,
+			span:    Span{1, 10},
+			extra:   []uint64{5},
+			trusted: []Span{{11, 30}},
+		},
+		{
+			name: "reordering",
+			mutate: func(r []Record) []Record {
+				r[21], r[22] = r[22], r[21]
+				return r
+			},
+			kind:    TamperReordering,
+			span:    Span{21, 30},
+			trusted: []Span{{1, 20}},
+		},
+		{
+			name: "appended beyond tail",
+			mutate: func(r []Record) []Record {
+				extra := r[29]
+				extra.Index = 31
+				return append(r, extra)
+			},
+			kind:    TamperInsertion,
+			span:    Span{31, 31},
+			extra:   []uint64{31},
+			trusted: []Span{{1, 30}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := LocateTampering(tt.mutate(clone()), anchors, a0, tail)
+			if report.Kind != tt.kind {
+				t.Fatalf("Expected %v, got %v", tt.kind, report.Kind)
+			}
+			if report.Tampered() != (tt.kind != TamperNone) {
+				t.Errorf("Tampered() inconsistent with kind %v", report.Kind)
+			}
+			if tt.kind != TamperNone && report.Span != tt.span {
+				t.Errorf("Expected span %+v, got %+v", tt.span, report.Span)
+			}
+			if report.FirstBad != tt.firstBad {
+				t.Errorf("Expected first bad %d, got %d", tt.firstBad, report.FirstBad)
+			}
+			if !reflect.DeepEqual(report.Missing, tt.missing) {
+				t.Errorf("Expected missing %v, got %v", tt.missing, report.Missing)
+			}
+			if !reflect.DeepEqual(report.Extra, tt.extra) {
+				t.Errorf("Expected extra %v, got %v", tt.extra, report.Extra)
+			}
+			if !reflect.DeepEqual(report.Trusted, tt.trusted) {
+				t.Errorf("Expected trusted %v, got %v", tt.trusted, report.Trusted)
+			}
+		})
+	}
+
+	// T can localise on its own chain using the checkpoints it derived.
+	tv := NewTrustedVerifier(store, b0)
+	tv.SetTrustedAnchorInterval(10)
+	if err := tv.VerifyAll(); err != nil {
+		t.Fatal(err)
+	}
+	tampered := clone()
+	tampered[24].TagT[3] ^= 1
+	report := LocateTamperingTrusted(tampered, tv.TrustedAnchors(), b0, tail)
+	if report.Kind != TamperModification || report.FirstBad != 25 || report.Span != (Span{21, 30}) {
+		t.Errorf("Unexpected trusted report: %+v", report)
+	}
+}
+
+func TestSemiTrustedVerifier_LocateTampering(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-forensics-disk-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	logger, err := New(Config{AnchorEvery: 5}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, _ := logger.GetInitialKeys()
+
+	msg := []byte("fixed-size entry")
+	for i := 0; i < 15; i++ {
+		if _, err := logger.Append(msg, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Flip a message byte of entry 8 directly in logs.dat, fixing up its
+	// checksum as an attacker would.
+	recordSize := int64(headerSize+codecSize+len(msg)+tagsSize) + checksumSize
+	f, err := os.OpenFile(filepath.Join(tmpDir, logsFileName), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := make([]byte, recordSize)
+	if _, err := f.ReadAt(entry, fileHeaderSize+7*recordSize); err != nil {
+		t.Fatal(err)
+	}
+	entry[headerSize+codecSize] = 'X'
+	r, _ := readRecord(bytes.NewReader(entry), checksumSize, int64(len(entry)))
+	if _, err := f.WriteAt(encodeRecord(r, checksumSize), fileHeaderSize+7*recordSize); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	verifier := NewSemiTrustedVerifier(store)
+	if err := verifier.VerifyFromAnchor(Anchor{Key: a0}); err != ErrTagMismatch {
+		t.Fatalf("Expected ErrTagMismatch, got: %v", err)
+	}
+
+	report, err := verifier.LocateTampering(a0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Kind != TamperModification || report.FirstBad != 8 || report.Span != (Span{6, 10}) {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if !reflect.DeepEqual(report.Trusted, []Span{{1, 5}, {11, 15}}) {
+		t.Errorf("Expected spans 1-5 and 11-15 to remain trusted, got %v", report.Trusted)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/karasz/securelog
 go 1.23
 
 require (
+	go.etcd.io/bbolt v1.3.11
 	google.golang.org/protobuf v1.36.10
 	modernc.org/sqlite v1.30.0
 )
@@ -1,8 +1,11 @@
 package securelog
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/binary"
+	"errors"
+	"io"
 	"time"
 )
 
@@ -29,50 +32,81 @@ type Record struct {
 
 // TailState captures the aggregate MACs μ_V,i and μ_T,i for the current log tail.
 type TailState struct {
-	Index uint64
-	TagV  [32]byte
-	TagT  [32]byte
+	Index    uint64
+	TagV     [32]byte
+	TagT     [32]byte
+	TreeRoot [32]byte // Merkle root over records 1..Index
+	Leaf     [32]byte // Merkle leaf hash of record Index, in the log's mode (see LeafHash)
 }
 
 // Anchor is the checkpoint tuple shared with verifiers.
 type Anchor struct {
-	Index uint64
-	Key   [KeySize]byte // A_i (verifier key)
-	TagV  [32]byte      // μ_V,i
-	TagT  [32]byte      // μ_T,i
+	Index    uint64
+	Key      [KeySize]byte // A_i (verifier key)
+	TagV     [32]byte      // μ_V,i
+	TagT     [32]byte      // μ_T,i
+	TreeRoot [32]byte      // Merkle root over records 1..Index
 }
 
 // Config controls logger behavior.
 type Config struct {
-	AnchorEvery uint64         // publish an anchor every N entries (0=disabled)
-	InitialKeyV *[KeySize]byte // optional fixed A0 for verifier chain (for tests/HSMs)
-	InitialKeyT *[KeySize]byte // optional fixed B0 for trusted server chain (for tests/HSMs)
+	AnchorEvery uint64             // publish an anchor every N entries (0=disabled)
+	InitialKeyV *[KeySize]byte     // optional fixed A0 for verifier chain (for tests/HSMs)
+	InitialKeyT *[KeySize]byte     // optional fixed B0 for trusted server chain (for tests/HSMs)
+	Redactable  bool               // MAC a salted commitment so payloads can later be redacted
+	Encrypt     bool               // encrypt messages under the evolving key chain E_i (see Decryptor)
+	InitialKeyE *[KeySize]byte     // optional fixed E0 for the encryption chain (for tests/HSMs)
+	TreeHeadKey ed25519.PrivateKey // optional tree head signing key; its public half is bound in InitCommitment
+	LogID       string             // log ID registered with T; set by InitProtocol, or given here for logs initialised elsewhere
+	PruneKey    ed25519.PublicKey  // T's prune key (TrustedServer.PrunePublicKey); Prune needs authorisations signed by it
 }
 
 // Store abstracts persistence & anchor handling.
+//
+// Iter streams records with Index >= startIdx. The returned func stops the
+// iteration and reports why it ended: nil once the channel has been drained
+// to the end of the log, or a *StoreReadError if the store could not be read.
+// Callers must call it after the channel is closed to tell a truncated or
+// unreadable log from a short one.
+//
+// Close releases the files or database handle behind the store; the store
+// must not be used afterwards.
 type Store interface {
 	Append(r Record, tail TailState, anchor *Anchor) error
 	Iter(startIdx uint64) (<-chan Record, func() error, error)
 	AnchorAt(i uint64) (Anchor, bool, error)
 	ListAnchors() ([]Anchor, error)
 	Tail() (TailState, bool, error)
+	io.Closer
 }
 
+// ErrReadOnly is returned by the write methods of a store opened read-only.
+var ErrReadOnly = errors.New("store is opened read-only")
+
 // Logger is the logging server ("U" in the paper).
 type Logger struct {
 	cfg   Config
 	i     uint64
 	keyV  [KeySize]byte // A_i - key for semi-trusted verifier chain
 	keyT  [KeySize]byte // B_i - key for trusted server chain
+	keyE  [KeySize]byte // E_i - key for message encryption (zero unless cfg.Encrypt)
 	tagV  [32]byte      // μ_V,i (undefined when i==0; first step uses H(tag))
 	tagT  [32]byte      // μ_T,i (undefined when i==0; first step uses H(tag))
+	tree  merkleTree    // Merkle tree over all appended records
 	store Store
+	logID string // log ID registered with T, checked against prune authorisations
 }
 
 // New creates a private‑verifiable logger bound to a Store.
 // Initializes both key chains A0 and B0 as per Section 4.2 of the paper.
+//
+// New always starts a new log at index 1; it does not resume one, since the
+// evolved keys are not kept in st. Over a store that already holds records
+// Append fails, as stores only accept the next index, and TreeHead and the
+// proof methods cover nothing; prove records of such a log with
+// ProveInclusion and ProveConsistency.
 func New(cfg Config, st Store) (*Logger, error) {
-	var a0, b0 [KeySize]byte
+	var a0, b0, e0 [KeySize]byte
 
 	if cfg.InitialKeyV != nil {
 		a0 = *cfg.InitialKeyV
@@ -90,31 +124,74 @@ func New(cfg Config, st Store) (*Logger, error) {
 		}
 	}
 
-	return &Logger{cfg: cfg, keyV: a0, keyT: b0, store: st}, nil
+	if cfg.InitialKeyE != nil {
+		e0 = *cfg.InitialKeyE
+	} else if cfg.Encrypt {
+		if _, err := rand.Read(e0[:]); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Encrypt && isZero32(e0) {
+		return nil, errors.New("encryption key E0 must not be zero")
+	}
+
+	return &Logger{cfg: cfg, keyV: a0, keyT: b0, keyE: e0, store: st, logID: cfg.LogID}, nil
 }
 
 // Append logs a message with timestamp, updates state, and persists atomically.
 // Implements dual MAC chain computation as per Section 4.2:
 // - μ_V,i for semi-trusted verifier V (using key chain A_i)
 // - μ_T,i for trusted server T (using key chain B_i)
+//
+// With Config.Encrypt the stored message is encrypted under E_i and the MACs
+// cover the ciphertext; the returned entry carries the plaintext.
 func (l *Logger) Append(msg []byte, ts time.Time) (Entry, error) {
-	l.i++
+	return l.append(msg, ts, l.cfg.Encrypt)
+}
+
+// appendProtocol appends a protocol record (START, CLOSE or a prune event),
+// which is never encrypted so T can check it without the encryption key.
+func (l *Logger) appendProtocol(msg []byte, ts time.Time) (Entry, error) {
+	return l.append(msg, ts, false)
+}
+
+func (l *Logger) append(msg []byte, ts time.Time, encrypt bool) (Entry, error) {
+	// Work on copies so a failed store append leaves the logger unchanged.
+	i, keyV, keyT, keyE := l.i+1, l.keyV, l.keyT, l.keyE
+	fwdKey(&keyV)
+	fwdKey(&keyT)
+	if l.cfg.Encrypt {
+		fwdKey(&keyE)
+	}
 
-	fwdKey(&l.keyV)
-	fwdKey(&l.keyT)
+	body := msg
+	if encrypt {
+		var err error
+		if body, err = encryptMessage(keyE, i, ts.UnixNano(), msg); err != nil {
+			return Entry{}, err
+		}
+	}
+	stored := append([]byte(nil), body...)
+	if l.cfg.Redactable {
+		var err error
+		if stored, err = sealMessage(body); err != nil {
+			return Entry{}, err
+		}
+	}
 
 	var idx [8]byte
-	binary.BigEndian.PutUint64(idx[:], l.i)
+	binary.BigEndian.PutUint64(idx[:], i)
 	var tsb [8]byte
 	binary.BigEndian.PutUint64(tsb[:], uint64(ts.UnixNano()))
 
-	macV := mac(l.keyV[:], idx[:], tsb[:], msg)
-	macT := mac(l.keyT[:], idx[:], tsb[:], msg)
+	covered, _ := macMessage(stored, l.cfg.Redactable)
+	macV := mac(keyV[:], idx[:], tsb[:], covered)
+	macT := mac(keyT[:], idx[:], tsb[:], covered)
 
 	//   First entry after start: μ_1 = H(tag_1)
 	//   Subsequent entries:     μ_i = H( μ_{i-1} || tag_i )
 	var tagV, tagT [32]byte
-	if l.i == 1 && isZero32(l.tagV) && isZero32(l.tagT) {
+	if i == 1 && isZero32(l.tagV) && isZero32(l.tagT) {
 		tagV = htag(macV)
 		tagT = htag(macT)
 	} else {
@@ -123,40 +200,48 @@ func (l *Logger) Append(msg []byte, ts time.Time) (Entry, error) {
 	}
 
 	rec := Record{
-		Index: l.i,
+		Index: i,
 		TS:    ts.UnixNano(),
-		Msg:   append([]byte(nil), msg...),
+		Msg:   stored,
 		TagV:  tagV,
 		TagT:  tagT,
 	}
 
+	leaf := LeafHash(rec, l.cfg.Redactable)
+	tree := l.tree.clone()
+	tree.append(leaf)
+	root := tree.root()
+
 	var anchor *Anchor
-	if l.cfg.AnchorEvery != 0 && (l.i%l.cfg.AnchorEvery == 0) {
-		cpKey := l.keyV // Store verifier key for checkpoints
+	if l.cfg.AnchorEvery != 0 && (i%l.cfg.AnchorEvery == 0) {
+		cpKey := keyV // Store verifier key for checkpoints
 		anchor = &Anchor{
-			Index: l.i,
-			Key:   cpKey,
-			TagV:  tagV,
-			TagT:  tagT,
+			Index:    i,
+			Key:      cpKey,
+			TagV:     tagV,
+			TagT:     tagT,
+			TreeRoot: root,
 		}
 	}
 
-	tail := TailState{Index: l.i, TagV: tagV, TagT: tagT}
+	tail := TailState{Index: i, TagV: tagV, TagT: tagT, TreeRoot: root, Leaf: leaf}
 
 	if err := l.store.Append(rec, tail, anchor); err != nil {
-		l.i--
 		return Entry{}, err
 	}
 
+	l.i, l.keyV, l.keyT, l.keyE = i, keyV, keyT, keyE
 	l.tagV = tagV
 	l.tagT = tagT
+	l.tree = tree
 
-	return Entry{Index: rec.Index, TS: rec.TS, Msg: rec.Msg, Tag: tagV}, nil
+	payload := append([]byte(nil), msg...)
+	return Entry{Index: rec.Index, TS: rec.TS, Msg: payload, Tag: tagV}, nil
 }
 
 // Close appends the special CLOSE record per §4 and returns that entry.
 func (l *Logger) Close(ts time.Time) (Entry, error) {
-	return l.Append([]byte("CLOSE"), ts)
+	return l.appendProtocol([]byte("CLOSE"), ts)
 }
 
 // LastState returns current tail state (useful for live checkpoints).
@@ -165,6 +250,12 @@ func (l *Logger) LastState() (idx uint64, tagV, tagT [32]byte) {
 	return l.i, l.tagV, l.tagT
 }
 
+// GetInitialEncryptionKey returns E0, or false if the logger does not encrypt.
+// Like GetInitialKeys it is only valid before the first append.
+func (l *Logger) GetInitialEncryptionKey() ([KeySize]byte, bool) {
+	return l.keyE, l.cfg.Encrypt
+}
+
 // GetInitialKeys returns A0 and B0 for trusted server commitment.
 // WARNING: This should only be called during log initialization and
 // the keys must be securely transmitted to the trusted server T.
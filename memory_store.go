@@ -0,0 +1,255 @@
+package securelog
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is returned by a MemoryStore append failed with FailAppend.
+var ErrInjectedFault = errors.New("injected fault")
+
+// MemoryStore is a Store kept in memory, for tests and ephemeral logs. It is
+// safe for concurrent use and, like the file store, only accepts the record
+// directly after the last one. It also implements SeqStore, TimeStore,
+// TreeStore, RedactableStore, Pruner and Repairer.
+//
+// FailAppend, DropRecords and CorruptMsg inject faults, so error handling
+// around Logger.Append and the verifiers can be tested without touching
+// files.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	last    uint64   // index of the last record appended
+	records []Record // in index order; gaps only through DropRecords or Prune
+	anchors []Anchor // in index order
+	tail    TailState
+	hasTail bool
+	leaves  [][32]byte // leaf hash of record i+1
+	roots   [][32]byte // tree root after record i+1
+
+	appends  int   // Append calls so far
+	failAt   int   // Append call that fails, 0 for none
+	failWith error // error returned by that call
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Append stores r, and anchor if not nil, and replaces the tail state.
+func (s *MemoryStore) Append(r Record, tail TailState, anchor *Anchor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.appends++
+	if s.appends == s.failAt {
+		s.failAt = 0
+		return s.failWith
+	}
+	if s.last != r.Index-1 {
+		return fmt.Errorf("non-contiguous append: have %d, got %d", s.last, r.Index)
+	}
+
+	r.Msg = slices.Clone(r.Msg)
+	s.records = append(s.records, r)
+	s.last = r.Index
+	if len(s.leaves) == int(r.Index-1) {
+		tail.Leaf = appendedLeaf(r, tail)
+		s.leaves = append(s.leaves, tail.Leaf)
+		s.roots = append(s.roots, tail.TreeRoot)
+	}
+	if anchor != nil {
+		s.anchors = append(s.anchors, *anchor)
+	}
+	s.tail, s.hasTail = tail, true
+	return nil
+}
+
+// FailAppend makes the nth Append call from now on fail with err, without
+// storing anything; later calls succeed again. A nil err fails with
+// ErrInjectedFault.
+func (s *MemoryStore) FailAppend(n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		err = ErrInjectedFault
+	}
+	s.failAt, s.failWith = s.appends+n, err
+}
+
+// DropRecords silently removes the records with from <= Index <= to, as a
+// store losing data would. Anchors, tail and tree state are left alone.
+func (s *MemoryStore) DropRecords(from, to uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = slices.DeleteFunc(s.records, func(r Record) bool {
+		return r.Index >= from && r.Index <= to
+	})
+}
+
+// CorruptMsg flips the lowest bit of byte off of the stored message of record
+// idx, leaving its tags unchanged.
+func (s *MemoryStore) CorruptMsg(idx uint64, off int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.findLocked(idx)
+	if !ok {
+		return fmt.Errorf("record %d not found", idx)
+	}
+	if off < 0 || off >= len(s.records[i].Msg) {
+		return fmt.Errorf("offset %d outside message of record %d", off, idx)
+	}
+	s.records[i].Msg[off] ^= 0x01
+	return nil
+}
+
+// findLocked returns the position of record idx in s.records.
+func (s *MemoryStore) findLocked(idx uint64) (int, bool) {
+	i := sort.Search(len(s.records), func(i int) bool { return s.records[i].Index >= idx })
+	return i, i < len(s.records) && s.records[i].Index == idx
+}
+
+// Iter returns a channel that yields records starting from startIdx.
+func (s *MemoryStore) Iter(startIdx uint64) (<-chan Record, func() error, error) {
+	out, done := seqChan(s.All(startIdx))
+	return out, done, nil
+}
+
+// All yields the records with Index >= start.
+func (s *MemoryStore) All(start uint64) iter.Seq2[Record, error] {
+	return s.Range(start, math.MaxUint64)
+}
+
+// Range yields the records with start <= Index <= end, as they were when the
+// iteration started.
+func (s *MemoryStore) Range(start, end uint64) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		s.mu.RLock()
+		from, _ := s.findLocked(start)
+		to, found := s.findLocked(end)
+		if found {
+			to++
+		}
+		recs := slices.Clone(s.records[from:max(from, to)])
+		s.mu.RUnlock()
+
+		for _, r := range recs {
+			r.Msg = slices.Clone(r.Msg)
+			if !yield(r, nil) {
+				return
+			}
+		}
+	}
+}
+
+// IterTime yields the records with from <= TS < to in index order.
+func (s *MemoryStore) IterTime(from, to time.Time) iter.Seq2[Record, error] {
+	lo, hi := from.UnixNano(), to.UnixNano()
+	return func(yield func(Record, error) bool) {
+		for r := range s.All(1) {
+			if r.TS >= lo && r.TS < hi && !yield(r, nil) {
+				return
+			}
+		}
+	}
+}
+
+// AnchorAt returns the anchor at index i, if any.
+func (s *MemoryStore) AnchorAt(i uint64) (Anchor, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, a := range s.anchors {
+		if a.Index == i {
+			return a, true, nil
+		}
+	}
+	return Anchor{}, false, nil
+}
+
+// ListAnchors returns all anchors in index order.
+func (s *MemoryStore) ListAnchors() ([]Anchor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.anchors), nil
+}
+
+// Tail returns the current tail state.
+func (s *MemoryStore) Tail() (TailState, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tail, s.hasTail, nil
+}
+
+// LeafHashes returns the leaf hashes of records with indexes in [from, to].
+func (s *MemoryStore) LeafHashes(from, to uint64) ([][32]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if from == 0 || from > to || from > uint64(len(s.leaves)) {
+		return nil, nil
+	}
+	to = min(to, uint64(len(s.leaves)))
+	return slices.Clone(s.leaves[from-1 : to]), nil
+}
+
+// ReplaceMsg replaces the stored message of record idx, keeping its index,
+// timestamp and tags. It is used for redaction.
+func (s *MemoryStore) ReplaceMsg(idx uint64, msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.findLocked(idx)
+	if !ok {
+		return fmt.Errorf("record %d not found", idx)
+	}
+	s.records[i].Msg = slices.Clone(msg)
+	return nil
+}
+
+// Prune deletes records and anchors with index below beforeIndex. Tree state
+// is kept, as in the file store.
+func (s *MemoryStore) Prune(beforeIndex uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, _ := s.findLocked(beforeIndex)
+	s.records = slices.Delete(s.records, 0, i)
+	s.anchors = slices.DeleteFunc(s.anchors, func(a Anchor) bool { return a.Index < beforeIndex })
+	return nil
+}
+
+// Close does nothing; the records stay in memory.
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// ResetTail drops anchors and tree state beyond tail.Index and replaces the
+// tail state. tail.Index must be the last record.
+func (s *MemoryStore) ResetTail(tail TailState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var last uint64
+	if n := len(s.records); n > 0 {
+		last = s.records[n-1].Index
+	}
+	if tail.Index != last {
+		return fmt.Errorf("tail at %d is not the last record %d", tail.Index, last)
+	}
+	s.last = last
+	s.anchors = slices.DeleteFunc(s.anchors, func(a Anchor) bool { return a.Index > last })
+	if n := min(uint64(len(s.leaves)), last); n < uint64(len(s.leaves)) {
+		s.leaves, s.roots = s.leaves[:n], s.roots[:n]
+	}
+	if last == 0 {
+		s.tail, s.hasTail = TailState{}, false
+		return nil
+	}
+	if last <= uint64(len(s.roots)) {
+		tail.TreeRoot, tail.Leaf = s.roots[last-1], s.leaves[last-1]
+	}
+	s.tail, s.hasTail = tail, true
+	return nil
+}
@@ -0,0 +1,173 @@
+package securelog
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	logger, err := New(Config{AnchorEvery: 5}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, _ := logger.GetInitialKeys()
+	for i := 0; i < 12; i++ {
+		if _, err := logger.Append([]byte(fmt.Sprintf("entry %d", i)), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records := readAllRecords(t, store)
+	if len(records) != 12 {
+		t.Fatalf("Expected 12 records, got %d", len(records))
+	}
+	var zeroTag [32]byte
+	if _, err := VerifyFrom(records, 0, a0, zeroTag); err != nil {
+		t.Fatalf("V-chain failed: %v", err)
+	}
+	if err := NewSemiTrustedVerifier(store).VerifyFromAnchor(mustAnchor(t, store, 5)); err != nil {
+		t.Fatalf("VerifyFromAnchor failed: %v", err)
+	}
+	if anchors, _ := store.ListAnchors(); len(anchors) != 2 {
+		t.Errorf("Expected 2 anchors, got %d", len(anchors))
+	}
+	if tail, ok, _ := store.Tail(); !ok || tail.Index != 12 || tail.TreeRoot != MerkleRoot(records, false) {
+		t.Errorf("Unexpected tail %+v", tail)
+	}
+	if rep, err := CheckStore(store); err != nil || !rep.OK() || rep.Records != 12 {
+		t.Errorf("CheckStore = %+v, %v", rep, err)
+	}
+
+	var got []uint64
+	for r, err := range RecordRange(store, 4, 6) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r.Index)
+	}
+	if !reflect.DeepEqual(got, []uint64{4, 5, 6}) {
+		t.Errorf("RecordRange(4, 6) yielded %v", got)
+	}
+
+	// Records handed out are copies.
+	records[0].Msg[0] ^= 0xff
+	if _, err := VerifyFrom(readAllRecords(t, store), 0, a0, zeroTag); err != nil {
+		t.Errorf("Modifying a returned record changed the store: %v", err)
+	}
+
+	if err := store.Append(Record{Index: 14}, TailState{Index: 14}, nil); err == nil {
+		t.Error("Expected a non-contiguous append to fail")
+	}
+}
+
+func TestMemoryStore_Concurrent(t *testing.T) {
+	store := NewMemoryStore()
+	logger, err := New(Config{AnchorEvery: 10}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				var prev uint64
+				for r, err := range AllRecords(store, 1) {
+					if err != nil || r.Index != prev+1 {
+						t.Errorf("Reader saw record %d after %d: %v", r.Index, prev, err)
+						return
+					}
+					prev = r.Index
+				}
+			}
+		}()
+	}
+	for i := 0; i < 200; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestMemoryStore_Faults(t *testing.T) {
+	store := NewMemoryStore()
+	logger, err := New(Config{AnchorEvery: 5}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, _ := logger.GetInitialKeys()
+	var zeroTag [32]byte
+
+	// A failed append leaves the logger where it was.
+	store.FailAppend(3, nil)
+	for i := 1; i <= 3; i++ {
+		_, err := logger.Append([]byte("entry"), time.Now())
+		if i < 3 && err != nil {
+			t.Fatal(err)
+		}
+		if i == 3 && !errors.Is(err, ErrInjectedFault) {
+			t.Fatalf("Expected ErrInjectedFault from append 3, got: %v", err)
+		}
+	}
+	diskFull := errors.New("disk full")
+	store.FailAppend(1, diskFull)
+	if _, err := logger.Append([]byte("entry"), time.Now()); !errors.Is(err, diskFull) {
+		t.Fatalf("Expected the injected error, got: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		e, err := logger.Append([]byte("entry"), time.Now())
+		if err != nil {
+			t.Fatalf("Append after injected faults: %v", err)
+		}
+		if e.Index != uint64(i+3) {
+			t.Fatalf("Expected index %d, got %d", i+3, e.Index)
+		}
+	}
+	if _, err := VerifyFrom(readAllRecords(t, store), 0, a0, zeroTag); err != nil {
+		t.Fatalf("V-chain after injected faults: %v", err)
+	}
+
+	if err := store.CorruptMsg(4, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyFrom(readAllRecords(t, store), 0, a0, zeroTag); !errors.Is(err, ErrTagMismatch) {
+		t.Errorf("Expected ErrTagMismatch for a corrupted message, got: %v", err)
+	}
+	if err := store.CorruptMsg(4, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CorruptMsg(11, 0); err == nil {
+		t.Error("Expected CorruptMsg of a missing record to fail")
+	}
+
+	store.DropRecords(6, 7)
+	if _, err := VerifyFrom(readAllRecords(t, store), 0, a0, zeroTag); !errors.Is(err, ErrGap) {
+		t.Errorf("Expected ErrGap for dropped records, got: %v", err)
+	}
+	rep, err := CheckStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, ok := hasIssue(rep, ErrStoreGap, 6); !ok || i.Repairable {
+		t.Errorf("Expected an unrepairable gap at 6, got %v", rep.Issues)
+	}
+}
@@ -0,0 +1,388 @@
+package securelog
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Merkle tree over records, following RFC 6962 §2.1: leaves are hashed as
+// SHA-256(0x00 || data) and interior nodes as SHA-256(0x01 || left || right).
+// Leaf i of the tree is the record with Index i+1.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// ErrInvalidProof indicates a Merkle proof does not match the claimed root.
+var ErrInvalidProof = errors.New("invalid Merkle proof")
+
+// TreeStore is implemented by stores that persist Merkle leaf hashes alongside
+// records. Proof generation uses it when available instead of re-hashing records.
+type TreeStore interface {
+	// LeafHashes returns the leaf hashes of records with indexes in [from, to].
+	// It may return fewer hashes when tree state does not cover the range,
+	// e.g. for records written before the store kept it.
+	LeafHashes(from, to uint64) ([][32]byte, error)
+}
+
+// LeafHash returns the RFC 6962 leaf hash of a record, covering its index,
+// timestamp, message and both chain tags. redactable is the log's mode
+// (Config.Redactable): on redactable logs the message commitment is hashed
+// instead, so redaction leaves the tree unchanged. Verifiers must take the
+// mode from what they know of the log, never from the record itself.
+func LeafHash(r Record, redactable bool) [32]byte {
+	msg := leafMessage(r.Msg, redactable)
+	h := sha256.New()
+	var buf [8 + 8 + 4]byte
+	binary.BigEndian.PutUint64(buf[0:8], r.Index)
+	binary.BigEndian.PutUint64(buf[8:16], uint64(r.TS))
+	binary.BigEndian.PutUint32(buf[16:20], uint32(len(msg)))
+	_, _ = h.Write([]byte{merkleLeafPrefix})
+	_, _ = h.Write(buf[:])
+	_, _ = h.Write(msg)
+	_, _ = h.Write(r.TagV[:])
+	_, _ = h.Write(r.TagT[:])
+	var out [32]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// appendedLeaf returns the leaf hash of r as the logger computed it in
+// tail. Stores keep it as their tree state, since they do not know the log's
+// mode; a tail without one is read as that of a log that is not redactable.
+func appendedLeaf(r Record, tail TailState) [32]byte {
+	if !isZero32(tail.Leaf) {
+		return tail.Leaf
+	}
+	return LeafHash(r, false)
+}
+
+// storedRedactable reports whether leaf, the hash a store keeps for r, reads
+// r as a record of a redactable log. Only envelopes hash differently in the
+// two modes; for other records it is false.
+func storedRedactable(r Record, leaf [32]byte) bool {
+	if _, ok := commitmentOf(r.Msg); !ok {
+		return false
+	}
+	return LeafHash(r, true) == leaf
+}
+
+func nodeHash(left, right [32]byte) [32]byte {
+	h := sha256.New()
+	_, _ = h.Write([]byte{merkleNodePrefix})
+	_, _ = h.Write(left[:])
+	_, _ = h.Write(right[:])
+	var out [32]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// merkleTree is an append-only Merkle tree that only keeps the roots of its
+// perfect subtrees (the "frontier"), so appends and root computation cost
+// O(log n) memory and time.
+type merkleTree struct {
+	size     uint64
+	frontier [][32]byte // perfect subtree roots, largest (leftmost) first
+}
+
+// append adds a leaf hash to the tree.
+func (t *merkleTree) append(leaf [32]byte) {
+	t.frontier = append(t.frontier, leaf)
+	for s := t.size; s&1 == 1; s >>= 1 {
+		n := len(t.frontier)
+		t.frontier[n-2] = nodeHash(t.frontier[n-2], t.frontier[n-1])
+		t.frontier = t.frontier[:n-1]
+	}
+	t.size++
+}
+
+// clone returns an independent copy, so a pending append can be discarded.
+func (t *merkleTree) clone() merkleTree {
+	return merkleTree{size: t.size, frontier: append([][32]byte(nil), t.frontier...)}
+}
+
+// root returns the RFC 6962 tree head for the current size.
+func (t *merkleTree) root() [32]byte {
+	if len(t.frontier) == 0 {
+		return sha256.Sum256(nil)
+	}
+	h := t.frontier[len(t.frontier)-1]
+	for i := len(t.frontier) - 2; i >= 0; i-- {
+		h = nodeHash(t.frontier[i], h)
+	}
+	return h
+}
+
+// MerkleRoot computes the RFC 6962 root over the given records' leaf hashes
+// (see LeafHash for redactable).
+func MerkleRoot(records []Record, redactable bool) [32]byte {
+	var t merkleTree
+	for _, r := range records {
+		t.append(LeafHash(r, redactable))
+	}
+	return t.root()
+}
+
+// mth is MTH(D[n]) from RFC 6962 over precomputed leaf hashes.
+func mth(leaves [][32]byte) [32]byte {
+	switch len(leaves) {
+	case 0:
+		return sha256.Sum256(nil)
+	case 1:
+		return leaves[0]
+	}
+	k := splitPoint(uint64(len(leaves)))
+	return nodeHash(mth(leaves[:k]), mth(leaves[k:]))
+}
+
+// splitPoint returns the largest power of two strictly smaller than n (n > 1).
+func splitPoint(n uint64) uint64 {
+	k := uint64(1)
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// InclusionProof proves that the record at Index is part of the tree of TreeSize records.
+type InclusionProof struct {
+	Index    uint64     // record index (1-based)
+	TreeSize uint64     // number of records in the tree
+	Path     [][32]byte // audit path, leaf to root
+}
+
+// inclusionPath is PATH(m, D[n]) from RFC 6962 for leaf position m.
+func inclusionPath(m uint64, leaves [][32]byte) [][32]byte {
+	n := uint64(len(leaves))
+	if n <= 1 {
+		return nil
+	}
+	k := splitPoint(n)
+	if m < k {
+		return append(inclusionPath(m, leaves[:k]), mth(leaves[k:]))
+	}
+	return append(inclusionPath(m-k, leaves[k:]), mth(leaves[:k]))
+}
+
+// ProveInclusion builds an inclusion proof for the record at index within the
+// tree formed by the first treeSize records of st.
+func ProveInclusion(st Store, index, treeSize uint64) (InclusionProof, error) {
+	if index == 0 || index > treeSize {
+		return InclusionProof{}, fmt.Errorf("index %d outside tree of size %d", index, treeSize)
+	}
+	leaves, err := storeLeaves(st, treeSize)
+	if err != nil {
+		return InclusionProof{}, err
+	}
+	return InclusionProof{
+		Index:    index,
+		TreeSize: treeSize,
+		Path:     inclusionPath(index-1, leaves),
+	}, nil
+}
+
+// VerifyInclusion checks that leaf (see LeafHash) is included in the tree with
+// the given root, following RFC 9162 §2.1.3.2.
+func VerifyInclusion(leaf [32]byte, proof InclusionProof, root [32]byte) error {
+	if proof.Index == 0 || proof.Index > proof.TreeSize {
+		return ErrInvalidProof
+	}
+	fn, sn := proof.Index-1, proof.TreeSize-1
+	r := leaf
+	for _, p := range proof.Path {
+		if sn == 0 {
+			return ErrInvalidProof
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 || !constantTimeEqual(r[:], root[:]) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// ConsistencyProof proves that the tree of OldSize records is a prefix of the
+// tree of NewSize records, i.e. the log was only appended to in between.
+type ConsistencyProof struct {
+	OldSize uint64
+	NewSize uint64
+	Path    [][32]byte
+}
+
+// consistencyPath is SUBPROOF(m, D[n], b) from RFC 6962.
+func consistencyPath(m uint64, leaves [][32]byte, complete bool) [][32]byte {
+	n := uint64(len(leaves))
+	if m == n {
+		if complete {
+			return nil
+		}
+		return [][32]byte{mth(leaves)}
+	}
+	k := splitPoint(n)
+	if m <= k {
+		return append(consistencyPath(m, leaves[:k], complete), mth(leaves[k:]))
+	}
+	return append(consistencyPath(m-k, leaves[k:], false), mth(leaves[:k]))
+}
+
+// ProveConsistency builds a consistency proof between the trees formed by the
+// first oldSize and the first newSize records of st.
+func ProveConsistency(st Store, oldSize, newSize uint64) (ConsistencyProof, error) {
+	if oldSize == 0 || oldSize > newSize {
+		return ConsistencyProof{}, fmt.Errorf("invalid tree sizes %d -> %d", oldSize, newSize)
+	}
+	leaves, err := storeLeaves(st, newSize)
+	if err != nil {
+		return ConsistencyProof{}, err
+	}
+	return ConsistencyProof{
+		OldSize: oldSize,
+		NewSize: newSize,
+		Path:    consistencyPath(oldSize, leaves, true),
+	}, nil
+}
+
+// VerifyConsistency checks that oldRoot and newRoot are the roots of trees of
+// proof.OldSize and proof.NewSize records where the former is a prefix of the
+// latter, following RFC 9162 §2.1.4.2.
+func VerifyConsistency(proof ConsistencyProof, oldRoot, newRoot [32]byte) error {
+	m, n := proof.OldSize, proof.NewSize
+	switch {
+	case m == 0 || m > n:
+		return ErrInvalidProof
+	case m == n:
+		if len(proof.Path) != 0 || !constantTimeEqual(oldRoot[:], newRoot[:]) {
+			return ErrInvalidProof
+		}
+		return nil
+	case len(proof.Path) == 0:
+		return ErrInvalidProof
+	}
+
+	path := proof.Path
+	if m&(m-1) == 0 {
+		// The old tree is a complete subtree, so its root is the first node.
+		path = append([][32]byte{oldRoot}, path...)
+	}
+	fn, sn := m-1, n-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+	fr, sr := path[0], path[0]
+	for _, c := range path[1:] {
+		if sn == 0 {
+			return ErrInvalidProof
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = nodeHash(c, fr)
+			sr = nodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = nodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 || !constantTimeEqual(fr[:], oldRoot[:]) || !constantTimeEqual(sr[:], newRoot[:]) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// leafChunk is how many leaf hashes are read from a TreeStore at a time.
+const leafChunk = 4096
+
+// storeLeaves returns the leaf hashes of records 1..treeSize.
+func storeLeaves(st Store, treeSize uint64) ([][32]byte, error) {
+	leaves := make([][32]byte, 0, treeSize)
+	err := eachLeaf(st, treeSize, func(leaf [32]byte) { leaves = append(leaves, leaf) })
+	if err != nil {
+		return nil, err
+	}
+	return leaves, nil
+}
+
+// eachLeaf calls fn with the leaf hashes of records 1..treeSize in order.
+// Hashes are read in chunks from the store's tree state as far as it covers
+// the range; the rest is recomputed from records, which fails when those
+// records are gone, e.g. after pruning. The store does not know the log's
+// mode, so only records whose leaf is the same in either mode are
+// recomputed.
+func eachLeaf(st Store, treeSize uint64, fn func([32]byte)) error {
+	next := uint64(1)
+	if ts, ok := st.(TreeStore); ok {
+		for next <= treeSize {
+			to := min(next+leafChunk-1, treeSize)
+			leaves, err := ts.LeafHashes(next, to)
+			if err != nil {
+				return err
+			}
+			for _, leaf := range leaves {
+				fn(leaf)
+			}
+			next += uint64(len(leaves))
+			if next <= to {
+				break // tree state ends here
+			}
+		}
+	}
+	if next > treeSize {
+		return nil
+	}
+
+	for r, err := range RecordRange(st, next, treeSize) {
+		if err != nil {
+			return err
+		}
+		if r.Index != next {
+			return fmt.Errorf("cannot rebuild Merkle leaf %d: store holds record %d next", next, r.Index)
+		}
+		if _, ok := commitmentOf(r.Msg); ok {
+			return fmt.Errorf("cannot rebuild Merkle leaf %d: its hash depends on the log's mode", next)
+		}
+		fn(LeafHash(r, false))
+		next++
+	}
+	if next <= treeSize {
+		return fmt.Errorf("cannot rebuild Merkle leaves %d..%d: records missing from store", next, treeSize)
+	}
+	return nil
+}
+
+// InclusionProof proves that the record at index is part of the logger's
+// current Merkle tree, whose root is returned by TreeHead. The tree covers
+// the records this logger appended; for a log written by another Logger use
+// ProveInclusion with the size and root of the store's Tail.
+func (l *Logger) InclusionProof(index uint64) (InclusionProof, error) {
+	return ProveInclusion(l.store, index, l.tree.size)
+}
+
+// ConsistencyProof proves that the logger's tree at oldSize is a prefix of its
+// tree at newSize. newSize must not exceed the current tree size.
+func (l *Logger) ConsistencyProof(oldSize, newSize uint64) (ConsistencyProof, error) {
+	if newSize > l.tree.size {
+		return ConsistencyProof{}, fmt.Errorf("tree size %d beyond current size %d", newSize, l.tree.size)
+	}
+	return ProveConsistency(l.store, oldSize, newSize)
+}
+
+// TreeHead returns the current Merkle tree size and root.
+func (l *Logger) TreeHead() (size uint64, root [32]byte) {
+	return l.tree.size, l.tree.root()
+}
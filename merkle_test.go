@@ -0,0 +1,350 @@
+package securelog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+func TestMerkleTree_MatchesRecursiveRoot(t *testing.T) {
+	var tree merkleTree
+	var leaves [][32]byte
+	for i := uint64(1); i <= 33; i++ {
+		leaf := LeafHash(Record{Index: i, TS: int64(i), Msg: []byte("entry")}, false)
+		tree.append(leaf)
+		leaves = append(leaves, leaf)
+		if tree.root() != mth(leaves) {
+			t.Fatalf("size %d: incremental root differs from MTH", i)
+		}
+	}
+}
+
+func TestInclusionProof_AllStores(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-merkle-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fileSt, err := OpenFileStore(filepath.Join(tmpDir, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileSt.(*fileStore).Close()
+
+	sqliteSt, err := OpenSQLiteStore(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, store := range map[string]Store{"file": fileSt, "sqlite": sqliteSt} {
+		t.Run(name, func(t *testing.T) {
+			logger, err := New(Config{AnchorEvery: 4}, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 11; i++ {
+				if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			size, root := logger.TreeHead()
+			records := readAllRecords(t, store)
+			if size != 11 || root != MerkleRoot(records, false) {
+				t.Fatalf("Tree head (%d) does not match records", size)
+			}
+
+			tail, _, err := store.Tail()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tail.TreeRoot != root {
+				t.Error("Tail tree root not persisted")
+			}
+
+			anchors, err := store.ListAnchors()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, a := range anchors {
+				if a.TreeRoot != MerkleRoot(records[:a.Index], false) {
+					t.Errorf("Anchor %d: tree root mismatch", a.Index)
+				}
+				proof, err := ProveInclusion(store, 1, a.Index)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := VerifyInclusion(LeafHash(records[0], false), proof, a.TreeRoot); err != nil {
+					t.Errorf("Anchor %d: inclusion of first record failed: %v", a.Index, err)
+				}
+			}
+
+			for _, r := range records {
+				proof, err := logger.InclusionProof(r.Index)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := VerifyInclusion(LeafHash(r, false), proof, root); err != nil {
+					t.Errorf("Record %d: VerifyInclusion failed: %v", r.Index, err)
+				}
+			}
+
+			// A modified record no longer proves against the root.
+			proof, err := logger.InclusionProof(6)
+			if err != nil {
+				t.Fatal(err)
+			}
+			modified := records[5]
+			modified.Msg = []byte("forged")
+			if err := VerifyInclusion(LeafHash(modified, false), proof, root); !errors.Is(err, ErrInvalidProof) {
+				t.Errorf("Expected ErrInvalidProof for modified record, got: %v", err)
+			}
+			proof.Index = 7
+			if err := VerifyInclusion(LeafHash(records[5], false), proof, root); !errors.Is(err, ErrInvalidProof) {
+				t.Errorf("Expected ErrInvalidProof for wrong index, got: %v", err)
+			}
+
+			if _, err := logger.InclusionProof(12); err == nil {
+				t.Error("Expected error for index beyond tree")
+			}
+
+			// A logger started over the existing store does not resume it;
+			// the store's tail still proves the records.
+			reopened, err := New(Config{}, store)
+			if err != nil {
+				t.Fatalf("New over existing store: %v", err)
+			}
+			if rsize, _ := reopened.TreeHead(); rsize != 0 {
+				t.Errorf("Expected a new logger's tree to be empty, got size %d", rsize)
+			}
+			tail, _, err = store.Tail()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tail.Index != size || tail.TreeRoot != root {
+				t.Errorf("Stored tail (%d) differs from the tree head (%d)", tail.Index, size)
+			}
+			proof, err = ProveInclusion(store, 11, tail.Index)
+			if err != nil {
+				t.Fatalf("ProveInclusion over the stored log: %v", err)
+			}
+			if err := VerifyInclusion(LeafHash(records[10], false), proof, tail.TreeRoot); err != nil {
+				t.Errorf("VerifyInclusion against the stored tail: %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreLeaves_RecordsMissing(t *testing.T) {
+	store := NewMemoryStore()
+	logger, err := New(Config{AnchorEvery: 4}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records := readAllRecords(t, store)
+	if err := store.Prune(5); err != nil {
+		t.Fatal(err)
+	}
+
+	// The store's own tree state still covers pruned records.
+	proof, err := ProveInclusion(store, 7, 10)
+	if err != nil {
+		t.Fatalf("ProveInclusion after prune: %v", err)
+	}
+	if err := VerifyInclusion(LeafHash(records[6], false), proof, MerkleRoot(records, false)); err != nil {
+		t.Errorf("VerifyInclusion after prune: %v", err)
+	}
+
+	// Without tree state the pruned leaves cannot be rebuilt from records.
+	plain := plainStore{store}
+	if _, err := ProveInclusion(plain, 7, 10); err == nil {
+		t.Error("Expected error rebuilding leaves of pruned records")
+	}
+	if _, err := New(Config{}, plain); err != nil {
+		t.Errorf("New must not read the store: %v", err)
+	}
+
+	// Short stores are reported as such.
+	if _, err := ProveInclusion(plainStore{NewMemoryStore()}, 1, 3); err == nil {
+		t.Error("Expected error for records missing from store")
+	}
+}
+
+func TestLeafHash_EnvelopeShapedMessagesOnPlainLog(t *testing.T) {
+	store := NewMemoryStore()
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A plain log stores whatever it is given, including bytes shaped like
+	// a content envelope.
+	sealed, err := sealMessage([]byte("pay alice 10"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range [][]byte{[]byte("entry"), sealed, []byte("entry")} {
+		if _, err := logger.Append(msg, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records := readAllRecords(t, store)
+	legit := records[1]
+	forged := mustRedactRecord(t, legit, Redaction{Index: legit.Index, Commitment: mustCommitment(t, legit)})
+
+	// On a redactable log both would be the same leaf; on a plain log every
+	// byte of the message counts.
+	if LeafHash(legit, true) != LeafHash(forged, true) {
+		t.Fatal("Expected a redacted record to keep its leaf on a redactable log")
+	}
+	if LeafHash(legit, false) == LeafHash(forged, false) {
+		t.Fatal("Different messages share a leaf on a plain log")
+	}
+	proof, err := ProveInclusion(store, legit.Index, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := MerkleRoot(records, false)
+	if err := VerifyInclusion(LeafHash(legit, false), proof, root); err != nil {
+		t.Fatalf("VerifyInclusion of the stored record: %v", err)
+	}
+	if err := VerifyInclusion(LeafHash(forged, false), proof, root); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("Expected ErrInvalidProof for the substituted message, got: %v", err)
+	}
+	if got, ok := messageOf(legit, false); !ok || string(got) != string(sealed) {
+		t.Errorf("Expected the plain log's message verbatim, got %q", got)
+	}
+}
+
+func TestCloseMessage_TreeRoot(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-merkle-close-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logID := "merkle-close"
+	commit, openMsg, err := logger.InitProtocol(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	closeMsg, err := logger.CloseProtocol(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := readAllRecords(t, store)
+	if closeMsg.FinalRoot != MerkleRoot(records, false) {
+		t.Fatal("CloseMessage does not carry the final tree root")
+	}
+
+	roundTrip, err := FromProtoCloseMessage(ToProtoCloseMessage(closeMsg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roundTrip.FinalRoot != closeMsg.FinalRoot {
+		t.Error("FinalRoot lost in protobuf conversion")
+	}
+
+	ts := NewTrustedServer()
+	ts.RegisterLog(commit)
+	ts.RegisterOpen(openMsg)
+	if err := ts.AcceptClosure(closeMsg); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.FinalVerify(logID, records); err != nil {
+		t.Fatalf("FinalVerify failed: %v", err)
+	}
+
+	forged := closeMsg
+	forged.FinalRoot[0] ^= 0xFF
+	if err := VerifyCloseMessage(records, forged); err == nil {
+		t.Error("Expected error for mismatched tree root")
+	}
+}
+
+func TestConsistencyProof(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-consistency-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const total = 17
+	roots := make([][32]byte, total+1)
+	for i := 1; i <= total; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		_, roots[i] = logger.TreeHead()
+	}
+
+	for m := uint64(1); m <= total; m++ {
+		for n := m; n <= total; n++ {
+			proof, err := logger.ConsistencyProof(m, n)
+			if err != nil {
+				t.Fatalf("ConsistencyProof(%d, %d): %v", m, n, err)
+			}
+			if err := VerifyConsistency(proof, roots[m], roots[n]); err != nil {
+				t.Errorf("VerifyConsistency(%d, %d) failed: %v", m, n, err)
+			}
+			if m < n {
+				if err := VerifyConsistency(proof, roots[n], roots[n]); !errors.Is(err, ErrInvalidProof) {
+					t.Errorf("VerifyConsistency(%d, %d) accepted wrong old root", m, n)
+				}
+				if len(proof.Path) > 0 {
+					bad := proof
+					bad.Path = append([][32]byte(nil), proof.Path...)
+					bad.Path[len(bad.Path)-1][0] ^= 0xFF
+					if err := VerifyConsistency(bad, roots[m], roots[n]); !errors.Is(err, ErrInvalidProof) {
+						t.Errorf("VerifyConsistency(%d, %d) accepted modified path", m, n)
+					}
+				}
+			}
+		}
+	}
+
+	if _, err := logger.ConsistencyProof(5, total+1); err == nil {
+		t.Error("Expected error for size beyond tree")
+	}
+	if _, err := logger.ConsistencyProof(0, 5); err == nil {
+		t.Error("Expected error for empty old tree")
+	}
+}
@@ -0,0 +1,89 @@
+package securelog
+
+import (
+	"crypto/ed25519"
+	"time"
+)
+
+// DefaultProgressEvery is how many records are verified between progress
+// callbacks when VerifyOptions.ProgressEvery is zero.
+const DefaultProgressEvery = 1000
+
+// Progress describes how far a verification run has got.
+type Progress struct {
+	Verified uint64        // records verified so far in this run
+	Index    uint64        // index of the last verified record
+	Elapsed  time.Duration // time since verification started
+	Rate     float64       // records verified per second
+}
+
+// ProgressFunc receives progress updates during verification.
+// It is called synchronously, so it should return quickly. The final update
+// covering the whole run is only sent when verification succeeds.
+type ProgressFunc func(Progress)
+
+// VerifyOptions tunes the context-aware verification entry points.
+// The zero value verifies without progress reporting or policy checks.
+type VerifyOptions struct {
+	Progress      ProgressFunc    // optional progress callback
+	ProgressEvery uint64          // records between callbacks (0 = DefaultProgressEvery)
+	Timestamps    TimestampPolicy // optional timestamp plausibility checks
+	OnRedacted    func(Redaction) // optional; called for each authentic redacted record
+
+	// Redactable must be set for logs written with Config.Redactable: their
+	// MACs cover message commitments, and every redaction marker must be
+	// signed by RedactionKey, T's redaction key (TrustedServer.RedactionPublicKey).
+	Redactable   bool
+	RedactionKey ed25519.PublicKey
+}
+
+// progressTracker counts verified records and fires the progress callback.
+// A nil tracker is valid and does nothing.
+type progressTracker struct {
+	fn       ProgressFunc
+	every    uint64
+	start    time.Time
+	verified uint64
+	index    uint64
+}
+
+func newProgressTracker(opts VerifyOptions) *progressTracker {
+	if opts.Progress == nil {
+		return nil
+	}
+	every := opts.ProgressEvery
+	if every == 0 {
+		every = DefaultProgressEvery
+	}
+	return &progressTracker{fn: opts.Progress, every: every, start: time.Now()}
+}
+
+// step records one verified entry and reports every p.every entries.
+func (p *progressTracker) step(idx uint64) {
+	if p == nil {
+		return
+	}
+	p.verified++
+	p.index = idx
+	if p.verified%p.every == 0 {
+		p.report()
+	}
+}
+
+// finish reports the final state of a run that ended with err, unless it was
+// just reported. Failed or cancelled runs are not reported as complete.
+func (p *progressTracker) finish(err error) {
+	if p == nil || err != nil || (p.verified != 0 && p.verified%p.every == 0) {
+		return
+	}
+	p.report()
+}
+
+func (p *progressTracker) report() {
+	elapsed := time.Since(p.start)
+	var rate float64
+	if secs := elapsed.Seconds(); secs > 0 {
+		rate = float64(p.verified) / secs
+	}
+	p.fn(Progress{Verified: p.verified, Index: p.index, Elapsed: elapsed, Rate: rate})
+}
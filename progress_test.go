@@ -0,0 +1,184 @@
+package securelog
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+func TestVerifyChainContext_Progress(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-progress-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, _ := logger.GetInitialKeys()
+
+	for i := 0; i < 25; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records := readAllRecords(t, store)
+
+	var updates []Progress
+	opts := VerifyOptions{
+		Progress:      func(p Progress) { updates = append(updates, p) },
+		ProgressEvery: 10,
+	}
+	var zeroTag [32]byte
+	if _, err := VerifyChainContext(context.Background(), records, 0, a0, zeroTag, true, opts); err != nil {
+		t.Fatalf("VerifyChainContext failed: %v", err)
+	}
+
+	// Updates at 10, 20 and a final one at 25.
+	if len(updates) != 3 {
+		t.Fatalf("Expected 3 progress updates, got %d", len(updates))
+	}
+	for i, want := range []uint64{10, 20, 25} {
+		if updates[i].Verified != want || updates[i].Index != want {
+			t.Errorf("Update %d: expected %d verified at index %d, got %+v", i, want, want, updates[i])
+		}
+	}
+	if updates[2].Rate <= 0 {
+		t.Errorf("Expected positive rate, got %f", updates[2].Rate)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelAt := VerifyOptions{
+		Progress: func(p Progress) {
+			if p.Verified == 5 {
+				cancel()
+			}
+		},
+		ProgressEvery: 5,
+	}
+	if _, err := VerifyChainContext(ctx, records, 0, a0, zeroTag, true, cancelAt); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+
+	// A failed run reports only the intervals it got through, never a final update.
+	tampered := append([]Record(nil), records...)
+	tampered[16].TagV[0] ^= 0xFF
+	updates = nil
+	if _, err := VerifyChainContext(context.Background(), tampered, 0, a0, zeroTag, true, opts); err != ErrTagMismatch {
+		t.Fatalf("Expected ErrTagMismatch, got: %v", err)
+	}
+	if len(updates) != 1 || updates[0].Verified != 10 {
+		t.Errorf("Expected a single update at 10 for a failed run, got %+v", updates)
+	}
+}
+
+func TestVerifiers_Cancelled(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-cancel-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	transport, err := NewFolderTransport(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logID := "cancel-log"
+	store, err := transport.GetLogStore(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	logger, err := NewRemoteLogger(Config{}, store, transport, logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := transport.LoadCommitment(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing the logger closed its store; verifiers open their own.
+	store, err = transport.GetLogStoreReadOnly(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	semi := NewSemiTrustedVerifier(store)
+	if err := semi.VerifyFromAnchorContext(ctx, Anchor{Key: commit.KeyA0}, VerifyOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("SemiTrustedVerifier: expected context.Canceled, got: %v", err)
+	}
+
+	trusted := NewTrustedVerifier(store, commit.KeyB0)
+	if err := trusted.VerifyAllContext(ctx, VerifyOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("TrustedVerifier: expected context.Canceled, got: %v", err)
+	}
+	if len(trusted.TrustedAnchors()) != 0 {
+		t.Error("Cancelled run must not cache checkpoints")
+	}
+
+	if err := transport.VerifyLogContext(ctx, logID, VerifyOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("FolderTransport: expected context.Canceled, got: %v", err)
+	}
+
+	ts := NewTrustedServer()
+	ts.RegisterLog(commit)
+	open, err := transport.LoadOpen(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.RegisterOpen(open)
+	closeMsg, err := transport.LoadClosure(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.AcceptClosure(closeMsg); err != nil {
+		t.Fatal(err)
+	}
+	records := readAllRecords(t, store)
+	if err := ts.FinalVerifyContext(ctx, logID, records, VerifyOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("TrustedServer: expected context.Canceled, got: %v", err)
+	}
+
+	// Same entry points succeed with a live context and report progress.
+	var last Progress
+	opts := VerifyOptions{Progress: func(p Progress) { last = p }}
+	if err := ts.FinalVerifyContext(context.Background(), logID, records, opts); err != nil {
+		t.Fatalf("FinalVerifyContext failed: %v", err)
+	}
+	if last.Verified != uint64(len(records)) {
+		t.Errorf("Expected %d verified records, got %d", len(records), last.Verified)
+	}
+	if err := transport.VerifyLogContext(context.Background(), logID, opts); err != nil {
+		t.Fatalf("VerifyLogContext failed: %v", err)
+	}
+	if err := trusted.VerifyAllContext(context.Background(), opts); err != nil {
+		t.Fatalf("VerifyAllContext failed: %v", err)
+	}
+}
@@ -26,11 +26,14 @@ const (
 // This implements the Log File Initialization protocol from Section 4.2.
 type InitCommitment struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	LogId         string                 `protobuf:"bytes,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`                 // Unique log identifier
-	StartTime     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`     // When the log was started
-	KeyA0         []byte                 `protobuf:"bytes,3,opt,name=key_a0,json=keyA0,proto3" json:"key_a0,omitempty"`                 // A_0 - initial verifier chain key (32 bytes)
-	KeyB0         []byte                 `protobuf:"bytes,4,opt,name=key_b0,json=keyB0,proto3" json:"key_b0,omitempty"`                 // B_0 - initial trusted server chain key (32 bytes)
-	UpdateFreq    uint64                 `protobuf:"varint,5,opt,name=update_freq,json=updateFreq,proto3" json:"update_freq,omitempty"` // Key update frequency (UPD in the paper)
+	LogId         string                 `protobuf:"bytes,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`                     // Unique log identifier
+	StartTime     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`         // When the log was started
+	KeyA0         []byte                 `protobuf:"bytes,3,opt,name=key_a0,json=keyA0,proto3" json:"key_a0,omitempty"`                     // A_0 - initial verifier chain key (32 bytes)
+	KeyB0         []byte                 `protobuf:"bytes,4,opt,name=key_b0,json=keyB0,proto3" json:"key_b0,omitempty"`                     // B_0 - initial trusted server chain key (32 bytes)
+	UpdateFreq    uint64                 `protobuf:"varint,5,opt,name=update_freq,json=updateFreq,proto3" json:"update_freq,omitempty"`     // Key update frequency (UPD in the paper)
+	KeyE0         []byte                 `protobuf:"bytes,6,opt,name=key_e0,json=keyE0,proto3" json:"key_e0,omitempty"`                     // E_0 - initial encryption chain key (32 bytes, optional)
+	TreeHeadKey   []byte                 `protobuf:"bytes,7,opt,name=tree_head_key,json=treeHeadKey,proto3" json:"tree_head_key,omitempty"` // Ed25519 key the logger signs tree heads with (32 bytes, optional)
+	Redactable    bool                   `protobuf:"varint,8,opt,name=redactable,proto3" json:"redactable,omitempty"`                       // Records are redactable envelopes
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -100,6 +103,27 @@ func (x *InitCommitment) GetUpdateFreq() uint64 {
 	return 0
 }
 
+func (x *InitCommitment) GetKeyE0() []byte {
+	if x != nil {
+		return x.KeyE0
+	}
+	return nil
+}
+
+func (x *InitCommitment) GetTreeHeadKey() []byte {
+	if x != nil {
+		return x.TreeHeadKey
+	}
+	return nil
+}
+
+func (x *InitCommitment) GetRedactable() bool {
+	if x != nil {
+		return x.Redactable
+	}
+	return false
+}
+
 // OpenMessage records the fact that a log was opened and the first entry appended.
 type OpenMessage struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -186,6 +210,7 @@ type CloseMessage struct {
 	FinalIndex    uint64                 `protobuf:"varint,3,opt,name=final_index,json=finalIndex,proto3" json:"final_index,omitempty"` // f - index of last entry
 	FinalTagV     []byte                 `protobuf:"bytes,4,opt,name=final_tag_v,json=finalTagV,proto3" json:"final_tag_v,omitempty"`   // μ_V,f (32 bytes)
 	FinalTagT     []byte                 `protobuf:"bytes,5,opt,name=final_tag_t,json=finalTagT,proto3" json:"final_tag_t,omitempty"`   // μ_T,f (32 bytes)
+	FinalRoot     []byte                 `protobuf:"bytes,6,opt,name=final_root,json=finalRoot,proto3" json:"final_root,omitempty"`     // Merkle root over entries 1..f (32 bytes, optional)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -255,6 +280,13 @@ func (x *CloseMessage) GetFinalTagT() []byte {
 	return nil
 }
 
+func (x *CloseMessage) GetFinalRoot() []byte {
+	if x != nil {
+		return x.FinalRoot
+	}
+	return nil
+}
+
 // Record is the persisted form used by Store.
 // Contains both MAC chains for dual verification.
 type Record struct {
@@ -431,18 +463,80 @@ func (x *VerifyRequest) GetRecords() []*Record {
 	return nil
 }
 
+// Finding is a policy violation detected on an otherwise authentic record
+type Finding struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         uint64                 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`  // Entry index
+	Kind          string                 `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`     // Violation kind (e.g. "timestamp earlier than previous entry")
+	Detail        string                 `protobuf:"bytes,3,opt,name=detail,proto3" json:"detail,omitempty"` // Human-readable detail
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Finding) Reset() {
+	*x = Finding{}
+	mi := &file_proto_securelog_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Finding) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Finding) ProtoMessage() {}
+
+func (x *Finding) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_securelog_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Finding.ProtoReflect.Descriptor instead.
+func (*Finding) Descriptor() ([]byte, []int) {
+	return file_proto_securelog_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Finding) GetIndex() uint64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *Finding) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Finding) GetDetail() string {
+	if x != nil {
+		return x.Detail
+	}
+	return ""
+}
+
 // VerifyResponse is returned by the trusted server after verification
 type VerifyResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Verified      bool                   `protobuf:"varint,1,opt,name=verified,proto3" json:"verified,omitempty"`
 	ErrorMessage  string                 `protobuf:"bytes,2,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"` // Empty if verified=true
+	Findings      []*Finding             `protobuf:"bytes,3,rep,name=findings,proto3" json:"findings,omitempty"`                             // Policy findings, if verification failed because of them
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *VerifyResponse) Reset() {
 	*x = VerifyResponse{}
-	mi := &file_proto_securelog_proto_msgTypes[6]
+	mi := &file_proto_securelog_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -454,7 +548,7 @@ func (x *VerifyResponse) String() string {
 func (*VerifyResponse) ProtoMessage() {}
 
 func (x *VerifyResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_securelog_proto_msgTypes[6]
+	mi := &file_proto_securelog_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -467,7 +561,7 @@ func (x *VerifyResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use VerifyResponse.ProtoReflect.Descriptor instead.
 func (*VerifyResponse) Descriptor() ([]byte, []int) {
-	return file_proto_securelog_proto_rawDescGZIP(), []int{6}
+	return file_proto_securelog_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *VerifyResponse) GetVerified() bool {
@@ -484,11 +578,318 @@ func (x *VerifyResponse) GetErrorMessage() string {
 	return ""
 }
 
+func (x *VerifyResponse) GetFindings() []*Finding {
+	if x != nil {
+		return x.Findings
+	}
+	return nil
+}
+
+// TreeHead is a party's view of a log: Merkle tree size and root at a point in time
+type TreeHead struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LogId         string                 `protobuf:"bytes,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
+	Size          uint64                 `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"` // Number of entries in the tree
+	Root          []byte                 `protobuf:"bytes,3,opt,name=root,proto3" json:"root,omitempty"`  // Merkle root (32 bytes)
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TreeHead) Reset() {
+	*x = TreeHead{}
+	mi := &file_proto_securelog_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TreeHead) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TreeHead) ProtoMessage() {}
+
+func (x *TreeHead) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_securelog_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TreeHead.ProtoReflect.Descriptor instead.
+func (*TreeHead) Descriptor() ([]byte, []int) {
+	return file_proto_securelog_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *TreeHead) GetLogId() string {
+	if x != nil {
+		return x.LogId
+	}
+	return ""
+}
+
+func (x *TreeHead) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *TreeHead) GetRoot() []byte {
+	if x != nil {
+		return x.Root
+	}
+	return nil
+}
+
+func (x *TreeHead) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+// SignedTreeHead is a TreeHead signed with Ed25519
+type SignedTreeHead struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Head          *TreeHead              `protobuf:"bytes,1,opt,name=head,proto3" json:"head,omitempty"`
+	PublicKey     []byte                 `protobuf:"bytes,2,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"` // Ed25519 public key (32 bytes)
+	Signature     []byte                 `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`                  // Ed25519 signature (64 bytes)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SignedTreeHead) Reset() {
+	*x = SignedTreeHead{}
+	mi := &file_proto_securelog_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SignedTreeHead) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignedTreeHead) ProtoMessage() {}
+
+func (x *SignedTreeHead) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_securelog_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignedTreeHead.ProtoReflect.Descriptor instead.
+func (*SignedTreeHead) Descriptor() ([]byte, []int) {
+	return file_proto_securelog_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SignedTreeHead) GetHead() *TreeHead {
+	if x != nil {
+		return x.Head
+	}
+	return nil
+}
+
+func (x *SignedTreeHead) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *SignedTreeHead) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// ConsistencyProof proves that the tree of old_size entries is a prefix of the tree of new_size entries
+type ConsistencyProof struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OldSize       uint64                 `protobuf:"varint,1,opt,name=old_size,json=oldSize,proto3" json:"old_size,omitempty"`
+	NewSize       uint64                 `protobuf:"varint,2,opt,name=new_size,json=newSize,proto3" json:"new_size,omitempty"`
+	Path          [][]byte               `protobuf:"bytes,3,rep,name=path,proto3" json:"path,omitempty"` // RFC 6962 consistency path (32 bytes each)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConsistencyProof) Reset() {
+	*x = ConsistencyProof{}
+	mi := &file_proto_securelog_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConsistencyProof) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConsistencyProof) ProtoMessage() {}
+
+func (x *ConsistencyProof) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_securelog_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConsistencyProof.ProtoReflect.Descriptor instead.
+func (*ConsistencyProof) Descriptor() ([]byte, []int) {
+	return file_proto_securelog_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ConsistencyProof) GetOldSize() uint64 {
+	if x != nil {
+		return x.OldSize
+	}
+	return 0
+}
+
+func (x *ConsistencyProof) GetNewSize() uint64 {
+	if x != nil {
+		return x.NewSize
+	}
+	return 0
+}
+
+func (x *ConsistencyProof) GetPath() [][]byte {
+	if x != nil {
+		return x.Path
+	}
+	return nil
+}
+
+// AuditGrant entitles an auditor to verify records [from, to] on the V-chain
+type AuditGrant struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LogId         string                 `protobuf:"bytes,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
+	Auditor       string                 `protobuf:"bytes,2,opt,name=auditor,proto3" json:"auditor,omitempty"`                   // Who the grant was issued to
+	From          uint64                 `protobuf:"varint,3,opt,name=from,proto3" json:"from,omitempty"`                        // a - first record covered
+	To            uint64                 `protobuf:"varint,4,opt,name=to,proto3" json:"to,omitempty"`                            // b - last record covered
+	Key           []byte                 `protobuf:"bytes,5,opt,name=key,proto3" json:"key,omitempty"`                           // A_{a-1} (32 bytes)
+	StartTag      []byte                 `protobuf:"bytes,6,opt,name=start_tag,json=startTag,proto3" json:"start_tag,omitempty"` // μ_V,a-1 (32 bytes, zero when from == 1)
+	EndTag        []byte                 `protobuf:"bytes,7,opt,name=end_tag,json=endTag,proto3" json:"end_tag,omitempty"`       // μ_V,b (32 bytes)
+	IssuedAt      *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=issued_at,json=issuedAt,proto3" json:"issued_at,omitempty"`
+	Redactable    bool                   `protobuf:"varint,9,opt,name=redactable,proto3" json:"redactable,omitempty"` // The log's records are redactable envelopes
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuditGrant) Reset() {
+	*x = AuditGrant{}
+	mi := &file_proto_securelog_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuditGrant) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuditGrant) ProtoMessage() {}
+
+func (x *AuditGrant) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_securelog_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuditGrant.ProtoReflect.Descriptor instead.
+func (*AuditGrant) Descriptor() ([]byte, []int) {
+	return file_proto_securelog_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *AuditGrant) GetLogId() string {
+	if x != nil {
+		return x.LogId
+	}
+	return ""
+}
+
+func (x *AuditGrant) GetAuditor() string {
+	if x != nil {
+		return x.Auditor
+	}
+	return ""
+}
+
+func (x *AuditGrant) GetFrom() uint64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *AuditGrant) GetTo() uint64 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+func (x *AuditGrant) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *AuditGrant) GetStartTag() []byte {
+	if x != nil {
+		return x.StartTag
+	}
+	return nil
+}
+
+func (x *AuditGrant) GetEndTag() []byte {
+	if x != nil {
+		return x.EndTag
+	}
+	return nil
+}
+
+func (x *AuditGrant) GetIssuedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.IssuedAt
+	}
+	return nil
+}
+
+func (x *AuditGrant) GetRedactable() bool {
+	if x != nil {
+		return x.Redactable
+	}
+	return false
+}
+
 var File_proto_securelog_proto protoreflect.FileDescriptor
 
 const file_proto_securelog_proto_rawDesc = "" +
 	"\n" +
-	"\x15proto/securelog.proto\x12\tsecurelog\x1a\x1fgoogle/protobuf/timestamp.proto\"\xb1\x01\n" +
+	"\x15proto/securelog.proto\x12\tsecurelog\x1a\x1fgoogle/protobuf/timestamp.proto\"\x8c\x02\n" +
 	"\x0eInitCommitment\x12\x15\n" +
 	"\x06log_id\x18\x01 \x01(\tR\x05logId\x129\n" +
 	"\n" +
@@ -496,14 +897,19 @@ const file_proto_securelog_proto_rawDesc = "" +
 	"\x06key_a0\x18\x03 \x01(\fR\x05keyA0\x12\x15\n" +
 	"\x06key_b0\x18\x04 \x01(\fR\x05keyB0\x12\x1f\n" +
 	"\vupdate_freq\x18\x05 \x01(\x04R\n" +
-	"updateFreq\"\xbe\x01\n" +
+	"updateFreq\x12\x15\n" +
+	"\x06key_e0\x18\x06 \x01(\fR\x05keyE0\x12\"\n" +
+	"\rtree_head_key\x18\a \x01(\fR\vtreeHeadKey\x12\x1e\n" +
+	"\n" +
+	"redactable\x18\b \x01(\bR\n" +
+	"redactable\"\xbe\x01\n" +
 	"\vOpenMessage\x12\x15\n" +
 	"\x06log_id\x18\x01 \x01(\tR\x05logId\x127\n" +
 	"\topen_time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\bopenTime\x12\x1f\n" +
 	"\vfirst_index\x18\x03 \x01(\x04R\n" +
 	"firstIndex\x12\x1e\n" +
 	"\vfirst_tag_v\x18\x04 \x01(\fR\tfirstTagV\x12\x1e\n" +
-	"\vfirst_tag_t\x18\x05 \x01(\fR\tfirstTagT\"\xc1\x01\n" +
+	"\vfirst_tag_t\x18\x05 \x01(\fR\tfirstTagT\"\xe0\x01\n" +
 	"\fCloseMessage\x12\x15\n" +
 	"\x06log_id\x18\x01 \x01(\tR\x05logId\x129\n" +
 	"\n" +
@@ -511,7 +917,9 @@ const file_proto_securelog_proto_rawDesc = "" +
 	"\vfinal_index\x18\x03 \x01(\x04R\n" +
 	"finalIndex\x12\x1e\n" +
 	"\vfinal_tag_v\x18\x04 \x01(\fR\tfinalTagV\x12\x1e\n" +
-	"\vfinal_tag_t\x18\x05 \x01(\fR\tfinalTagT\"j\n" +
+	"\vfinal_tag_t\x18\x05 \x01(\fR\tfinalTagT\x12\x1d\n" +
+	"\n" +
+	"final_root\x18\x06 \x01(\fR\tfinalRoot\"j\n" +
 	"\x06Record\x12\x14\n" +
 	"\x05index\x18\x01 \x01(\x04R\x05index\x12\x0e\n" +
 	"\x02ts\x18\x02 \x01(\x03R\x02ts\x12\x10\n" +
@@ -522,10 +930,42 @@ const file_proto_securelog_proto_rawDesc = "" +
 	"\arecords\x18\x01 \x03(\v2\x11.securelog.RecordR\arecords\"S\n" +
 	"\rVerifyRequest\x12\x15\n" +
 	"\x06log_id\x18\x01 \x01(\tR\x05logId\x12+\n" +
-	"\arecords\x18\x02 \x03(\v2\x11.securelog.RecordR\arecords\"Q\n" +
+	"\arecords\x18\x02 \x03(\v2\x11.securelog.RecordR\arecords\"K\n" +
+	"\aFinding\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x04R\x05index\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\x12\x16\n" +
+	"\x06detail\x18\x03 \x01(\tR\x06detail\"\x81\x01\n" +
 	"\x0eVerifyResponse\x12\x1a\n" +
 	"\bverified\x18\x01 \x01(\bR\bverified\x12#\n" +
-	"\rerror_message\x18\x02 \x01(\tR\ferrorMessageB#Z!github.com/karasz/securelog/protob\x06proto3"
+	"\rerror_message\x18\x02 \x01(\tR\ferrorMessage\x12.\n" +
+	"\bfindings\x18\x03 \x03(\v2\x12.securelog.FindingR\bfindings\"\x83\x01\n" +
+	"\bTreeHead\x12\x15\n" +
+	"\x06log_id\x18\x01 \x01(\tR\x05logId\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x04R\x04size\x12\x12\n" +
+	"\x04root\x18\x03 \x01(\fR\x04root\x128\n" +
+	"\ttimestamp\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\"v\n" +
+	"\x0eSignedTreeHead\x12'\n" +
+	"\x04head\x18\x01 \x01(\v2\x13.securelog.TreeHeadR\x04head\x12\x1d\n" +
+	"\n" +
+	"public_key\x18\x02 \x01(\fR\tpublicKey\x12\x1c\n" +
+	"\tsignature\x18\x03 \x01(\fR\tsignature\"\\\n" +
+	"\x10ConsistencyProof\x12\x19\n" +
+	"\bold_size\x18\x01 \x01(\x04R\aoldSize\x12\x19\n" +
+	"\bnew_size\x18\x02 \x01(\x04R\anewSize\x12\x12\n" +
+	"\x04path\x18\x03 \x03(\fR\x04path\"\x82\x02\n" +
+	"\n" +
+	"AuditGrant\x12\x15\n" +
+	"\x06log_id\x18\x01 \x01(\tR\x05logId\x12\x18\n" +
+	"\aauditor\x18\x02 \x01(\tR\aauditor\x12\x12\n" +
+	"\x04from\x18\x03 \x01(\x04R\x04from\x12\x0e\n" +
+	"\x02to\x18\x04 \x01(\x04R\x02to\x12\x10\n" +
+	"\x03key\x18\x05 \x01(\fR\x03key\x12\x1b\n" +
+	"\tstart_tag\x18\x06 \x01(\fR\bstartTag\x12\x17\n" +
+	"\aend_tag\x18\a \x01(\fR\x06endTag\x127\n" +
+	"\tissued_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\bissuedAt\x12\x1e\n" +
+	"\n" +
+	"redactable\x18\t \x01(\bR\n" +
+	"redactableB#Z!github.com/karasz/securelog/protob\x06proto3"
 
 var (
 	file_proto_securelog_proto_rawDescOnce sync.Once
@@ -539,7 +979,7 @@ func file_proto_securelog_proto_rawDescGZIP() []byte {
 	return file_proto_securelog_proto_rawDescData
 }
 
-var file_proto_securelog_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_proto_securelog_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_proto_securelog_proto_goTypes = []any{
 	(*InitCommitment)(nil),        // 0: securelog.InitCommitment
 	(*OpenMessage)(nil),           // 1: securelog.OpenMessage
@@ -547,20 +987,29 @@ var file_proto_securelog_proto_goTypes = []any{
 	(*Record)(nil),                // 3: securelog.Record
 	(*RecordBatch)(nil),           // 4: securelog.RecordBatch
 	(*VerifyRequest)(nil),         // 5: securelog.VerifyRequest
-	(*VerifyResponse)(nil),        // 6: securelog.VerifyResponse
-	(*timestamppb.Timestamp)(nil), // 7: google.protobuf.Timestamp
+	(*Finding)(nil),               // 6: securelog.Finding
+	(*VerifyResponse)(nil),        // 7: securelog.VerifyResponse
+	(*TreeHead)(nil),              // 8: securelog.TreeHead
+	(*SignedTreeHead)(nil),        // 9: securelog.SignedTreeHead
+	(*ConsistencyProof)(nil),      // 10: securelog.ConsistencyProof
+	(*AuditGrant)(nil),            // 11: securelog.AuditGrant
+	(*timestamppb.Timestamp)(nil), // 12: google.protobuf.Timestamp
 }
 var file_proto_securelog_proto_depIdxs = []int32{
-	7, // 0: securelog.InitCommitment.start_time:type_name -> google.protobuf.Timestamp
-	7, // 1: securelog.OpenMessage.open_time:type_name -> google.protobuf.Timestamp
-	7, // 2: securelog.CloseMessage.close_time:type_name -> google.protobuf.Timestamp
-	3, // 3: securelog.RecordBatch.records:type_name -> securelog.Record
-	3, // 4: securelog.VerifyRequest.records:type_name -> securelog.Record
-	5, // [5:5] is the sub-list for method output_type
-	5, // [5:5] is the sub-list for method input_type
-	5, // [5:5] is the sub-list for extension type_name
-	5, // [5:5] is the sub-list for extension extendee
-	0, // [0:5] is the sub-list for field type_name
+	12, // 0: securelog.InitCommitment.start_time:type_name -> google.protobuf.Timestamp
+	12, // 1: securelog.OpenMessage.open_time:type_name -> google.protobuf.Timestamp
+	12, // 2: securelog.CloseMessage.close_time:type_name -> google.protobuf.Timestamp
+	3,  // 3: securelog.RecordBatch.records:type_name -> securelog.Record
+	3,  // 4: securelog.VerifyRequest.records:type_name -> securelog.Record
+	6,  // 5: securelog.VerifyResponse.findings:type_name -> securelog.Finding
+	12, // 6: securelog.TreeHead.timestamp:type_name -> google.protobuf.Timestamp
+	8,  // 7: securelog.SignedTreeHead.head:type_name -> securelog.TreeHead
+	12, // 8: securelog.AuditGrant.issued_at:type_name -> google.protobuf.Timestamp
+	9,  // [9:9] is the sub-list for method output_type
+	9,  // [9:9] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_proto_securelog_proto_init() }
@@ -574,7 +1023,7 @@ func file_proto_securelog_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_securelog_proto_rawDesc), len(file_proto_securelog_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   7,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -1,6 +1,8 @@
 package securelog
 
 import (
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 
 	pb "github.com/karasz/securelog/proto"
@@ -10,12 +12,23 @@ import (
 // ToProtoInitCommitment converts InitCommitment to protobuf message
 func ToProtoInitCommitment(c InitCommitment) *pb.InitCommitment {
 	return &pb.InitCommitment{
-		LogId:      c.LogID,
-		StartTime:  timestamppb.New(c.StartTime),
-		KeyA0:      c.KeyA0[:],
-		KeyB0:      c.KeyB0[:],
-		UpdateFreq: c.UpdateFreq,
+		LogId:       c.LogID,
+		StartTime:   timestamppb.New(c.StartTime),
+		KeyA0:       c.KeyA0[:],
+		KeyB0:       c.KeyB0[:],
+		UpdateFreq:  c.UpdateFreq,
+		KeyE0:       keyE0Bytes(c.KeyE0),
+		TreeHeadKey: c.TreeHeadKey,
+		Redactable:  c.Redactable,
+	}
+}
+
+// keyE0Bytes omits an unset encryption key from the wire form.
+func keyE0Bytes(k [KeySize]byte) []byte {
+	if isZero32(k) {
+		return nil
 	}
+	return k[:]
 }
 
 // FromProtoInitCommitment converts protobuf message to InitCommitment
@@ -23,6 +36,7 @@ func FromProtoInitCommitment(p *pb.InitCommitment) (InitCommitment, error) {
 	var c InitCommitment
 	c.LogID = p.LogId
 	c.StartTime = p.StartTime.AsTime()
+	c.Redactable = p.Redactable
 
 	if len(p.KeyA0) != KeySize {
 		return c, fmt.Errorf("invalid KeyA0 size: expected %d, got %d", KeySize, len(p.KeyA0))
@@ -34,6 +48,22 @@ func FromProtoInitCommitment(p *pb.InitCommitment) (InitCommitment, error) {
 	}
 	copy(c.KeyB0[:], p.KeyB0)
 
+	switch len(p.KeyE0) {
+	case 0:
+	case KeySize:
+		copy(c.KeyE0[:], p.KeyE0)
+	default:
+		return c, fmt.Errorf("invalid KeyE0 size: expected %d, got %d", KeySize, len(p.KeyE0))
+	}
+
+	switch len(p.TreeHeadKey) {
+	case 0:
+	case ed25519.PublicKeySize:
+		c.TreeHeadKey = append(ed25519.PublicKey(nil), p.TreeHeadKey...)
+	default:
+		return c, fmt.Errorf("invalid TreeHeadKey size: expected %d, got %d", ed25519.PublicKeySize, len(p.TreeHeadKey))
+	}
+
 	c.UpdateFreq = p.UpdateFreq
 	return c, nil
 }
@@ -77,6 +107,7 @@ func ToProtoCloseMessage(c CloseMessage) *pb.CloseMessage {
 		FinalIndex: c.FinalIndex,
 		FinalTagV:  c.FinalTagV[:],
 		FinalTagT:  c.FinalTagT[:],
+		FinalRoot:  c.FinalRoot[:],
 	}
 }
 
@@ -97,6 +128,12 @@ func FromProtoCloseMessage(p *pb.CloseMessage) (CloseMessage, error) {
 	}
 	copy(c.FinalTagT[:], p.FinalTagT)
 
+	// FinalRoot is optional for compatibility with older loggers.
+	if len(p.FinalRoot) != 0 && len(p.FinalRoot) != 32 {
+		return c, fmt.Errorf("invalid FinalRoot size: expected 32, got %d", len(p.FinalRoot))
+	}
+	copy(c.FinalRoot[:], p.FinalRoot)
+
 	return c, nil
 }
 
@@ -152,3 +189,146 @@ func FromProtoRecords(pRecords []*pb.Record) ([]Record, error) {
 	}
 	return result, nil
 }
+
+// ToProtoFindings converts policy findings to protobuf messages
+func ToProtoFindings(findings []Finding) []*pb.Finding {
+	result := make([]*pb.Finding, len(findings))
+	for i, f := range findings {
+		result[i] = &pb.Finding{
+			Index:  f.Index,
+			Kind:   f.Kind.Error(),
+			Detail: f.Detail,
+		}
+	}
+	return result
+}
+
+// FromProtoFindings converts protobuf messages to policy findings.
+// Known kinds are mapped back to their sentinel errors so errors.Is keeps working.
+func FromProtoFindings(pFindings []*pb.Finding) []Finding {
+	result := make([]Finding, len(pFindings))
+	for i, p := range pFindings {
+		kind := findingKind(p.Kind)
+		result[i] = Finding{Index: p.Index, Kind: kind, Detail: p.Detail}
+	}
+	return result
+}
+
+func findingKind(s string) error {
+	for _, k := range []error{
+		ErrTimestampRegression,
+		ErrTimestampBeforeStart,
+		ErrTimestampAfterClose,
+		ErrTimestampGap,
+		ErrTimestampFuture,
+	} {
+		if k.Error() == s {
+			return k
+		}
+	}
+	return errors.New(s)
+}
+
+// ToProtoSignedTreeHead converts SignedTreeHead to protobuf message
+func ToProtoSignedTreeHead(s SignedTreeHead) *pb.SignedTreeHead {
+	return &pb.SignedTreeHead{
+		Head: &pb.TreeHead{
+			LogId:     s.Head.LogID,
+			Size:      s.Head.Size,
+			Root:      s.Head.Root[:],
+			Timestamp: timestamppb.New(s.Head.Timestamp),
+		},
+		PublicKey: s.PublicKey,
+		Signature: s.Signature,
+	}
+}
+
+// FromProtoSignedTreeHead converts protobuf message to SignedTreeHead.
+// The signature is not verified; use SignedTreeHead.Verify.
+func FromProtoSignedTreeHead(p *pb.SignedTreeHead) (SignedTreeHead, error) {
+	var s SignedTreeHead
+	if p.Head == nil {
+		return s, errors.New("missing tree head")
+	}
+	s.Head.LogID = p.Head.LogId
+	s.Head.Size = p.Head.Size
+	s.Head.Timestamp = p.Head.Timestamp.AsTime()
+
+	if len(p.Head.Root) != 32 {
+		return s, fmt.Errorf("invalid Root size: expected 32, got %d", len(p.Head.Root))
+	}
+	copy(s.Head.Root[:], p.Head.Root)
+
+	if len(p.PublicKey) != ed25519.PublicKeySize {
+		return s, fmt.Errorf("invalid PublicKey size: expected %d, got %d", ed25519.PublicKeySize, len(p.PublicKey))
+	}
+	s.PublicKey = append(ed25519.PublicKey(nil), p.PublicKey...)
+	s.Signature = append([]byte(nil), p.Signature...)
+
+	return s, nil
+}
+
+// ToProtoConsistencyProof converts ConsistencyProof to protobuf message
+func ToProtoConsistencyProof(c ConsistencyProof) *pb.ConsistencyProof {
+	path := make([][]byte, len(c.Path))
+	for i := range c.Path {
+		path[i] = append([]byte(nil), c.Path[i][:]...)
+	}
+	return &pb.ConsistencyProof{OldSize: c.OldSize, NewSize: c.NewSize, Path: path}
+}
+
+// FromProtoConsistencyProof converts protobuf message to ConsistencyProof
+func FromProtoConsistencyProof(p *pb.ConsistencyProof) (ConsistencyProof, error) {
+	c := ConsistencyProof{OldSize: p.OldSize, NewSize: p.NewSize, Path: make([][32]byte, len(p.Path))}
+	for i, h := range p.Path {
+		if len(h) != 32 {
+			return c, fmt.Errorf("path %d: invalid hash size: expected 32, got %d", i, len(h))
+		}
+		copy(c.Path[i][:], h)
+	}
+	return c, nil
+}
+
+// ToProtoAuditGrant converts AuditGrant to protobuf message
+func ToProtoAuditGrant(g AuditGrant) *pb.AuditGrant {
+	return &pb.AuditGrant{
+		LogId:      g.LogID,
+		Auditor:    g.Auditor,
+		From:       g.From,
+		To:         g.To,
+		Key:        g.Key[:],
+		StartTag:   g.StartTag[:],
+		EndTag:     g.EndTag[:],
+		IssuedAt:   timestamppb.New(g.IssuedAt),
+		Redactable: g.Redactable,
+	}
+}
+
+// FromProtoAuditGrant converts protobuf message to AuditGrant
+func FromProtoAuditGrant(p *pb.AuditGrant) (AuditGrant, error) {
+	g := AuditGrant{
+		LogID:      p.LogId,
+		Auditor:    p.Auditor,
+		From:       p.From,
+		To:         p.To,
+		IssuedAt:   p.IssuedAt.AsTime(),
+		Redactable: p.Redactable,
+	}
+
+	if len(p.Key) != KeySize {
+		return g, fmt.Errorf("invalid Key size: expected %d, got %d", KeySize, len(p.Key))
+	}
+	copy(g.Key[:], p.Key)
+
+	if len(p.StartTag) != 32 {
+		return g, fmt.Errorf("invalid StartTag size: expected 32, got %d", len(p.StartTag))
+	}
+	copy(g.StartTag[:], p.StartTag)
+
+	if len(p.EndTag) != 32 {
+		return g, fmt.Errorf("invalid EndTag size: expected 32, got %d", len(p.EndTag))
+	}
+	copy(g.EndTag[:], p.EndTag)
+
+	return g, nil
+}
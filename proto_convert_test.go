@@ -11,10 +11,11 @@ import (
 
 func TestInitCommitmentProtoConversion(t *testing.T) {
 	now := time.Now()
-	var keyA0, keyB0 [KeySize]byte
+	var keyA0, keyB0, keyE0 [KeySize]byte
 	for i := range keyA0 {
 		keyA0[i] = byte(i)
 		keyB0[i] = byte(i + 100)
+		keyE0[i] = byte(i + 200)
 	}
 
 	original := InitCommitment{
@@ -23,10 +24,18 @@ func TestInitCommitmentProtoConversion(t *testing.T) {
 		KeyA0:      keyA0,
 		KeyB0:      keyB0,
 		UpdateFreq: 1000,
+		KeyE0:      keyE0,
 	}
 
-	// Convert to proto
-	pbMsg := ToProtoInitCommitment(original)
+	// Convert to proto and through the wire format
+	data, err := proto.Marshal(ToProtoInitCommitment(original))
+	if err != nil {
+		t.Fatalf("proto.Marshal failed: %v", err)
+	}
+	pbMsg := &pb.InitCommitment{}
+	if err := proto.Unmarshal(data, pbMsg); err != nil {
+		t.Fatalf("proto.Unmarshal failed: %v", err)
+	}
 
 	// Convert back
 	converted, err := FromProtoInitCommitment(pbMsg)
@@ -50,6 +59,15 @@ func TestInitCommitmentProtoConversion(t *testing.T) {
 	if converted.UpdateFreq != original.UpdateFreq {
 		t.Errorf("UpdateFreq mismatch: got %d, want %d", converted.UpdateFreq, original.UpdateFreq)
 	}
+	if converted.KeyE0 != original.KeyE0 {
+		t.Errorf("KeyE0 mismatch")
+	}
+
+	// A log without encryption sends no KeyE0.
+	original.KeyE0 = [KeySize]byte{}
+	if pbMsg := ToProtoInitCommitment(original); pbMsg.KeyE0 != nil {
+		t.Errorf("Expected no KeyE0 for an unencrypted log, got %x", pbMsg.KeyE0)
+	}
 }
 
 func TestOpenMessageProtoConversion(t *testing.T) {
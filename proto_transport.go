@@ -94,6 +94,24 @@ func (t *ProtoHTTPTransport) SendClosure(closeMsg CloseMessage) error {
 	return nil
 }
 
+// SendTreeHead submits a signed tree head via HTTP POST using protobuf.
+// A conflict with a head already recorded by T is reported as ErrTreeHeadConflict.
+func (t *ProtoHTTPTransport) SendTreeHead(sth SignedTreeHead) error {
+	data, err := proto.Marshal(ToProtoSignedTreeHead(sth))
+	if err != nil {
+		return fmt.Errorf("marshal tree head: %w", err)
+	}
+
+	url := t.BaseURL + "/api/v1/logs/treehead"
+	resp, err := t.Client.Post(url, "application/x-protobuf", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("post tree head: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return treeHeadResponseError(resp)
+}
+
 // SendLogFile sends the complete log file for verification using protobuf.
 func (t *ProtoHTTPTransport) SendLogFile(logID string, records []Record) (bool, error) {
 	req := &pb.VerifyRequest{
@@ -124,6 +142,9 @@ func (t *ProtoHTTPTransport) SendLogFile(logID string, records []Record) (bool,
 	}
 
 	if !verifyResp.Verified {
+		if len(verifyResp.Findings) > 0 {
+			return false, fmt.Errorf("verification failed: %w", &PolicyError{Findings: FromProtoFindings(verifyResp.Findings)})
+		}
 		return false, fmt.Errorf("verification failed: %s", verifyResp.ErrorMessage)
 	}
 
@@ -1,10 +1,14 @@
 package securelog
 
 import (
+	"context"
+	"crypto/ed25519"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -16,6 +20,11 @@ type InitCommitment struct {
 	KeyA0      [KeySize]byte // A_0 - initial verifier chain key
 	KeyB0      [KeySize]byte // B_0 - initial trusted server chain key
 	UpdateFreq uint64        // Key update frequency (UPD in the paper)
+	KeyE0      [KeySize]byte // E_0 - initial encryption chain key (zero if messages are not encrypted)
+	// TreeHeadKey is the key the logger signs tree heads with (nil if it does
+	// not). T only accepts tree heads for the log signed by this key.
+	TreeHeadKey ed25519.PublicKey
+	Redactable  bool // the log was written with Config.Redactable
 }
 
 // OpenMessage records the fact that a log was opened and the first entry appended.
@@ -35,6 +44,7 @@ type CloseMessage struct {
 	FinalIndex uint64    // f - index of last entry
 	FinalTagV  [32]byte  // μ_V,f
 	FinalTagT  [32]byte  // μ_T,f
+	FinalRoot  [32]byte  // Merkle root over records 1..f (zero if not provided)
 }
 
 // ErrLogAlreadyClosed is returned when attempting to close an already closed log.
@@ -63,12 +73,21 @@ func (l *Logger) InitProtocol(logID string) (InitCommitment, OpenMessage, error)
 		KeyA0:      l.keyV,
 		KeyB0:      l.keyT,
 		UpdateFreq: l.keyUpdateFrequency(),
+		Redactable: l.cfg.Redactable,
+	}
+	if l.cfg.Encrypt {
+		commit.KeyE0 = l.keyE
+	}
+	if l.cfg.TreeHeadKey != nil {
+		pub, _ := l.cfg.TreeHeadKey.Public().(ed25519.PublicKey)
+		commit.TreeHeadKey = append(ed25519.PublicKey(nil), pub...)
 	}
 
-	entry, err := l.Append([]byte("START"), now)
+	entry, err := l.appendProtocol([]byte("START"), now)
 	if err != nil {
 		return InitCommitment{}, OpenMessage{}, err
 	}
+	l.logID = logID
 
 	_, tagV, tagT := l.LastState()
 	open := OpenMessage{
@@ -91,15 +110,17 @@ func (*Logger) keyUpdateFrequency() uint64 {
 // After closing, no more entries can be appended.
 func (l *Logger) CloseProtocol(logID string) (CloseMessage, error) {
 	now := time.Now()
-	_, err := l.Append([]byte("CLOSE"), now)
+	_, err := l.appendProtocol([]byte("CLOSE"), now)
 	if err != nil {
 		return CloseMessage{}, err
 	}
 
 	idx, tagV, tagT := l.LastState()
+	_, root := l.TreeHead()
 
 	l.keyV = [KeySize]byte{}
 	l.keyT = [KeySize]byte{}
+	l.keyE = [KeySize]byte{}
 
 	return CloseMessage{
 		LogID:      logID,
@@ -107,12 +128,21 @@ func (l *Logger) CloseProtocol(logID string) (CloseMessage, error) {
 		FinalIndex: idx,
 		FinalTagV:  tagV,
 		FinalTagT:  tagT,
+		FinalRoot:  root,
 	}, nil
 }
 
 // VerifyCloseMessage verifies that a log was properly closed by checking
 // that the final entry contains the closing message and tags match.
+// When records form the whole log and the closure carries a tree root, the
+// Merkle root over the records must match it as well. The log must not be
+// redactable; FinalVerify checks the closure of either kind of log.
 func VerifyCloseMessage(records []Record, closeMsg CloseMessage) error {
+	return verifyCloseMessage(records, closeMsg, false)
+}
+
+// verifyCloseMessage is VerifyCloseMessage for a log of the given mode.
+func verifyCloseMessage(records []Record, closeMsg CloseMessage, redactable bool) error {
 	if len(records) == 0 {
 		return errors.New("no records to verify")
 	}
@@ -123,10 +153,17 @@ func VerifyCloseMessage(records []Record, closeMsg CloseMessage) error {
 		return errors.New("final index mismatch")
 	}
 
-	if string(lastRec.Msg) != "CLOSE" {
+	if msg, _ := messageOf(lastRec, redactable); string(msg) != "CLOSE" {
 		return errors.New("missing proper closing message")
 	}
 
+	if !isZero32(closeMsg.FinalRoot) && records[0].Index == 1 {
+		root := MerkleRoot(records, redactable)
+		if !hmac.Equal(root[:], closeMsg.FinalRoot[:]) {
+			return errors.New("final tree root mismatch")
+		}
+	}
+
 	return nil
 }
 
@@ -136,6 +173,61 @@ type TrustedServer struct {
 	commitments map[string]InitCommitment
 	opens       map[string]OpenMessage
 	closures    map[string]CloseMessage
+
+	anchorMu    sync.Mutex // guards anchorEvery and the anchors map, not the caches themselves
+	anchorEvery uint64
+	anchors     map[string]*trustedAnchorCache // T-chain checkpoints derived during verification
+
+	headMu    sync.Mutex
+	treeHeads map[string][]SignedTreeHead // signed views of each log, ordered by size
+
+	grantMu sync.Mutex
+	grants  map[string][]AuditGrant // audit grants issued per log
+
+	pruneMu     sync.Mutex
+	legalHolds  map[string]bool          // logs T refuses to authorise pruning for
+	prunePoints map[string]TrustedAnchor // latest authorised prune point per log
+
+	redactKey signingKey // signs redaction authorisations
+	pruneKey  signingKey // signs prune authorisations
+}
+
+// signingKey is an Ed25519 key T signs authorisations with. It is generated
+// on first use unless set beforehand.
+type signingKey struct {
+	mu  sync.Mutex
+	key ed25519.PrivateKey
+}
+
+func (k *signingKey) set(key ed25519.PrivateKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.key = key
+}
+
+// public returns the public half of the key, or nil if none is set yet.
+func (k *signingKey) public() ed25519.PublicKey {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.key == nil {
+		return nil
+	}
+	pub, _ := k.key.Public().(ed25519.PublicKey)
+	return pub
+}
+
+// sign signs msg, generating the key first if none is set.
+func (k *signingKey) sign(msg []byte) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.key == nil {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		k.key = key
+	}
+	return ed25519.Sign(k.key, msg), nil
 }
 
 // NewTrustedServer creates a new trusted server instance for managing log commitments and verification.
@@ -144,7 +236,99 @@ func NewTrustedServer() *TrustedServer {
 		commitments: make(map[string]InitCommitment),
 		opens:       make(map[string]OpenMessage),
 		closures:    make(map[string]CloseMessage),
+		anchorEvery: DefaultTrustedAnchorEvery,
+		anchors:     make(map[string]*trustedAnchorCache),
+		treeHeads:   make(map[string][]SignedTreeHead),
+		grants:      make(map[string][]AuditGrant),
+		legalHolds:  make(map[string]bool),
+		prunePoints: make(map[string]TrustedAnchor),
+	}
+}
+
+// SetTrustedAnchorInterval sets how often (in entries) T caches T-chain
+// checkpoints while verifying. Zero keeps only the furthest verified position.
+// It applies to logs whose checkpoints have not been derived yet.
+func (ts *TrustedServer) SetTrustedAnchorInterval(every uint64) {
+	ts.anchorMu.Lock()
+	defer ts.anchorMu.Unlock()
+	ts.anchorEvery = every
+}
+
+// TrustedAnchors returns the T-chain checkpoints T has derived for a log.
+// These contain B_i and must never leave the trusted server.
+func (ts *TrustedServer) TrustedAnchors(logID string) []TrustedAnchor {
+	ts.anchorMu.Lock()
+	c, ok := ts.anchors[logID]
+	ts.anchorMu.Unlock()
+	if !ok {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list()
+}
+
+// LoadTrustedAnchors restores previously derived checkpoints for a log,
+// e.g. from T's own persistent storage after a restart.
+func (ts *TrustedServer) LoadTrustedAnchors(logID string, anchors []TrustedAnchor) {
+	c := ts.anchorCache(logID)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, a := range anchors {
+		c.add(a)
+	}
+}
+
+// anchorCache returns the checkpoint cache for logID, creating it if needed.
+// The global lock is only held for the map lookup; callers lock the cache.
+func (ts *TrustedServer) anchorCache(logID string) *trustedAnchorCache {
+	ts.anchorMu.Lock()
+	defer ts.anchorMu.Unlock()
+	c, ok := ts.anchors[logID]
+	if !ok {
+		c = newTrustedAnchorCache(ts.anchorEvery)
+		ts.anchors[logID] = c
+	}
+	return c
+}
+
+// VerifyTrustedChain verifies the T-chain of a (possibly still open) log and
+// caches checkpoints along the way. Records may be the full log or any
+// contiguous suffix that starts at or right after a cached checkpoint; only
+// entries after the furthest usable checkpoint are re-verified.
+// Returns the T-chain state after the last record.
+func (ts *TrustedServer) VerifyTrustedChain(logID string, records []Record) (TrustedAnchor, error) {
+	return ts.VerifyTrustedChainContext(context.Background(), logID, records, VerifyOptions{})
+}
+
+// VerifyTrustedChainContext is VerifyTrustedChain with cancellation and progress reporting.
+func (ts *TrustedServer) VerifyTrustedChainContext(
+	ctx context.Context, logID string, records []Record, opts VerifyOptions,
+) (_ TrustedAnchor, err error) {
+	commit, ok := ts.commitments[logID]
+	if !ok {
+		return TrustedAnchor{}, errors.New("log not registered with trusted server")
+	}
+	if closeMsg, ok := ts.closures[logID]; ok {
+		opts.Timestamps = opts.Timestamps.withProtocolBounds(commit.StartTime, closeMsg.CloseTime)
+	} else {
+		opts.Timestamps = opts.Timestamps.withProtocolBounds(commit.StartTime, time.Time{})
+	}
+	opts.Redactable = commit.Redactable
+	opts.RedactionKey = ts.RedactionPublicKey()
+	co := newChainOptions(opts)
+	co.logID = logID
+	defer func() { co.progress.finish(err) }()
+	prune, pruned := ts.PrunePoint(logID)
+	cache := ts.anchorCache(logID)
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if pruned {
+		// The prune point is where a pruned log now starts; keep it usable
+		// even after the cache has moved past it.
+		cache.add(prune)
 	}
+	return cache.verify(ctx, records, commit.KeyB0, co)
 }
 
 // RegisterLog stores the initial commitment from logger U.
@@ -169,7 +353,16 @@ func (ts *TrustedServer) AcceptClosure(closeMsg CloseMessage) error {
 
 // FinalVerify performs final validation using the T-chain.
 // This is the authoritative verification that cannot be forged by V.
+// Only entries after the furthest checkpoint cached by an earlier run are re-verified.
 func (ts *TrustedServer) FinalVerify(logID string, records []Record) error {
+	return ts.FinalVerifyContext(context.Background(), logID, records, VerifyOptions{})
+}
+
+// FinalVerifyContext is FinalVerify with cancellation and progress reporting.
+// HTTP handlers should pass the request context so abandoned requests stop early.
+func (ts *TrustedServer) FinalVerifyContext(
+	ctx context.Context, logID string, records []Record, opts VerifyOptions,
+) error {
 	commit, ok := ts.commitments[logID]
 	if !ok {
 		return errors.New("log not registered with trusted server")
@@ -185,24 +378,16 @@ func (ts *TrustedServer) FinalVerify(logID string, records []Record) error {
 	}
 
 	firstRec := records[0]
-	if firstRec.Index != open.FirstIndex {
-		return errors.New("opening index mismatch")
-	}
-	if string(firstRec.Msg) != "START" {
-		return errors.New("missing opening message")
-	}
-
-	var zeroTag [32]byte
-	firstV, err := VerifyFrom(records[:1], 0, commit.KeyA0, zeroTag)
-	if err != nil {
-		return fmt.Errorf("verify opening V-chain: %w", err)
-	}
-	firstT, err := VerifyFromTrusted(records[:1], 0, commit.KeyB0, zeroTag)
-	if err != nil {
-		return fmt.Errorf("verify opening T-chain: %w", err)
-	}
-	if !hmac.Equal(firstV[:], open.FirstTagV[:]) || !hmac.Equal(firstT[:], open.FirstTagT[:]) {
-		return errors.New("opening tag mismatch")
+	prune, pruned := ts.PrunePoint(logID)
+	pruned = pruned && firstRec.Index == prune.Index && firstRec.Index != open.FirstIndex
+	if pruned {
+		// Records before the authorised prune point are gone; the log must
+		// carry the event recording the prune.
+		if err := checkPruneEvent(records, prune, commit.Redactable); err != nil {
+			return err
+		}
+	} else if err := verifyOpening(records[:1], commit, open); err != nil {
+		return err
 	}
 
 	closeMsg, ok := ts.closures[logID]
@@ -210,22 +395,53 @@ func (ts *TrustedServer) FinalVerify(logID string, records []Record) error {
 		return ErrLogNotClosed
 	}
 
-	if err := VerifyCloseMessage(records, closeMsg); err != nil {
+	if err := verifyCloseMessage(records, closeMsg, commit.Redactable); err != nil {
 		return err
 	}
 
-	finalTag, err := VerifyFromTrusted(records, 0, commit.KeyB0, zeroTag)
+	if !pruned {
+		if err := ts.checkTreeHeads(logID, records); err != nil {
+			return err
+		}
+	}
+
+	final, err := ts.VerifyTrustedChainContext(ctx, logID, records, opts)
 	if err != nil {
 		return err
 	}
 
-	if !hmac.Equal(finalTag[:], closeMsg.FinalTagT[:]) {
+	if !hmac.Equal(final.TagT[:], closeMsg.FinalTagT[:]) {
 		return errors.New("final T-chain tag mismatch")
 	}
 
 	return nil
 }
 
+// verifyOpening checks that first is the opening entry announced in open.
+func verifyOpening(first []Record, commit InitCommitment, open OpenMessage) error {
+	if first[0].Index != open.FirstIndex {
+		return errors.New("opening index mismatch")
+	}
+	if msg, _ := messageOf(first[0], commit.Redactable); string(msg) != "START" {
+		return errors.New("missing opening message")
+	}
+
+	var zeroTag [32]byte
+	co := chainOptions{redactable: commit.Redactable}
+	firstV, err := verifyChain(context.Background(), first, 0, commit.KeyA0, zeroTag, true, co)
+	if err != nil {
+		return fmt.Errorf("verify opening V-chain: %w", err)
+	}
+	firstT, err := verifyChain(context.Background(), first, 0, commit.KeyB0, zeroTag, false, co)
+	if err != nil {
+		return fmt.Errorf("verify opening T-chain: %w", err)
+	}
+	if !hmac.Equal(firstV[:], open.FirstTagV[:]) || !hmac.Equal(firstT[:], open.FirstTagT[:]) {
+		return errors.New("opening tag mismatch")
+	}
+	return nil
+}
+
 // DetectDelayedAttack checks if V's verification differs from T's verification.
 // If they differ, a delayed detection attack has occurred (Section 2.2).
 func (*TrustedServer) DetectDelayedAttack(_ string, vTag, tTag [32]byte) bool {
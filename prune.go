@@ -0,0 +1,253 @@
+package securelog
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrLegalHold indicates pruning was refused because the log is under legal hold.
+	ErrLegalHold = errors.New("log is under legal hold")
+	// ErrPruneUnsupported indicates the store cannot delete records.
+	ErrPruneUnsupported = errors.New("store does not support pruning")
+	// ErrPruneUnauthorized indicates a prune authorisation that was not signed
+	// by T's prune key or was issued for another log.
+	ErrPruneUnauthorized = errors.New("prune not authorised by the trusted server")
+)
+
+const (
+	// pruneEventPrefix starts the message of the entry that records a prune.
+	pruneEventPrefix = "PRUNE "
+	// pruneDomain separates prune signatures from other uses of T's keys.
+	pruneDomain = "securelog prune v1\x00"
+)
+
+// Pruner is implemented by stores that can delete old records.
+type Pruner interface {
+	// Prune deletes records and anchors with index below beforeIndex.
+	Prune(beforeIndex uint64) error
+}
+
+// PruneAuthorization is T's approval to drop every record before Index.
+// T has verified the T-chain up to Index and keeps (B_Index, μ_T,Index) as
+// the point from which it verifies the remaining log. It is signed with T's
+// prune key, so the logger can tell it came from T.
+type PruneAuthorization struct {
+	LogID     string
+	Index     uint64   // first record kept; must be an anchor in the logger's store
+	TagT      [32]byte // μ_T,Index as verified by T
+	IssuedAt  time.Time
+	Signature []byte // T's Ed25519 signature over all fields above
+}
+
+// signedBytes is the canonical encoding covered by the signature.
+func (auth PruneAuthorization) signedBytes() []byte {
+	buf := make([]byte, 0, len(pruneDomain)+4+len(auth.LogID)+8+32+8)
+	buf = append(buf, pruneDomain...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(auth.LogID)))
+	buf = append(buf, auth.LogID...)
+	buf = binary.BigEndian.AppendUint64(buf, auth.Index)
+	buf = append(buf, auth.TagT[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(auth.IssuedAt.UnixNano()))
+	return buf
+}
+
+// Verify checks that auth was signed by T's prune key.
+func (auth PruneAuthorization) Verify(key ed25519.PublicKey) error {
+	if len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, auth.signedBytes(), auth.Signature) {
+		return ErrPruneUnauthorized
+	}
+	return nil
+}
+
+// pruneEventMessage is the message of the entry recording auth.
+func pruneEventMessage(auth PruneAuthorization) []byte {
+	return []byte(fmt.Sprintf("%s%d %x", pruneEventPrefix, auth.Index, auth.TagT))
+}
+
+// parsePruneEvent extracts the prune point from a prune event record of a
+// log of the given mode.
+func parsePruneEvent(r Record, redactable bool) (idx uint64, tagT [32]byte, ok bool) {
+	msg, ok := messageOf(r, redactable)
+	if !ok || !strings.HasPrefix(string(msg), pruneEventPrefix) {
+		return 0, tagT, false
+	}
+	fields := strings.Fields(string(msg[len(pruneEventPrefix):]))
+	if len(fields) != 2 {
+		return 0, tagT, false
+	}
+	idx, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, tagT, false
+	}
+	b, err := hex.DecodeString(fields[1])
+	if err != nil || len(b) != len(tagT) {
+		return 0, tagT, false
+	}
+	copy(tagT[:], b)
+	return idx, tagT, true
+}
+
+// Prune appends a prune event entry and then deletes every record before
+// auth.Index from the store, so the deletion itself is covered by both MAC
+// chains. auth must be signed by Config.PruneKey and issued for this logger's
+// log; since T refuses to sign while a log is under legal hold, the hold is
+// enforced by T rather than by state the logger could lose. auth.Index must
+// be an anchor; it becomes the verification starting point for the
+// remaining log. The store must implement Pruner.
+//
+// Only the logger that wrote the store's last record can prune: a Logger
+// from New over an existing store cannot append the event and is refused
+// before anything is deleted. If the event is committed but the store fails
+// to prune, the event is returned with the error; the log still verifies
+// in full and the store's Pruner can be called again.
+func (l *Logger) Prune(auth PruneAuthorization) (Entry, error) {
+	if err := auth.Verify(l.cfg.PruneKey); err != nil {
+		return Entry{}, err
+	}
+	if l.logID == "" {
+		return Entry{}, errors.New("log ID unknown: set Config.LogID")
+	}
+	if auth.LogID != l.logID {
+		return Entry{}, fmt.Errorf("authorisation issued for log %q: %w", auth.LogID, ErrPruneUnauthorized)
+	}
+	p, ok := l.store.(Pruner)
+	if !ok {
+		return Entry{}, ErrPruneUnsupported
+	}
+	a, found, err := l.store.AnchorAt(auth.Index)
+	if err != nil {
+		return Entry{}, err
+	}
+	if !found {
+		return Entry{}, fmt.Errorf("prune point %d is not an anchor", auth.Index)
+	}
+	if !constantTimeEqual(a.TagT[:], auth.TagT[:]) {
+		return Entry{}, errors.New("prune authorisation does not match anchor")
+	}
+	tail, ok, err := l.store.Tail()
+	if err != nil {
+		return Entry{}, err
+	}
+	if !ok || tail.Index != l.i || l.i == 0 {
+		return Entry{}, fmt.Errorf("logger at %d does not hold the chain of the store at %d", l.i, tail.Index)
+	}
+	event, err := l.appendProtocol(pruneEventMessage(auth), time.Now())
+	if err != nil {
+		return Entry{}, fmt.Errorf("append prune event: %w", err)
+	}
+	if err := p.Prune(auth.Index); err != nil {
+		return event, fmt.Errorf("prune store: %w", err)
+	}
+	return event, nil
+}
+
+// SetLegalHold places logID under legal hold (or lifts it). T refuses to
+// authorise pruning of a log under hold, and the logger only prunes with T's
+// authorisation. Holds are part of T's state: a T that restarts must restore
+// them (see LegalHolds) before authorising any prune.
+func (ts *TrustedServer) SetLegalHold(logID string, hold bool) {
+	ts.pruneMu.Lock()
+	defer ts.pruneMu.Unlock()
+	if hold {
+		ts.legalHolds[logID] = true
+	} else {
+		delete(ts.legalHolds, logID)
+	}
+}
+
+// LegalHolds returns the IDs of the logs under legal hold, sorted.
+func (ts *TrustedServer) LegalHolds() []string {
+	ts.pruneMu.Lock()
+	defer ts.pruneMu.Unlock()
+	ids := make([]string, 0, len(ts.legalHolds))
+	for id := range ts.legalHolds {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// SetPruneKey sets the Ed25519 key T signs prune authorisations with. Its
+// public half goes into the logger's Config.PruneKey. Without it T generates
+// a key the first time it authorises a prune.
+func (ts *TrustedServer) SetPruneKey(key ed25519.PrivateKey) {
+	ts.pruneKey.set(key)
+}
+
+// PrunePublicKey returns the key prune authorisations are checked against,
+// or nil if T has not authorised any prune nor been given a key.
+func (ts *TrustedServer) PrunePublicKey() ed25519.PublicKey {
+	return ts.pruneKey.public()
+}
+
+// AuthorizePrune verifies records (covering at least up to beforeIndex) on
+// the T-chain and authorises dropping every record before beforeIndex.
+// FinalVerify then accepts the log starting at beforeIndex instead of its
+// opening entry, provided the log contains the matching prune event.
+func (ts *TrustedServer) AuthorizePrune(logID string, records []Record, beforeIndex uint64) (PruneAuthorization, error) {
+	ts.pruneMu.Lock()
+	held := ts.legalHolds[logID]
+	prev, pruned := ts.prunePoints[logID]
+	ts.pruneMu.Unlock()
+
+	if held {
+		return PruneAuthorization{}, ErrLegalHold
+	}
+	if pruned && beforeIndex <= prev.Index {
+		return PruneAuthorization{}, fmt.Errorf("prune point %d does not advance past %d", beforeIndex, prev.Index)
+	}
+
+	end := -1
+	for i, r := range records {
+		if r.Index == beforeIndex {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return PruneAuthorization{}, fmt.Errorf("records do not include prune point %d", beforeIndex)
+	}
+	point, err := ts.VerifyTrustedChain(logID, records[:end+1])
+	if err != nil {
+		return PruneAuthorization{}, fmt.Errorf("verify T-chain: %w", err)
+	}
+
+	// The hold may have been set while the chain was being verified.
+	ts.pruneMu.Lock()
+	defer ts.pruneMu.Unlock()
+	if ts.legalHolds[logID] {
+		return PruneAuthorization{}, ErrLegalHold
+	}
+	auth := PruneAuthorization{LogID: logID, Index: point.Index, TagT: point.TagT, IssuedAt: time.Now()}
+	if auth.Signature, err = ts.pruneKey.sign(auth.signedBytes()); err != nil {
+		return PruneAuthorization{}, err
+	}
+	ts.prunePoints[logID] = point
+	return auth, nil
+}
+
+// PrunePoint returns the T-chain checkpoint of the latest authorised prune of logID.
+func (ts *TrustedServer) PrunePoint(logID string) (TrustedAnchor, bool) {
+	ts.pruneMu.Lock()
+	defer ts.pruneMu.Unlock()
+	a, ok := ts.prunePoints[logID]
+	return a, ok
+}
+
+// checkPruneEvent ensures records contain the prune event for point.
+func checkPruneEvent(records []Record, point TrustedAnchor, redactable bool) error {
+	for _, r := range records {
+		if idx, tagT, ok := parsePruneEvent(r, redactable); ok && idx == point.Index && tagT == point.TagT {
+			return nil
+		}
+	}
+	return errors.New("pruned log lacks its prune event")
+}
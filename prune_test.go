@@ -0,0 +1,214 @@
+package securelog
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+func TestPrune_AllStores(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-prune-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fileSt, err := OpenFileStore(filepath.Join(tmpDir, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileSt.(*fileStore).Close()
+
+	sqliteSt, err := OpenSQLiteStore(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, store := range map[string]Store{"file": fileSt, "sqlite": sqliteSt} {
+		t.Run(name, func(t *testing.T) {
+			prunePub, pruneKey, err := ed25519.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			logger, err := New(Config{AnchorEvery: 5, PruneKey: prunePub}, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			logID := "prune-" + name
+			commit, openMsg, err := logger.InitProtocol(logID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 14; i++ {
+				if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ts := NewTrustedServer()
+			ts.SetPruneKey(pruneKey)
+			ts.RegisterLog(commit)
+			ts.RegisterOpen(openMsg)
+			records := readAllRecords(t, store)
+
+			// Legal hold at T blocks the authorisation. Holds are T state
+			// and carry over to a restarted T.
+			ts.SetLegalHold(logID, true)
+			if _, err := ts.AuthorizePrune(logID, records, 10); !errors.Is(err, ErrLegalHold) {
+				t.Fatalf("Expected ErrLegalHold from T, got: %v", err)
+			}
+			restarted := NewTrustedServer()
+			restarted.RegisterLog(commit)
+			for _, id := range ts.LegalHolds() {
+				restarted.SetLegalHold(id, true)
+			}
+			if _, err := restarted.AuthorizePrune(logID, records, 10); !errors.Is(err, ErrLegalHold) {
+				t.Fatalf("Expected ErrLegalHold from restarted T, got: %v", err)
+			}
+			ts.SetLegalHold(logID, false)
+			if got := ts.LegalHolds(); len(got) != 0 {
+				t.Fatalf("Expected no legal holds, got %v", got)
+			}
+
+			auth, err := ts.AuthorizePrune(logID, records, 10)
+			if err != nil {
+				t.Fatalf("AuthorizePrune failed: %v", err)
+			}
+			if auth.Index != 10 || auth.TagT != records[9].TagT {
+				t.Fatalf("Unexpected authorisation: %+v", auth)
+			}
+
+			// The logger only prunes with T's signed authorisation for its log.
+			unsigned := auth
+			unsigned.Signature = nil
+			altered := auth
+			altered.Index = 5
+			for name, bad := range map[string]PruneAuthorization{"unsigned": unsigned, "altered": altered} {
+				if _, err := logger.Prune(bad); !errors.Is(err, ErrPruneUnauthorized) {
+					t.Errorf("%s authorisation: expected ErrPruneUnauthorized, got: %v", name, err)
+				}
+			}
+			otherLog := signPrune(PruneAuthorization{LogID: "other", Index: 10, TagT: records[9].TagT}, pruneKey)
+			if _, err := logger.Prune(otherLog); !errors.Is(err, ErrPruneUnauthorized) {
+				t.Errorf("Expected ErrPruneUnauthorized for another log, got: %v", err)
+			}
+			unknown, err := New(Config{PruneKey: prunePub}, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := unknown.Prune(auth); err == nil {
+				t.Error("Expected a logger without a log ID to refuse pruning")
+			}
+			// A logger started over the existing store cannot append the
+			// prune event, so it must not delete anything either.
+			restartedLogger, err := New(Config{PruneKey: prunePub, LogID: logID}, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := restartedLogger.Prune(auth); err == nil {
+				t.Error("Expected a logger that did not write the store to refuse pruning")
+			}
+			if got := readAllRecords(t, store); len(got) != len(records) {
+				t.Fatalf("Records removed without authorisation: %d left", len(got))
+			}
+
+			// The prune point must be an anchor the authorisation matches.
+			if _, err := logger.Prune(signPrune(PruneAuthorization{LogID: logID, Index: 9, TagT: records[8].TagT}, pruneKey)); err == nil {
+				t.Error("Expected error for prune point that is not an anchor")
+			}
+			forged := auth
+			forged.TagT[0] ^= 0xFF
+			if _, err := logger.Prune(signPrune(forged, pruneKey)); err == nil {
+				t.Error("Expected error for authorisation not matching the anchor")
+			}
+
+			event, err := logger.Prune(auth)
+			if err != nil {
+				t.Fatalf("Prune failed: %v", err)
+			}
+			if _, err := logger.Append([]byte("after prune"), time.Now()); err != nil {
+				t.Fatal(err)
+			}
+			closeMsg, err := logger.CloseProtocol(logID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pruned := readAllRecords(t, store)
+			if pruned[0].Index != 10 {
+				t.Fatalf("Expected pruned log to start at 10, got %d", pruned[0].Index)
+			}
+			if idx, _, ok := parsePruneEvent(pruned[event.Index-10], false); !ok || idx != 10 {
+				t.Fatal("Prune event not found in the log")
+			}
+			anchors, err := store.ListAnchors()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(anchors) == 0 || anchors[0].Index != 10 {
+				t.Fatalf("Expected anchors from 10 on, got %+v", anchors)
+			}
+
+			// The kept anchor is the verifier's new starting point.
+			verifier := NewSemiTrustedVerifier(store)
+			if err := verifier.VerifyFromAnchor(anchors[0]); err != nil {
+				t.Fatalf("VerifyFromAnchor after prune failed: %v", err)
+			}
+
+			if err := ts.AcceptClosure(closeMsg); err != nil {
+				t.Fatal(err)
+			}
+			if err := ts.FinalVerify(logID, pruned); err != nil {
+				t.Fatalf("FinalVerify on pruned log failed: %v", err)
+			}
+
+			// A fresh T that never authorised the prune rejects the log.
+			other := NewTrustedServer()
+			other.RegisterLog(commit)
+			other.RegisterOpen(openMsg)
+			if err := other.AcceptClosure(closeMsg); err != nil {
+				t.Fatal(err)
+			}
+			if err := other.FinalVerify(logID, pruned); err == nil {
+				t.Error("Expected FinalVerify to reject an unauthorised prune")
+			}
+
+			// Dropping the prune event is detected.
+			var stripped []Record
+			for _, r := range pruned {
+				if r.Index != event.Index {
+					stripped = append(stripped, r)
+				}
+			}
+			if err := ts.FinalVerify(logID, stripped); err == nil {
+				t.Error("Expected FinalVerify to reject a log without its prune event")
+			}
+
+			// Further records may not be dropped beyond the authorised point.
+			if err := ts.FinalVerify(logID, pruned[1:]); err == nil {
+				t.Error("Expected FinalVerify to reject records beyond the prune point")
+			}
+
+			if _, err := ts.AuthorizePrune(logID, pruned, 10); err == nil {
+				t.Error("Expected error for a prune point that does not advance")
+			}
+			if p, ok := ts.PrunePoint(logID); !ok || p.Index != 10 {
+				t.Errorf("Unexpected prune point: %+v (ok=%v)", p, ok)
+			}
+		})
+	}
+}
+
+// signPrune signs auth with key, standing in for T.
+func signPrune(auth PruneAuthorization, key ed25519.PrivateKey) PruneAuthorization {
+	auth.Signature = ed25519.Sign(key, auth.signedBytes())
+	return auth
+}
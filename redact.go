@@ -0,0 +1,394 @@
+package securelog
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Redactable records (Config.Redactable) store their message in an envelope
+// and the MACs cover a salted commitment to the message rather than the
+// message itself, so the payload can later be replaced by a redaction marker
+// without breaking either chain or the Merkle tree.
+//
+// Envelope layout in Record.Msg:
+//
+//	[5]byte:  envelopeMagic
+//	[1]byte:  kind (envelopeContent or envelopeRedacted)
+//
+// followed, for envelopeContent, by
+//
+//	[16]byte: salt
+//	[n]byte:  message
+//
+// and, for envelopeRedacted, by
+//
+//	[32]byte: commitment
+//	[8]byte:  redaction time (unix nanos)
+//	[2]byte:  reason length, then the reason
+//	[2]byte:  authoriser length, then the authoriser
+//	[2]byte:  log ID length, then the log ID
+//	[2]byte:  signature length, then T's Ed25519 signature (see Redaction.Verify)
+//
+// The MACs and leaf hash cover envelopeMagic || commitment, where
+// commitment = SHA-256(commitDomain || salt || message).
+//
+// Envelopes are only interpreted on logs written with Config.Redactable:
+// verifiers are told the mode (VerifyOptions.Redactable, or the Redactable
+// flag of the log's InitCommitment for T), and messages of other logs are
+// MACed exactly as given, whatever bytes they start with.
+const (
+	envelopeMagic    = "\x00SLRX"
+	envelopeContent  = 0x01
+	envelopeRedacted = 0x02
+	redactSaltSize   = 16
+	commitDomain     = "securelog commit v1\x00"
+	redactionDomain  = "securelog redaction v1\x00"
+)
+
+var (
+	// ErrNotRedactable indicates a redaction was requested for a record that was
+	// not written in redactable mode.
+	ErrNotRedactable = errors.New("record is not redactable")
+	// ErrRedactUnsupported indicates the store cannot replace record payloads.
+	ErrRedactUnsupported = errors.New("store does not support redaction")
+	// ErrRedactionUnauthorized indicates a redaction marker that does not carry
+	// a valid authorisation signed by T for the record it replaces.
+	ErrRedactionUnauthorized = errors.New("redaction not authorised by the trusted server")
+)
+
+// Redaction describes a record whose payload was erased. It is issued and
+// signed by T (TrustedServer.AuthorizeRedaction) and stored in the record's
+// redaction marker, so the marker cannot be forged or altered without T.
+type Redaction struct {
+	LogID      string
+	Index      uint64
+	Commitment [32]byte // commitment to the erased message, as covered by the MACs
+	Reason     string
+	Authoriser string
+	At         time.Time
+	Signature  []byte // T's Ed25519 signature over all fields above
+}
+
+// signedBytes is the canonical encoding covered by the signature.
+func (red Redaction) signedBytes() []byte {
+	buf := make([]byte, 0, len(redactionDomain)+4+len(red.LogID)+8+32+8+4+len(red.Reason)+len(red.Authoriser))
+	buf = append(buf, redactionDomain...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(red.LogID)))
+	buf = append(buf, red.LogID...)
+	buf = binary.BigEndian.AppendUint64(buf, red.Index)
+	buf = append(buf, red.Commitment[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(red.At.UnixNano()))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(red.Reason)))
+	buf = append(buf, red.Reason...)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(red.Authoriser)))
+	buf = append(buf, red.Authoriser...)
+	return buf
+}
+
+// Verify checks that red was signed by T's redaction key.
+func (red Redaction) Verify(key ed25519.PublicKey) error {
+	if len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, red.signedBytes(), red.Signature) {
+		return ErrRedactionUnauthorized
+	}
+	return nil
+}
+
+// RedactableStore is implemented by stores that can replace the stored
+// message of an existing record. It is only used for redaction.
+type RedactableStore interface {
+	ReplaceMsg(idx uint64, msg []byte) error
+}
+
+// sealMessage wraps msg in a content envelope with a fresh salt.
+func sealMessage(msg []byte) ([]byte, error) {
+	out := make([]byte, 0, len(envelopeMagic)+1+redactSaltSize+len(msg))
+	out = append(out, envelopeMagic...)
+	out = append(out, envelopeContent)
+	var salt [redactSaltSize]byte
+	if _, err := rand.Read(salt[:]); err != nil {
+		return nil, err
+	}
+	out = append(out, salt[:]...)
+	return append(out, msg...), nil
+}
+
+func commitment(salt, msg []byte) [32]byte {
+	h := sha256.New()
+	_, _ = h.Write([]byte(commitDomain))
+	_, _ = h.Write(salt)
+	_, _ = h.Write(msg)
+	var out [32]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// envelopeKind returns the envelope kind of a stored message, or 0 for a plain message.
+func envelopeKind(stored []byte) byte {
+	if len(stored) <= len(envelopeMagic) || !bytes.HasPrefix(stored, []byte(envelopeMagic)) {
+		return 0
+	}
+	switch k := stored[len(envelopeMagic)]; k {
+	case envelopeContent:
+		if len(stored) >= len(envelopeMagic)+1+redactSaltSize {
+			return k
+		}
+	case envelopeRedacted:
+		if len(stored) >= len(envelopeMagic)+1+32+8+2+2+2+2 {
+			return k
+		}
+	case envelopeEncrypted:
+		if len(stored) >= len(envelopeMagic)+1+encOverhead {
+			return k
+		}
+	}
+	return 0
+}
+
+// commitmentOf returns the commitment of a content or redacted envelope.
+func commitmentOf(stored []byte) ([32]byte, bool) {
+	var c [32]byte
+	switch envelopeKind(stored) {
+	case envelopeContent:
+		body := stored[len(envelopeMagic)+1:]
+		return commitment(body[:redactSaltSize], body[redactSaltSize:]), true
+	case envelopeRedacted:
+		copy(c[:], stored[len(envelopeMagic)+1:])
+		return c, true
+	}
+	return c, false
+}
+
+// macMessage returns the bytes the MACs cover for a stored message. On a
+// redactable log every message must be a content or redacted envelope and
+// the MACs cover its commitment; ok is false for anything else. On other
+// logs the MACs cover the message as stored.
+func macMessage(stored []byte, redactable bool) (covered []byte, ok bool) {
+	if !redactable {
+		return stored, true
+	}
+	c, ok := commitmentOf(stored)
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(envelopeMagic), c[:]...), true
+}
+
+// leafMessage returns the bytes the Merkle leaf hash covers for a stored
+// message. On a redactable log envelopes are hashed through their
+// commitment, which keeps leaves stable across redaction; on other logs the
+// message is hashed as stored, so two messages never share a leaf.
+func leafMessage(stored []byte, redactable bool) []byte {
+	if !redactable {
+		return stored
+	}
+	if c, ok := commitmentOf(stored); ok {
+		return append([]byte(envelopeMagic), c[:]...)
+	}
+	return stored
+}
+
+// messageOf returns the message carried by r on a log of the given mode:
+// the payload on a redactable log (see Record.Payload), Msg on other logs.
+func messageOf(r Record, redactable bool) ([]byte, bool) {
+	if !redactable {
+		return r.Msg, true
+	}
+	return r.Payload()
+}
+
+// Payload returns the message carried by a record of a redactable log,
+// unwrapping its envelope. ok is false if the record has been redacted. The
+// payload of an encrypted record is its ciphertext (see Decryptor). On other
+// logs Msg is the message, whatever bytes it starts with; Payload must not
+// be used there.
+func (r Record) Payload() (msg []byte, ok bool) {
+	switch envelopeKind(r.Msg) {
+	case envelopeContent:
+		return r.Msg[len(envelopeMagic)+1+redactSaltSize:], true
+	case envelopeRedacted:
+		return nil, false
+	default:
+		return r.Msg, true
+	}
+}
+
+// Redaction returns the redaction marker of r, if r has been redacted.
+// The marker is only authentic if Redaction.Verify accepts it.
+func (r Record) Redaction() (Redaction, bool) {
+	if envelopeKind(r.Msg) != envelopeRedacted {
+		return Redaction{}, false
+	}
+	body := r.Msg[len(envelopeMagic)+1:]
+	red := Redaction{Index: r.Index}
+	copy(red.Commitment[:], body)
+	red.At = time.Unix(0, int64(binary.BigEndian.Uint64(body[32:])))
+	body = body[32+8:]
+	var fields [4][]byte
+	for i := range fields {
+		var ok bool
+		if fields[i], body, ok = cutField(body); !ok {
+			return Redaction{}, false
+		}
+	}
+	red.Reason, red.Authoriser, red.LogID = string(fields[0]), string(fields[1]), string(fields[2])
+	red.Signature = append([]byte(nil), fields[3]...)
+	return red, true
+}
+
+// cutField splits a 2-byte length-prefixed field off b.
+func cutField(b []byte) (field, rest []byte, ok bool) {
+	if len(b) < 2 {
+		return nil, nil, false
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return nil, nil, false
+	}
+	return b[2 : 2+n], b[2+n:], true
+}
+
+// RedactRecord returns r with its payload replaced by the marker for red,
+// which must be T's authorisation for exactly this record.
+// The result verifies on both chains exactly as r did.
+func RedactRecord(r Record, red Redaction) (Record, error) {
+	c, ok := commitmentOf(r.Msg)
+	if !ok {
+		return Record{}, ErrNotRedactable
+	}
+	if red.Index != r.Index || red.Commitment != c {
+		return Record{}, fmt.Errorf("redaction authorisation is for a different record: %w", ErrRedactionUnauthorized)
+	}
+	for _, f := range []string{red.Reason, red.Authoriser, red.LogID, string(red.Signature)} {
+		if len(f) > 0xFFFF {
+			return Record{}, errors.New("redaction field too long")
+		}
+	}
+
+	msg := make([]byte, 0, len(envelopeMagic)+1+32+8+8+len(red.Reason)+len(red.Authoriser)+len(red.LogID)+len(red.Signature))
+	msg = append(msg, envelopeMagic...)
+	msg = append(msg, envelopeRedacted)
+	msg = append(msg, c[:]...)
+	msg = binary.BigEndian.AppendUint64(msg, uint64(red.At.UnixNano()))
+	for _, f := range [][]byte{[]byte(red.Reason), []byte(red.Authoriser), []byte(red.LogID), red.Signature} {
+		msg = binary.BigEndian.AppendUint16(msg, uint16(len(f)))
+		msg = append(msg, f...)
+	}
+
+	r.Msg = msg
+	return r, nil
+}
+
+// Redact erases the payload of record red.Index in st, replacing it with a
+// redaction marker carrying red, T's authorisation for it. The record must
+// have been written in redactable mode and the store must implement
+// RedactableStore.
+func Redact(st Store, red Redaction) error {
+	rs, ok := st.(RedactableStore)
+	if !ok {
+		return ErrRedactUnsupported
+	}
+	recs, err := collectRange(context.Background(), st, red.Index, red.Index)
+	if err != nil {
+		return err
+	}
+	if len(recs) != 1 || recs[0].Index != red.Index {
+		return fmt.Errorf("record %d not found", red.Index)
+	}
+	marked, err := RedactRecord(recs[0], red)
+	if err != nil {
+		return err
+	}
+	return rs.ReplaceMsg(red.Index, marked.Msg)
+}
+
+// Redactions lists the redaction markers found in records, checking each
+// against T's redaction key. A marker without a valid authorisation fails
+// with ErrRedactionUnauthorized.
+func Redactions(records []Record, key ed25519.PublicKey) ([]Redaction, error) {
+	var out []Redaction
+	for _, r := range records {
+		red, ok, err := checkRedaction(r, key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, red)
+		}
+	}
+	return out, nil
+}
+
+// checkRedaction verifies the marker of a redacted record on a redactable
+// log against T's key, returning it when authentic. Records that are not
+// redacted return ok == false and no error.
+func checkRedaction(r Record, key ed25519.PublicKey) (red Redaction, ok bool, err error) {
+	if envelopeKind(r.Msg) != envelopeRedacted {
+		return Redaction{}, false, nil
+	}
+	red, ok = r.Redaction()
+	if !ok {
+		return Redaction{}, false, fmt.Errorf("record %d: %w", r.Index, ErrRedactionUnauthorized)
+	}
+	if err := red.Verify(key); err != nil {
+		return Redaction{}, false, fmt.Errorf("record %d: %w", r.Index, err)
+	}
+	return red, true, nil
+}
+
+// SetRedactionKey sets the Ed25519 key T signs redaction authorisations
+// with. T must keep the same key across restarts, since verifiers check
+// existing markers against its public half. Without it T generates a key the
+// first time it authorises a redaction.
+func (ts *TrustedServer) SetRedactionKey(key ed25519.PrivateKey) {
+	ts.redactKey.set(key)
+}
+
+// RedactionPublicKey returns the key redaction markers are checked against,
+// or nil if T has not authorised any redaction nor been given a key.
+func (ts *TrustedServer) RedactionPublicKey() ed25519.PublicKey {
+	return ts.redactKey.public()
+}
+
+// AuthorizeRedaction signs T's approval to erase the payload of record r of
+// logID, which must be a redactable log. The result is written into the
+// record's marker with Redact; verifiers reject markers T did not sign.
+// The MACs still bind the commitment, so an authorisation for a record other
+// than the one in the log will not verify.
+func (ts *TrustedServer) AuthorizeRedaction(logID string, r Record, reason, authoriser string) (Redaction, error) {
+	commit, ok := ts.commitments[logID]
+	if !ok {
+		return Redaction{}, errors.New("log not registered with trusted server")
+	}
+	if !commit.Redactable {
+		return Redaction{}, ErrNotRedactable
+	}
+	c, ok := commitmentOf(r.Msg)
+	if !ok {
+		return Redaction{}, ErrNotRedactable
+	}
+	if len(reason) > 0xFFFF || len(authoriser) > 0xFFFF || len(logID) > 0xFFFF {
+		return Redaction{}, errors.New("redaction reason, authoriser or log ID too long")
+	}
+
+	red := Redaction{
+		LogID:      logID,
+		Index:      r.Index,
+		Commitment: c,
+		Reason:     reason,
+		Authoriser: authoriser,
+		At:         time.Now(),
+	}
+	sig, err := ts.redactKey.sign(red.signedBytes())
+	if err != nil {
+		return Redaction{}, err
+	}
+	red.Signature = sig
+	return red, nil
+}
@@ -0,0 +1,276 @@
+package securelog
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+func TestRedact_AllStores(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-redact-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fileSt, err := OpenFileStore(filepath.Join(tmpDir, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileSt.(*fileStore).Close()
+
+	sqliteSt, err := OpenSQLiteStore(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, store := range map[string]Store{"file": fileSt, "sqlite": sqliteSt} {
+		t.Run(name, func(t *testing.T) {
+			logger, err := New(Config{Redactable: true}, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a0, b0 := logger.GetInitialKeys()
+			logID := "redact-" + name
+			commit, openMsg, err := logger.InitProtocol(logID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry, err := logger.Append([]byte("user alice@example.com logged in"), time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(entry.Msg) != "user alice@example.com logged in" {
+				t.Errorf("Entry should carry the plain message, got %q", entry.Msg)
+			}
+			if _, err := logger.Append([]byte("routine entry"), time.Now()); err != nil {
+				t.Fatal(err)
+			}
+			closeMsg, err := logger.CloseProtocol(logID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ts := NewTrustedServer()
+			ts.RegisterLog(commit)
+			ts.RegisterOpen(openMsg)
+			if err := ts.AcceptClosure(closeMsg); err != nil {
+				t.Fatal(err)
+			}
+			original := readAllRecords(t, store)
+			auth, err := ts.AuthorizeRedaction(logID, original[entry.Index-1], "GDPR erasure request #42", "dpo@example.com")
+			if err != nil {
+				t.Fatalf("AuthorizeRedaction failed: %v", err)
+			}
+			if err := Redact(store, auth); err != nil {
+				t.Fatalf("Redact failed: %v", err)
+			}
+
+			records := readAllRecords(t, store)
+			red := records[entry.Index-1]
+			if _, ok := red.Payload(); ok {
+				t.Error("Redacted record still exposes a payload")
+			}
+			marker, ok := red.Redaction()
+			if !ok || marker.Reason != "GDPR erasure request #42" || marker.Authoriser != "dpo@example.com" || marker.LogID != logID {
+				t.Fatalf("Unexpected redaction marker: %+v (ok=%v)", marker, ok)
+			}
+			if msg, _ := records[entry.Index].Payload(); string(msg) != "routine entry" {
+				t.Errorf("Neighbouring record changed: %q", msg)
+			}
+
+			// Both chains still verify and the redaction is reported.
+			key := ts.RedactionPublicKey()
+			var reported []Redaction
+			opts := VerifyOptions{
+				Redactable:   true,
+				RedactionKey: key,
+				OnRedacted:   func(r Redaction) { reported = append(reported, r) },
+			}
+			var zeroTag [32]byte
+			if _, err := VerifyChainContext(context.Background(), records, 0, a0, zeroTag, true, opts); err != nil {
+				t.Fatalf("V-chain failed after redaction: %v", err)
+			}
+			if len(reported) != 1 || reported[0].Index != entry.Index {
+				t.Errorf("Expected redaction of %d to be reported, got %+v", entry.Index, reported)
+			}
+			if _, err := VerifyChainContext(context.Background(), records, 0, b0, zeroTag, false, VerifyOptions{Redactable: true, RedactionKey: key}); err != nil {
+				t.Fatalf("T-chain failed after redaction: %v", err)
+			}
+			if got, err := Redactions(records, key); err != nil || len(got) != 1 {
+				t.Errorf("Expected 1 redaction, got %d, %v", len(got), err)
+			}
+
+			// The Merkle tree is unaffected.
+			if MerkleRoot(records, true) != closeMsg.FinalRoot {
+				t.Error("Tree root changed after redaction")
+			}
+			if err := ts.FinalVerify(logID, records); err != nil {
+				t.Fatalf("FinalVerify failed after redaction: %v", err)
+			}
+
+			// Read without the redactable mode, the log does not verify.
+			if _, err := VerifyFrom(records, 0, a0, zeroTag); !errors.Is(err, ErrTagMismatch) {
+				t.Errorf("Expected ErrTagMismatch verifying a redactable log as plain, got: %v", err)
+			}
+
+			// A marker T did not sign, or whose fields were changed, is rejected.
+			unsigned := auth
+			unsigned.Signature = nil
+			forgedRec, err := RedactRecord(original[entry.Index], Redaction{
+				LogID: logID, Index: entry.Index + 1, Reason: "cover-up", At: time.Now(),
+				Commitment: mustCommitment(t, original[entry.Index]),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			altered := auth
+			altered.Reason = "routine cleanup"
+			for name, forged := range map[string]Record{
+				"unsigned": mustRedactRecord(t, original[entry.Index-1], unsigned),
+				"altered":  mustRedactRecord(t, original[entry.Index-1], altered),
+				"self":     forgedRec,
+			} {
+				tampered := append([]Record(nil), records...)
+				tampered[forged.Index-1] = forged
+				if _, err := VerifyChainContext(context.Background(), tampered, 0, a0, zeroTag, true, opts); !errors.Is(err, ErrRedactionUnauthorized) {
+					t.Errorf("%s marker: expected ErrRedactionUnauthorized, got: %v", name, err)
+				}
+				if _, err := Redactions(tampered, key); !errors.Is(err, ErrRedactionUnauthorized) {
+					t.Errorf("%s marker: expected Redactions to fail, got: %v", name, err)
+				}
+				if err := ts.FinalVerify(logID, tampered); !errors.Is(err, ErrRedactionUnauthorized) {
+					t.Errorf("%s marker: expected FinalVerify to fail, got: %v", name, err)
+				}
+			}
+
+			// Forging the commitment in a marker is tampering, not redaction.
+			forged := append([]Record(nil), records...)
+			forged[entry.Index-1].Msg = append([]byte(nil), red.Msg...)
+			forged[entry.Index-1].Msg[len(envelopeMagic)+1] ^= 0xFF
+			if _, err := VerifyChainContext(context.Background(), forged, 0, a0, zeroTag, true, opts); !errors.Is(err, ErrTagMismatch) {
+				t.Errorf("Expected ErrTagMismatch for forged commitment, got: %v", err)
+			}
+
+			// Replacing a payload with the bare covered bytes erases it without a marker.
+			forged = append([]Record(nil), records...)
+			forged[entry.Index].Msg, _ = macMessage(records[entry.Index].Msg, true)
+			if _, err := VerifyChainContext(context.Background(), forged, 0, a0, zeroTag, true, opts); !errors.Is(err, ErrTagMismatch) {
+				t.Errorf("Expected ErrTagMismatch for a bare commitment, got: %v", err)
+			}
+
+			// Changing a payload that is still present is tampering too.
+			forged = append([]Record(nil), records...)
+			forged[entry.Index].Msg = append([]byte(nil), records[entry.Index].Msg...)
+			forged[entry.Index].Msg[len(forged[entry.Index].Msg)-1] ^= 0xFF
+			if _, err := VerifyChainContext(context.Background(), forged, 0, a0, zeroTag, true, opts); !errors.Is(err, ErrTagMismatch) {
+				t.Errorf("Expected ErrTagMismatch for modified payload, got: %v", err)
+			}
+		})
+	}
+}
+
+func mustCommitment(t *testing.T, r Record) [32]byte {
+	t.Helper()
+	c, ok := commitmentOf(r.Msg)
+	if !ok {
+		t.Fatalf("Record %d is not redactable", r.Index)
+	}
+	return c
+}
+
+func mustRedactRecord(t *testing.T, r Record, red Redaction) Record {
+	t.Helper()
+	out, err := RedactRecord(r, red)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// redactForTest redacts record idx of store with an authorisation from a
+// throwaway T and returns T's redaction key.
+func redactForTest(t *testing.T, store Store, idx uint64) (ed25519.PublicKey, error) {
+	t.Helper()
+	ts := NewTrustedServer()
+	ts.RegisterLog(InitCommitment{LogID: "redact-test", Redactable: true})
+	recs, err := collectRange(context.Background(), store, idx, idx)
+	if err != nil || len(recs) != 1 {
+		t.Fatalf("Reading record %d: %d records, %v", idx, len(recs), err)
+	}
+	red, err := ts.AuthorizeRedaction("redact-test", recs[0], "gdpr", "dpo")
+	if err != nil {
+		return nil, err
+	}
+	return ts.RedactionPublicKey(), Redact(store, red)
+}
+
+func TestRedact_NotRedactable(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-redact-plain-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, _ := logger.GetInitialKeys()
+	if _, err := logger.Append([]byte("plain"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := Redact(store, Redaction{Index: 1}); !errors.Is(err, ErrNotRedactable) {
+		t.Errorf("Expected ErrNotRedactable, got: %v", err)
+	}
+
+	// Plain logs take any message, including one shaped like an envelope,
+	// and MAC it as given.
+	sealed, err := sealMessage([]byte("looks like an envelope"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := logger.Append(sealed, time.Now()); err != nil {
+		t.Fatalf("Append of an envelope-shaped message failed: %v", err)
+	}
+	records := readAllRecords(t, store)
+	if !bytes.Equal(records[1].Msg, sealed) {
+		t.Error("Envelope-shaped message was not stored as given")
+	}
+	var zeroTag [32]byte
+	if _, err := VerifyFrom(records, 0, a0, zeroTag); err != nil {
+		t.Fatalf("V-chain with an envelope-shaped message: %v", err)
+	}
+
+	// Turning it into a redaction marker is tampering on a plain log.
+	marked, err := RedactRecord(records[1], Redaction{Index: 2, Commitment: mustCommitment(t, records[1])})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records[1] = marked
+	if _, err := VerifyFrom(records, 0, a0, zeroTag); !errors.Is(err, ErrTagMismatch) {
+		t.Errorf("Expected ErrTagMismatch for a marker on a plain log, got: %v", err)
+	}
+
+	// T only authorises redactions on logs registered as redactable.
+	ts := NewTrustedServer()
+	ts.RegisterLog(InitCommitment{LogID: "plain"})
+	if _, err := ts.AuthorizeRedaction("plain", records[1], "reason", "someone"); !errors.Is(err, ErrNotRedactable) {
+		t.Errorf("Expected ErrNotRedactable from AuthorizeRedaction, got: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/tls"
 	"encoding/gob"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -21,6 +22,7 @@ type Server struct {
 	mu            sync.RWMutex
 	stores        map[string]Store // Map of logID -> Store for verification
 	tlsConfig     *tls.Config
+	tsPolicy      TimestampPolicy
 }
 
 // NewServer creates a new HTTPS server for trusted server T.
@@ -41,6 +43,14 @@ func (s *Server) SetTLSConfig(cfg *tls.Config) {
 	s.tlsConfig = cfg.Clone()
 }
 
+// SetTimestampPolicy sets the timestamp checks applied by HandleVerify.
+// Violations are reported as findings in the verification response.
+func (s *Server) SetTimestampPolicy(p TimestampPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tsPolicy = p
+}
+
 // RegisterStore associates a log ID with its storage backend.
 // Required before verification can be performed.
 func (s *Server) RegisterStore(logID string, store Store) {
@@ -122,6 +132,28 @@ func decodeCloseMessage(r *http.Request) (CloseMessage, error) {
 	return closeMsg, nil
 }
 
+// decodeSignedTreeHead decodes SignedTreeHead from either Gob or Protobuf.
+func decodeSignedTreeHead(r *http.Request) (SignedTreeHead, error) {
+	if isProtobuf(r) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return SignedTreeHead{}, fmt.Errorf("read body: %w", err)
+		}
+		var pbHead pb.SignedTreeHead
+		if err := proto.Unmarshal(body, &pbHead); err != nil {
+			return SignedTreeHead{}, fmt.Errorf("unmarshal protobuf: %w", err)
+		}
+		return FromProtoSignedTreeHead(&pbHead)
+	}
+
+	// Default to Gob
+	var sth SignedTreeHead
+	if err := gob.NewDecoder(r.Body).Decode(&sth); err != nil {
+		return SignedTreeHead{}, fmt.Errorf("decode gob: %w", err)
+	}
+	return sth, nil
+}
+
 // decodeVerifyRequest decodes verify request from either Gob or Protobuf.
 func decodeVerifyRequest(r *http.Request) (string, []Record, error) {
 	// Extract logID from path
@@ -153,11 +185,24 @@ func decodeVerifyRequest(r *http.Request) (string, []Record, error) {
 }
 
 // encodeVerifyResponse encodes verify response in the appropriate format.
-func encodeVerifyResponse(w http.ResponseWriter, r *http.Request, logID string, verified bool, errMsg string) error {
+// Policy findings carried by verifyErr are included in the response.
+func encodeVerifyResponse(w http.ResponseWriter, r *http.Request, logID string, verifyErr error) error {
+	verified := verifyErr == nil
+	var errMsg string
+	var findings []Finding
+	if verifyErr != nil {
+		errMsg = verifyErr.Error()
+		var policyErr *PolicyError
+		if errors.As(verifyErr, &policyErr) {
+			findings = policyErr.Findings
+		}
+	}
+
 	if isProtobuf(r) {
 		resp := &pb.VerifyResponse{
 			Verified:     verified,
 			ErrorMessage: errMsg,
+			Findings:     ToProtoFindings(findings),
 		}
 		data, err := proto.Marshal(resp)
 		if err != nil {
@@ -170,13 +215,21 @@ func encodeVerifyResponse(w http.ResponseWriter, r *http.Request, logID string,
 	}
 
 	// Default to JSON
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	return json.NewEncoder(w).Encode(map[string]any{
+	body := map[string]any{
 		"status":   "verified",
 		"log_id":   logID,
 		"verified": verified,
-	})
+	}
+	if len(findings) > 0 {
+		out := make([]map[string]any, len(findings))
+		for i, f := range findings {
+			out[i] = map[string]any{"index": f.Index, "kind": f.Kind.Error(), "detail": f.Detail}
+		}
+		body["findings"] = out
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(body)
 }
 
 // HandleRegister handles POST /api/v1/logs/register - initial commitment.
@@ -251,6 +304,42 @@ func (s *Server) HandleClose(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// HandleTreeHead handles POST /api/v1/logs/treehead - signed tree head submission.
+// Supports both Gob and Protocol Buffer encoding. A head that conflicts with
+// one already recorded is answered with 409 Conflict, a head not signed by the
+// log's registered key with 403 Forbidden.
+func (s *Server) HandleTreeHead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sth, err := decodeSignedTreeHead(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid tree head: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.TrustedServer.RecordTreeHead(sth); err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, ErrTreeHeadConflict):
+			status = http.StatusConflict
+		case errors.Is(err, ErrTreeHeadKeyMismatch):
+			status = http.StatusForbidden
+		}
+		http.Error(w, fmt.Sprintf("Record tree head failed: %v", err), status)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"status": "recorded",
+		"log_id": sth.Head.LogID,
+		"size":   sth.Head.Size,
+	})
+}
+
 // HandleVerify handles POST /api/v1/logs/{logID}/verify - final verification.
 // Supports both Gob and Protocol Buffer encoding.
 func (s *Server) HandleVerify(w http.ResponseWriter, r *http.Request) {
@@ -265,17 +354,21 @@ func (s *Server) HandleVerify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Perform verification
-	if err := s.TrustedServer.FinalVerify(logID, records); err != nil {
+	s.mu.RLock()
+	opts := VerifyOptions{Timestamps: s.tsPolicy}
+	s.mu.RUnlock()
+
+	// Perform verification; stop early if the client goes away
+	if err := s.TrustedServer.FinalVerifyContext(r.Context(), logID, records, opts); err != nil {
 		// Send error response in appropriate format
-		if encErr := encodeVerifyResponse(w, r, logID, false, err.Error()); encErr != nil {
+		if encErr := encodeVerifyResponse(w, r, logID, err); encErr != nil {
 			http.Error(w, fmt.Sprintf("Verification failed: %v", err), http.StatusUnauthorized)
 		}
 		return
 	}
 
 	// Send success response
-	if err := encodeVerifyResponse(w, r, logID, true, ""); err != nil {
+	if err := encodeVerifyResponse(w, r, logID, nil); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
 	}
 }
@@ -285,6 +378,7 @@ func (s *Server) SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/v1/logs/register", s.HandleRegister)
 	mux.HandleFunc("/api/v1/logs/open", s.HandleOpen)
 	mux.HandleFunc("/api/v1/logs/close", s.HandleClose)
+	mux.HandleFunc("/api/v1/logs/treehead", s.HandleTreeHead)
 	mux.HandleFunc("/api/v1/logs/", s.HandleVerify) // Catch-all for verify
 }
 
@@ -0,0 +1,177 @@
+package securelog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrSearchDisabled is returned by Search on a database without a full-text
+// index (see EnableSQLiteSearch).
+var ErrSearchDisabled = errors.New("full-text search is not enabled")
+
+// SearchStore is implemented by stores that keep a full-text index over
+// record messages.
+type SearchStore interface {
+	// Search yields the records whose message matches query in index order.
+	Search(query string) iter.Seq2[Record, error]
+}
+
+// sqliteSearchSchema is the optional full-text index. logs_fts is a
+// contentless FTS5 table, so messages are not stored twice; logs_fts_rows maps
+// its rowids to records, since the rowids of logs may change on VACUUM. Only
+// messages that are valid UTF-8 are indexed, and records of redactable logs
+// by their payload. The store takes a log's mode from the leaf hashes the
+// logger gave it (see storedRedactable).
+const sqliteSearchSchema = `
+CREATE TABLE IF NOT EXISTS logs_fts_rows (
+  id     INTEGER PRIMARY KEY,   -- rowid in logs_fts
+  log_id TEXT    NOT NULL,
+  idx    INTEGER NOT NULL,
+  UNIQUE (log_id, idx)
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS logs_fts USING fts5(msg, content='', contentless_delete=1);
+`
+
+// EnableSQLiteSearch adds a full-text index over the messages of every log in
+// db, which must have been opened with OpenSQLiteDB, and indexes the records
+// already stored. From then on appends, redactions and pruning keep it up to
+// date. It does nothing if db already has the index.
+func EnableSQLiteSearch(db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	enabled, err := sqliteSearchEnabled(ctx, tx)
+	if err != nil || enabled {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, sqliteSearchSchema); err != nil {
+		return fmt.Errorf("create search index: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT l.log_id, l.idx, l.ts, l.msg, l.codec, l.tagV, l.tagT, t.leaf
+		FROM logs l LEFT JOIN tree t ON t.log_id = l.log_id AND t.idx = l.idx
+		ORDER BY l.log_id, l.idx`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var logID string
+		var r Record
+		var codec byte
+		var tagV, tagT, leaf []byte
+		if err := rows.Scan(&logID, &r.Index, &r.TS, &r.Msg, &codec, &tagV, &tagT, &leaf); err != nil {
+			return err
+		}
+		if r.Msg, err = decompressMsg(codec, r.Msg); err != nil {
+			return fmt.Errorf("record %d of log %q: %w", r.Index, logID, err)
+		}
+		var l [32]byte
+		copy(r.TagV[:], tagV)
+		copy(r.TagT[:], tagT)
+		copy(l[:], leaf)
+		if err := indexSearchMsg(ctx, tx, logID, r.Index, r.Msg, storedRedactable(r, l)); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqliteSearchEnabled reports whether the database has the full-text index.
+func sqliteSearchEnabled(ctx context.Context, tx *sql.Tx) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='logs_fts_rows'`).Scan(&n)
+	return n > 0, err
+}
+
+// searchText returns the text indexed for a stored message of a log of the
+// given mode: the payload on a redactable log, and nothing for redacted or
+// encrypted records or messages that are not valid UTF-8.
+func searchText(stored []byte, redactable bool) (string, bool) {
+	msg, ok := messageOf(Record{Msg: stored}, redactable)
+	if !ok || envelopeKind(msg) == envelopeEncrypted || !utf8.Valid(msg) {
+		return "", false
+	}
+	return string(msg), true
+}
+
+// indexSearchMsg adds record idx of logID to the full-text index, which must
+// exist.
+func indexSearchMsg(ctx context.Context, tx *sql.Tx, logID string, idx uint64, msg []byte, redactable bool) error {
+	text, ok := searchText(msg, redactable)
+	if !ok {
+		return nil
+	}
+	res, err := tx.ExecContext(ctx, `INSERT INTO logs_fts_rows(log_id, idx) VALUES(?, ?)`, logID, idx)
+	if err != nil {
+		return fmt.Errorf("index record %d: %w", idx, err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO logs_fts(rowid, msg) VALUES(?, ?)`, id, text); err != nil {
+		return fmt.Errorf("index record %d: %w", idx, err)
+	}
+	return nil
+}
+
+// unindexSearch removes the records of logID with from <= idx <= to from the
+// full-text index, which must exist.
+func unindexSearch(ctx context.Context, tx *sql.Tx, logID string, from, to uint64) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM logs_fts WHERE rowid IN
+		(SELECT id FROM logs_fts_rows WHERE log_id=? AND idx >= ? AND idx <= ?)`, logID, from, to); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `DELETE FROM logs_fts_rows WHERE log_id=? AND idx >= ? AND idx <= ?`, logID, from, to)
+	return err
+}
+
+// Search yields the records whose message matches the FTS5 query in index
+// order, e.g. "login AND failed" or "admin*". Hits are read from the log
+// itself, so they carry their tags and can be verified like any other record,
+// e.g. with SemiTrustedVerifier.VerifyFromAnchor from the latest anchor before
+// them. It fails with ErrSearchDisabled unless the database has a full-text
+// index.
+func (s *sqliteStore) Search(query string) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		var n int
+		if err := s.db.QueryRow(
+			`SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='logs_fts_rows'`).Scan(&n); err != nil {
+			yield(Record{}, err)
+			return
+		}
+		if n == 0 {
+			yield(Record{}, ErrSearchDisabled)
+			return
+		}
+		rows, err := s.db.Query(`SELECT l.idx, l.ts, l.msg, l.codec, l.tagV, l.tagT
+			FROM logs_fts f
+			JOIN logs_fts_rows m ON m.id = f.rowid
+			JOIN logs l ON l.log_id = m.log_id AND l.idx = m.idx
+			WHERE logs_fts MATCH ? AND m.log_id = ? ORDER BY l.idx ASC`, query, s.logID)
+		if err != nil {
+			yield(Record{}, fmt.Errorf("search %q: %w", query, err))
+			return
+		}
+		defer rows.Close()
+		for r, err := range scanRecords(rows) {
+			if !yield(r, err) || err != nil {
+				return
+			}
+		}
+	}
+}
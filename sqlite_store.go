@@ -5,15 +5,167 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"iter"
+	"math"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite" // Import SQLite driver for database/sql
 )
 
-type sqliteStore struct{ db *sql.DB }
+// sqliteStore is one log in a SQLite database. Every table is keyed by log
+// ID, so a database can hold many logs sharing one *sql.DB; OpenSQLiteStore
+// uses the default log, whose ID is empty.
+type sqliteStore struct {
+	db       *sql.DB
+	logID    string
+	ownsDB   bool // opened by OpenSQLiteStore; Close closes db
+	readOnly bool
+	compress bool // compress messages on write (see compressMsg)
+
+	compressed compressionCounter
+}
+
+// SQLiteStoreOptions configures OpenSQLiteStoreWithOptions.
+type SQLiteStoreOptions struct {
+	Check    CheckMode // check (and repair) the store before returning it
+	ReadOnly bool      // open an existing database for reading only (see OpenSQLiteDBReadOnly)
+	Search   bool      // keep a full-text index over messages (see EnableSQLiteSearch); ignored when ReadOnly
+	Compress bool      // compress messages below the MAC layer (see CompressionStats); ignored when ReadOnly
+}
+
+// SQLiteLogStoreOptions configures OpenSQLiteLogStoreWithOptions.
+type SQLiteLogStoreOptions struct {
+	Compress bool // compress messages below the MAC layer (see CompressionStats)
+}
+
+// sqliteSchemaVersion is stored in PRAGMA user_version. Version 0 databases
+// hold a single log in tables without a log_id column; version 1 lacks the
+// index on timestamps and versions 1 and 2 the codec column.
+const sqliteSchemaVersion = 3
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS log_ids (
+  log_id TEXT PRIMARY KEY
+);
+CREATE TABLE IF NOT EXISTS logs (
+  log_id TEXT    NOT NULL,
+  idx    INTEGER NOT NULL,
+  ts     INTEGER NOT NULL,
+  msg    BLOB    NOT NULL,      -- message as stored, encoded with codec
+  codec  INTEGER NOT NULL DEFAULT 0,
+  tagV   BLOB    NOT NULL,      -- μ_V,i (semi-trusted verifier chain tag)
+  tagT   BLOB    NOT NULL,      -- μ_T,i (trusted server chain tag)
+  PRIMARY KEY (log_id, idx)
+);
+CREATE TABLE IF NOT EXISTS tail (
+  log_id TEXT    PRIMARY KEY,
+  idx    INTEGER NOT NULL,
+  tagV   BLOB    NOT NULL,
+  tagT   BLOB    NOT NULL
+);
+CREATE TABLE IF NOT EXISTS anchors (
+  log_id TEXT    NOT NULL,
+  idx    INTEGER NOT NULL,
+  key    BLOB    NOT NULL,      -- A_i (verifier key at checkpoint i)
+  tagV   BLOB    NOT NULL,      -- μ_V,i at checkpoint i
+  tagT   BLOB    NOT NULL,      -- μ_T,i at checkpoint i
+  PRIMARY KEY (log_id, idx)
+);
+CREATE TABLE IF NOT EXISTS tree (
+  log_id TEXT    NOT NULL,
+  idx    INTEGER NOT NULL,      -- record index
+  leaf   BLOB    NOT NULL,      -- Merkle leaf hash of the record
+  root   BLOB    NOT NULL,      -- Merkle root over records 1..idx
+  PRIMARY KEY (log_id, idx)
+);
+CREATE INDEX IF NOT EXISTS logs_ts ON logs(log_id, ts);
+`
+
+// sqliteMigrateV0 moves the single log of a version 0 database into the
+// default log. The version 0 tables are created first, since databases
+// written before the tree table existed lack it.
+const sqliteMigrateV0 = `
+CREATE TABLE IF NOT EXISTS tail (
+  id    INTEGER PRIMARY KEY CHECK(id=1),
+  idx   INTEGER NOT NULL,
+  tagV  BLOB    NOT NULL,
+  tagT  BLOB    NOT NULL
+);
+CREATE TABLE IF NOT EXISTS anchors (
+  idx   INTEGER PRIMARY KEY,
+  key   BLOB NOT NULL,
+  tagV  BLOB NOT NULL,
+  tagT  BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS tree (
+  idx   INTEGER PRIMARY KEY,
+  leaf  BLOB NOT NULL,
+  root  BLOB NOT NULL
+);
+ALTER TABLE logs RENAME TO logs_v0;
+ALTER TABLE tail RENAME TO tail_v0;
+ALTER TABLE anchors RENAME TO anchors_v0;
+ALTER TABLE tree RENAME TO tree_v0;
+` + sqliteSchema + `
+INSERT INTO log_ids(log_id) VALUES('');
+INSERT INTO logs(log_id, idx, ts, msg, tagV, tagT) SELECT '', idx, ts, msg, tagV, tagT FROM logs_v0;
+INSERT INTO tail(log_id, idx, tagV, tagT) SELECT '', idx, tagV, tagT FROM tail_v0;
+INSERT INTO anchors(log_id, idx, key, tagV, tagT) SELECT '', idx, key, tagV, tagT FROM anchors_v0;
+INSERT INTO tree(log_id, idx, leaf, root) SELECT '', idx, leaf, root FROM tree_v0;
+DROP TABLE logs_v0;
+DROP TABLE tail_v0;
+DROP TABLE anchors_v0;
+DROP TABLE tree_v0;
+`
+
+// sqliteAddCodec adds the codec column to a version 1 or 2 database, whose
+// messages are all stored as is.
+const sqliteAddCodec = `
+ALTER TABLE logs ADD COLUMN codec INTEGER NOT NULL DEFAULT 0;
+` + sqliteSchema
 
 // OpenSQLiteStore opens/creates a SQLite DB and ensures schema + PRAGMAs.
+// The store is the default log of the database.
 func OpenSQLiteStore(dsn string) (Store, error) {
+	return OpenSQLiteStoreWithOptions(dsn, SQLiteStoreOptions{})
+}
+
+// OpenSQLiteStoreWithOptions is OpenSQLiteStore with options.
+func OpenSQLiteStoreWithOptions(dsn string, opts SQLiteStoreOptions) (Store, error) {
+	open, openStore := OpenSQLiteDB, func(db *sql.DB, logID string) (Store, error) {
+		return OpenSQLiteLogStoreWithOptions(db, logID, SQLiteLogStoreOptions{Compress: opts.Compress})
+	}
+	if opts.ReadOnly {
+		open, openStore = OpenSQLiteDBReadOnly, OpenSQLiteLogStoreReadOnly
+	}
+	db, err := open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Search && !opts.ReadOnly {
+		if err := EnableSQLiteSearch(db); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+	st, err := openStore(db, "")
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	st.(*sqliteStore).ownsDB = true
+	if err := checkOnOpen(st, opts.Check); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return st, nil
+}
+
+// OpenSQLiteDB opens/creates a SQLite DB for OpenSQLiteLogStore, sets the
+// PRAGMAs the stores rely on and creates or upgrades the schema. A database
+// written before logs were keyed by log ID keeps its log as the default log.
+func OpenSQLiteDB(dsn string) (*sql.DB, error) {
 	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, err
@@ -22,7 +174,6 @@ func OpenSQLiteStore(dsn string) (Store, error) {
 		_ = db.Close()
 		return nil, err
 	}
-	st := &sqliteStore{db: db}
 	for _, p := range []string{
 		"PRAGMA journal_mode=WAL;",
 		"PRAGMA synchronous=FULL;",
@@ -35,37 +186,149 @@ func OpenSQLiteStore(dsn string) (Store, error) {
 			return nil, fmt.Errorf("set %s: %w", p, err)
 		}
 	}
-	schema := `
-CREATE TABLE IF NOT EXISTS logs (
-  idx   INTEGER PRIMARY KEY,
-  ts    INTEGER NOT NULL,
-  msg   BLOB    NOT NULL,
-  tagV  BLOB    NOT NULL,      -- μ_V,i (semi-trusted verifier chain tag)
-  tagT  BLOB    NOT NULL       -- μ_T,i (trusted server chain tag)
-);
-CREATE TABLE IF NOT EXISTS tail (
-  id    INTEGER PRIMARY KEY CHECK(id=1),
-  idx   INTEGER NOT NULL,
-  tagV  BLOB    NOT NULL,
-  tagT  BLOB    NOT NULL
-);
-CREATE TABLE IF NOT EXISTS anchors (
-  idx   INTEGER PRIMARY KEY,
-  key   BLOB NOT NULL,      -- A_i (verifier key at checkpoint i)
-  tagV  BLOB NOT NULL,      -- μ_V,i at checkpoint i
-  tagT  BLOB NOT NULL       -- μ_T,i at checkpoint i
-);
-CREATE UNIQUE INDEX IF NOT EXISTS anchors_idx_uq ON anchors(idx);
-`
-	if _, err := db.Exec(schema); err != nil {
+	if err := ensureSQLiteSchema(db); err != nil {
 		_ = db.Close()
 		return nil, err
 	}
-	return st, nil
+	return db, nil
+}
+
+// OpenSQLiteDBReadOnly opens a SQLite DB written through OpenSQLiteDB for
+// reading only, for OpenSQLiteLogStoreReadOnly. Nothing is created or
+// upgraded, so the schema must be current, and SQLite refuses any write.
+func OpenSQLiteDBReadOnly(dsn string) (*sql.DB, error) {
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite", dsn+sep+"mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec("PRAGMA busy_timeout=5000;"); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("set busy_timeout: %w", err)
+	}
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if version != sqliteSchemaVersion {
+		_ = db.Close()
+		return nil, fmt.Errorf("%w: sqlite schema version %d (open read-write to upgrade)", ErrUnsupportedFormat, version)
+	}
+	return db, nil
+}
+
+// ensureSQLiteSchema creates the schema, migrating a version 0 database.
+func ensureSQLiteSchema(db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var version int
+	if err := tx.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	switch {
+	case version == sqliteSchemaVersion:
+		return nil
+	case version > sqliteSchemaVersion:
+		return fmt.Errorf("%w: sqlite schema version %d", ErrUnsupportedFormat, version)
+	}
+	var legacy int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='logs'`).Scan(&legacy); err != nil {
+		return err
+	}
+	schema := sqliteSchema
+	switch {
+	case version == 0 && legacy > 0:
+		schema = sqliteMigrateV0
+	case version > 0:
+		schema = sqliteAddCodec
+	}
+	if _, err := tx.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create sqlite schema: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version=%d`, sqliteSchemaVersion)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// OpenSQLiteLogStore returns the store of log logID in db, which must have
+// been opened with OpenSQLiteDB, and registers the log if it is new. Stores
+// of different logs share db and may be used concurrently; db stays open
+// until the caller closes it.
+func OpenSQLiteLogStore(db *sql.DB, logID string) (Store, error) {
+	return OpenSQLiteLogStoreWithOptions(db, logID, SQLiteLogStoreOptions{})
+}
+
+// OpenSQLiteLogStoreWithOptions is OpenSQLiteLogStore with options. Compression
+// only changes what a store writes, so stores of one database may differ and
+// still read each other's records.
+func OpenSQLiteLogStoreWithOptions(db *sql.DB, logID string, opts SQLiteLogStoreOptions) (Store, error) {
+	if _, err := db.Exec(`INSERT INTO log_ids(log_id) VALUES(?) ON CONFLICT(log_id) DO NOTHING`, logID); err != nil {
+		return nil, fmt.Errorf("register log %q: %w", logID, err)
+	}
+	return &sqliteStore{db: db, logID: logID, compress: opts.Compress}, nil
+}
+
+// OpenSQLiteLogStoreReadOnly returns the store of the existing log logID in
+// db for reading only; its write methods return ErrReadOnly.
+func OpenSQLiteLogStoreReadOnly(db *sql.DB, logID string) (Store, error) {
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM log_ids WHERE log_id=?`, logID).Scan(&n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("log %q not found", logID)
+	}
+	return &sqliteStore{db: db, logID: logID, readOnly: true}, nil
+}
+
+// Close closes the database if the store opened it; stores returned by
+// OpenSQLiteLogStore leave the shared database to its owner.
+func (s *sqliteStore) Close() error {
+	if !s.ownsDB {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// ListSQLiteLogs returns the IDs of the logs in db in ascending order. The
+// default log has the empty ID.
+func ListSQLiteLogs(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT log_id FROM log_ids ORDER BY log_id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
 }
 
 // Append stores a record, updates tail state, and optionally stores an anchor checkpoint.
 func (s *sqliteStore) Append(r Record, tail TailState, anchor *Anchor) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
@@ -74,73 +337,170 @@ func (s *sqliteStore) Append(r Record, tail TailState, anchor *Anchor) error {
 	}
 	defer func() { _ = tx.Rollback() }()
 	var maxIdx sql.NullInt64
-	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(idx),0) FROM logs`).Scan(&maxIdx.Int64); err != nil {
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(idx),0) FROM logs WHERE log_id=?`, s.logID).Scan(&maxIdx.Int64); err != nil {
 		return err
 	}
 	if uint64(maxIdx.Int64) != r.Index-1 {
 		return fmt.Errorf("non-contiguous append: have %d, got %d", maxIdx.Int64, r.Index)
 	}
 
-	if _, err := tx.ExecContext(ctx, `INSERT INTO logs(idx, ts, msg, tagV, tagT) VALUES(?, ?, ?, ?, ?)`,
-		r.Index, r.TS, r.Msg, r.TagV[:], r.TagT[:]); err != nil {
+	codec, stored := s.packMsg(r.Msg)
+	if _, err := tx.ExecContext(ctx, `INSERT INTO logs(log_id, idx, ts, msg, codec, tagV, tagT) VALUES(?, ?, ?, ?, ?, ?, ?)`,
+		s.logID, r.Index, r.TS, stored, codec, r.TagV[:], r.TagT[:]); err != nil {
+		return err
+	}
+	leaf := appendedLeaf(r, tail)
+	redactable := storedRedactable(r, leaf)
+	if _, ok := searchText(r.Msg, redactable); ok {
+		enabled, err := sqliteSearchEnabled(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if enabled {
+			if err := indexSearchMsg(ctx, tx, s.logID, r.Index, r.Msg, redactable); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO tree(log_id, idx, leaf, root) VALUES(?, ?, ?, ?)`,
+		s.logID, r.Index, leaf[:], tail.TreeRoot[:]); err != nil {
 		return err
 	}
 
 	if anchor != nil {
 		if _, err := tx.ExecContext(ctx,
-			`INSERT INTO anchors(idx, key, tagV, tagT) VALUES(?, ?, ?, ?)
-			 ON CONFLICT(idx) DO UPDATE SET key=excluded.key, tagV=excluded.tagV, tagT=excluded.tagT`,
-			anchor.Index, anchor.Key[:], anchor.TagV[:], anchor.TagT[:]); err != nil {
+			`INSERT INTO anchors(log_id, idx, key, tagV, tagT) VALUES(?, ?, ?, ?, ?)
+			 ON CONFLICT(log_id, idx) DO UPDATE SET key=excluded.key, tagV=excluded.tagV, tagT=excluded.tagT`,
+			s.logID, anchor.Index, anchor.Key[:], anchor.TagV[:], anchor.TagT[:]); err != nil {
 			return err
 		}
 	}
 
 	if _, err := tx.ExecContext(ctx,
-		`INSERT INTO tail(id, idx, tagV, tagT) VALUES(1, ?, ?, ?)
-		 ON CONFLICT(id) DO UPDATE SET idx=excluded.idx, tagV=excluded.tagV, tagT=excluded.tagT`,
-		tail.Index, tail.TagV[:], tail.TagT[:]); err != nil {
+		`INSERT INTO tail(log_id, idx, tagV, tagT) VALUES(?, ?, ?, ?)
+		 ON CONFLICT(log_id) DO UPDATE SET idx=excluded.idx, tagV=excluded.tagV, tagT=excluded.tagT`,
+		s.logID, tail.Index, tail.TagV[:], tail.TagT[:]); err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.compressed.add(len(r.Msg), len(stored), codec)
+	return nil
+}
+
+// packMsg returns the codec and stored bytes for msg, compressing it if the
+// store compresses and that saves space.
+func (s *sqliteStore) packMsg(msg []byte) (byte, []byte) {
+	if !s.compress {
+		return codecRaw, msg
+	}
+	return compressMsg(msg)
+}
+
+// CompressionStats reports the messages appended since the store was opened.
+func (s *sqliteStore) CompressionStats() CompressionStats {
+	return s.compressed.get()
 }
 
 // Iter returns a channel that streams records starting from startIdx in ascending order.
 func (s *sqliteStore) Iter(startIdx uint64) (<-chan Record, func() error, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	query := `SELECT idx, ts, msg, tagV, tagT FROM logs WHERE idx >= ? ORDER BY idx ASC`
-	rows, err := s.db.QueryContext(ctx, query, startIdx)
-	if err != nil {
-		cancel()
-		return nil, nil, err
-	}
-	out := make(chan Record, 64)
-	go func() {
-		defer close(out)
+	out, done := seqChan(s.All(startIdx))
+	return out, done, nil
+}
+
+// All yields the records with Index >= start.
+func (s *sqliteStore) All(start uint64) iter.Seq2[Record, error] {
+	return s.Range(start, math.MaxUint64)
+}
+
+// Range yields the records with start <= Index <= end.
+func (s *sqliteStore) Range(start, end uint64) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		if end > math.MaxInt64 {
+			end = math.MaxInt64
+		}
+		rows, err := s.db.Query(`SELECT idx, ts, msg, codec, tagV, tagT FROM logs
+			WHERE log_id = ? AND idx >= ? AND idx <= ? ORDER BY idx ASC`, s.logID, start, int64(end))
+		if err != nil {
+			yield(Record{}, err)
+			return
+		}
 		defer rows.Close()
-		defer cancel()
+		for r, err := range scanRecords(rows) {
+			if !yield(r, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// scanRecords yields the records in rows, which select idx, ts, msg, codec,
+// tagV and tagT, decompressing their messages. Read failures are yielded as
+// *StoreReadError.
+func scanRecords(rows *sql.Rows) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		var last uint64
 		for rows.Next() {
 			var idx uint64
 			var ts int64
+			var codec byte
 			var msg, tagVBytes, tagTBytes []byte
-			if err := rows.Scan(&idx, &ts, &msg, &tagVBytes, &tagTBytes); err != nil {
+			if err := rows.Scan(&idx, &ts, &msg, &codec, &tagVBytes, &tagTBytes); err != nil {
+				yield(Record{}, &StoreReadError{Offset: -1, Index: last, Err: err})
+				return
+			}
+			if len(tagVBytes) != 32 || len(tagTBytes) != 32 {
+				yield(Record{}, &StoreReadError{Offset: -1, Index: last, Err: fmt.Errorf("record %d: invalid tag sizes", idx)})
+				return
+			}
+			msg, err := decompressMsg(codec, msg)
+			if err != nil {
+				yield(Record{}, &StoreReadError{Offset: -1, Index: last, Err: fmt.Errorf("record %d: %w", idx, err)})
 				return
 			}
 			var tagV, tagT [32]byte
 			copy(tagV[:], tagVBytes)
 			copy(tagT[:], tagTBytes)
-			out <- Record{Index: idx, TS: ts, Msg: msg, TagV: tagV, TagT: tagT}
+			if !yield(Record{Index: idx, TS: ts, Msg: msg, TagV: tagV, TagT: tagT}, nil) {
+				return
+			}
+			last = idx
+		}
+		if err := rows.Err(); err != nil {
+			yield(Record{}, &StoreReadError{Offset: -1, Index: last, Err: err})
+		}
+	}
+}
+
+// IterTime yields the records with from <= TS < to in index order.
+func (s *sqliteStore) IterTime(from, to time.Time) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		rows, err := s.db.Query(`SELECT idx, ts, msg, codec, tagV, tagT FROM logs
+			WHERE log_id = ? AND ts >= ? AND ts < ? ORDER BY idx ASC`, s.logID, from.UnixNano(), to.UnixNano())
+		if err != nil {
+			yield(Record{}, err)
+			return
 		}
-	}()
-	return out, func() error { cancel(); return nil }, nil
+		defer rows.Close()
+		for r, err := range scanRecords(rows) {
+			if !yield(r, err) || err != nil {
+				return
+			}
+		}
+	}
 }
 
 // AnchorAt retrieves the anchor checkpoint at the specified index.
 func (s *sqliteStore) AnchorAt(i uint64) (Anchor, bool, error) {
 	var zero Anchor
 	var idx int64
-	var key, tagV, tagT []byte
-	err := s.db.QueryRow(`SELECT idx, key, tagV, tagT FROM anchors WHERE idx=?`, i).Scan(&idx, &key, &tagV, &tagT)
+	var key, tagV, tagT, root []byte
+	err := s.db.QueryRow(`SELECT a.idx, a.key, a.tagV, a.tagT, t.root
+		FROM anchors a LEFT JOIN tree t ON t.log_id = a.log_id AND t.idx = a.idx
+		WHERE a.log_id=? AND a.idx=?`, s.logID, i).Scan(&idx, &key, &tagV, &tagT, &root)
 	if errors.Is(err, sql.ErrNoRows) {
 		return zero, false, nil
 	}
@@ -155,12 +515,15 @@ func (s *sqliteStore) AnchorAt(i uint64) (Anchor, bool, error) {
 	copy(out.Key[:], key)
 	copy(out.TagV[:], tagV)
 	copy(out.TagT[:], tagT)
+	copy(out.TreeRoot[:], root)
 	return out, true, nil
 }
 
 // ListAnchors returns all stored anchor checkpoints in ascending order by index.
 func (s *sqliteStore) ListAnchors() ([]Anchor, error) {
-	rows, err := s.db.Query(`SELECT idx, key, tagV, tagT FROM anchors ORDER BY idx ASC`)
+	rows, err := s.db.Query(`SELECT a.idx, a.key, a.tagV, a.tagT, t.root
+		FROM anchors a LEFT JOIN tree t ON t.log_id = a.log_id AND t.idx = a.idx
+		WHERE a.log_id=? ORDER BY a.idx ASC`, s.logID)
 	if err != nil {
 		return nil, err
 	}
@@ -168,19 +531,20 @@ func (s *sqliteStore) ListAnchors() ([]Anchor, error) {
 	var out []Anchor
 	for rows.Next() {
 		var idx uint64
-		var keyB, tagVB, tagTB []byte
-		if err := rows.Scan(&idx, &keyB, &tagVB, &tagTB); err != nil {
+		var keyB, tagVB, tagTB, rootB []byte
+		if err := rows.Scan(&idx, &keyB, &tagVB, &tagTB, &rootB); err != nil {
 			return nil, err
 		}
 		if len(keyB) != KeySize || len(tagVB) != 32 || len(tagTB) != 32 {
 			continue
 		}
 		var k [KeySize]byte
-		var tv, tt [32]byte
+		var tv, tt, root [32]byte
 		copy(k[:], keyB)
 		copy(tv[:], tagVB)
 		copy(tt[:], tagTB)
-		out = append(out, Anchor{Index: idx, Key: k, TagV: tv, TagT: tt})
+		copy(root[:], rootB)
+		out = append(out, Anchor{Index: idx, Key: k, TagV: tv, TagT: tt, TreeRoot: root})
 	}
 	return out, nil
 }
@@ -189,8 +553,10 @@ func (s *sqliteStore) ListAnchors() ([]Anchor, error) {
 func (s *sqliteStore) Tail() (TailState, bool, error) {
 	var tail TailState
 	var idx int64
-	var tagV, tagT []byte
-	err := s.db.QueryRow(`SELECT idx, tagV, tagT FROM tail WHERE id=1`).Scan(&idx, &tagV, &tagT)
+	var tagV, tagT, root, leaf []byte
+	err := s.db.QueryRow(`SELECT tl.idx, tl.tagV, tl.tagT, t.root, t.leaf
+		FROM tail tl LEFT JOIN tree t ON t.log_id = tl.log_id AND t.idx = tl.idx
+		WHERE tl.log_id=?`, s.logID).Scan(&idx, &tagV, &tagT, &root, &leaf)
 	if errors.Is(err, sql.ErrNoRows) {
 		return tail, false, nil
 	}
@@ -203,5 +569,159 @@ func (s *sqliteStore) Tail() (TailState, bool, error) {
 	tail.Index = uint64(idx)
 	copy(tail.TagV[:], tagV)
 	copy(tail.TagT[:], tagT)
+	copy(tail.TreeRoot[:], root)
+	copy(tail.Leaf[:], leaf)
 	return tail, true, nil
 }
+
+// LeafHashes returns the stored Merkle leaf hashes for records in [from, to].
+// The result stops early where the tree table does not cover the range.
+func (s *sqliteStore) LeafHashes(from, to uint64) ([][32]byte, error) {
+	rows, err := s.db.Query(`SELECT idx, leaf FROM tree
+		WHERE log_id = ? AND idx >= ? AND idx <= ? ORDER BY idx ASC`, s.logID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out [][32]byte
+	next := from
+	for rows.Next() {
+		var idx uint64
+		var leafB []byte
+		if err := rows.Scan(&idx, &leafB); err != nil {
+			return nil, err
+		}
+		if idx != next || len(leafB) != 32 {
+			break
+		}
+		var leaf [32]byte
+		copy(leaf[:], leafB)
+		out = append(out, leaf)
+		next++
+	}
+	return out, rows.Err()
+}
+
+// ReplaceMsg replaces the stored message of record idx, keeping its index,
+// timestamp and tags. It is used for redaction, so the full-text index entry
+// of the old message is dropped with it.
+func (s *sqliteStore) ReplaceMsg(idx uint64, msg []byte) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	codec, stored := s.packMsg(msg)
+	res, err := tx.ExecContext(ctx, `UPDATE logs SET msg=?, codec=? WHERE log_id=? AND idx=?`, stored, codec, s.logID, idx)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return fmt.Errorf("record %d not found", idx)
+	}
+	enabled, err := sqliteSearchEnabled(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		if err := unindexSearch(ctx, tx, s.logID, idx, idx); err != nil {
+			return err
+		}
+		// The record keeps its leaf, which tells the log's mode.
+		r := Record{Index: idx, Msg: msg}
+		var tagV, tagT, leaf []byte
+		err := tx.QueryRowContext(ctx, `SELECT l.ts, l.tagV, l.tagT, t.leaf FROM logs l
+			LEFT JOIN tree t ON t.log_id = l.log_id AND t.idx = l.idx
+			WHERE l.log_id=? AND l.idx=?`, s.logID, idx).Scan(&r.TS, &tagV, &tagT, &leaf)
+		if err != nil {
+			return err
+		}
+		var l [32]byte
+		copy(r.TagV[:], tagV)
+		copy(r.TagT[:], tagT)
+		copy(l[:], leaf)
+		if err := indexSearchMsg(ctx, tx, s.logID, idx, msg, storedRedactable(r, l)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ResetTail drops anchors and tree rows beyond tail.Index and replaces the
+// tail row. tail.Index must be the last record.
+func (s *sqliteStore) ResetTail(tail TailState) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	var maxIdx int64
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(idx),0) FROM logs WHERE log_id=?`, s.logID).Scan(&maxIdx); err != nil {
+		return err
+	}
+	if uint64(maxIdx) != tail.Index {
+		return fmt.Errorf("tail at %d is not the last record %d", tail.Index, maxIdx)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM anchors WHERE log_id=? AND idx > ?`, s.logID, tail.Index); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tree WHERE log_id=? AND idx > ?`, s.logID, tail.Index); err != nil {
+		return err
+	}
+	if tail.Index == 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM tail WHERE log_id=?`, s.logID); err != nil {
+			return err
+		}
+	} else if _, err := tx.ExecContext(ctx,
+		`INSERT INTO tail(log_id, idx, tagV, tagT) VALUES(?, ?, ?, ?)
+		 ON CONFLICT(log_id) DO UPDATE SET idx=excluded.idx, tagV=excluded.tagV, tagT=excluded.tagT`,
+		s.logID, tail.Index, tail.TagV[:], tail.TagT[:]); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Prune deletes records and anchors with index below beforeIndex. The tree
+// table is kept, since leaf hashes carry no payload and are needed for proofs.
+func (s *sqliteStore) Prune(beforeIndex uint64) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM logs WHERE log_id=? AND idx < ?`, s.logID, beforeIndex); err != nil {
+		return err
+	}
+	enabled, err := sqliteSearchEnabled(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if enabled && beforeIndex > 0 {
+		if err := unindexSearch(ctx, tx, s.logID, 0, beforeIndex-1); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM anchors WHERE log_id=? AND idx < ?`, s.logID, beforeIndex); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -1,8 +1,11 @@
 package securelog
 
 import (
+	"database/sql"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -72,6 +75,50 @@ func TestSQLiteStore_Iter(t *testing.T) {
 	}
 }
 
+func TestSQLiteStore_IterReadError(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-sqlite-iter-err-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenSQLiteStore(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := logger.Append([]byte("test"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := store.(*sqliteStore).db.Exec(`UPDATE logs SET tagV = x'00' WHERE log_id = '' AND idx = 4`); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, done, err := store.Iter(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for range ch {
+		count++
+	}
+	err = done()
+	if count != 3 {
+		t.Errorf("Expected 3 readable records, got %d", count)
+	}
+	var readErr *StoreReadError
+	if !errors.As(err, &readErr) || readErr.Index != 3 {
+		t.Fatalf("Expected *StoreReadError after record 3, got: %v", err)
+	}
+}
+
 func TestSQLiteStore_AnchorAt(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "securelog-sqlite-anchor-*")
 	if err != nil {
@@ -184,7 +231,7 @@ func TestSQLiteStore_InvalidAnchorData(t *testing.T) {
 	sqlStore := store.(*sqliteStore)
 
 	// Manually insert invalid anchor with wrong sizes
-	_, err = sqlStore.db.Exec(`INSERT INTO anchors(idx, key, tagV, tagT) VALUES(?, ?, ?, ?)`,
+	_, err = sqlStore.db.Exec(`INSERT INTO anchors(log_id, idx, key, tagV, tagT) VALUES('', ?, ?, ?, ?)`,
 		1, []byte{1, 2, 3}, []byte{4, 5}, []byte{6})
 	if err != nil {
 		t.Fatal(err)
@@ -196,3 +243,391 @@ func TestSQLiteStore_InvalidAnchorData(t *testing.T) {
 		t.Error("Expected error reading invalid anchor")
 	}
 }
+
+func TestSQLiteLogStore_MultipleLogs(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-sqlite-multi-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "logs.db")
+	db, err := OpenSQLiteDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{"app-a", "app-b", "app-c"}
+	loggers := make(map[string]*Logger)
+	keys := make(map[string][KeySize]byte)
+	for i, id := range ids {
+		store, err := OpenSQLiteLogStore(db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger, err := New(Config{AnchorEvery: 3}, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loggers[id] = logger
+		keys[id], _ = logger.GetInitialKeys()
+		for j := 0; j < 4+i; j++ {
+			if _, err := logger.Append([]byte(id), time.Now()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if got, err := ListSQLiteLogs(db); err != nil || !reflect.DeepEqual(got, ids) {
+		t.Fatalf("ListSQLiteLogs = %v, %v", got, err)
+	}
+
+	if err := loggers["app-b"].store.(Pruner).Prune(4); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+
+	// Each log keeps its own records, anchors and tail.
+	db, err = OpenSQLiteDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var zeroTag [32]byte
+	for i, id := range ids {
+		store, err := OpenSQLiteLogStore(db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records := readAllRecords(t, store)
+		for _, r := range records {
+			if string(r.Msg) != id {
+				t.Fatalf("Log %s holds a record of %s", id, r.Msg)
+			}
+		}
+		tail, ok, err := store.Tail()
+		if err != nil || !ok || tail.Index != uint64(4+i) {
+			t.Errorf("Log %s: tail %+v, %v, %v", id, tail, ok, err)
+		}
+		anchors, err := store.ListAnchors()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id == "app-b" {
+			if len(records) != 2 || len(anchors) != 0 {
+				t.Errorf("Pruned log kept %d records and %d anchors", len(records), len(anchors))
+			}
+			continue
+		}
+		if len(records) != 4+i || len(anchors) != (4+i)/3 {
+			t.Errorf("Log %s: %d records, %d anchors", id, len(records), len(anchors))
+		}
+		if _, err := VerifyFrom(records, 0, keys[id], zeroTag); err != nil {
+			t.Errorf("Log %s: V-chain failed: %v", id, err)
+		}
+		if rep, err := CheckStore(store); err != nil || !rep.OK() {
+			t.Errorf("Log %s: CheckStore = %+v, %v", id, rep, err)
+		}
+	}
+
+	store, err := OpenSQLiteLogStore(db, "app-d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := store.Tail(); ok {
+		t.Error("Expected a new log to have no tail")
+	}
+	if got, _ := ListSQLiteLogs(db); len(got) != 4 {
+		t.Errorf("Expected 4 logs, got %v", got)
+	}
+}
+
+func TestSQLiteStore_MigratesSingleLogSchema(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-sqlite-v0-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	mem := NewMemoryStore()
+	logger, err := New(Config{AnchorEvery: 3}, mem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, _ := logger.GetInitialKeys()
+	for i := 0; i < 7; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Write the log in the schema used before logs were keyed by log ID.
+	dbPath := filepath.Join(tmpDir, "v0.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+CREATE TABLE logs (idx INTEGER PRIMARY KEY, ts INTEGER NOT NULL, msg BLOB NOT NULL, tagV BLOB NOT NULL, tagT BLOB NOT NULL);
+CREATE TABLE tail (id INTEGER PRIMARY KEY CHECK(id=1), idx INTEGER NOT NULL, tagV BLOB NOT NULL, tagT BLOB NOT NULL);
+CREATE TABLE anchors (idx INTEGER PRIMARY KEY, key BLOB NOT NULL, tagV BLOB NOT NULL, tagT BLOB NOT NULL);
+CREATE UNIQUE INDEX anchors_idx_uq ON anchors(idx);`); err != nil {
+		t.Fatal(err)
+	}
+	records := readAllRecords(t, mem)
+	for _, r := range records {
+		if _, err := db.Exec(`INSERT INTO logs VALUES(?, ?, ?, ?, ?)`, r.Index, r.TS, r.Msg, r.TagV[:], r.TagT[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	anchors, _ := mem.ListAnchors()
+	for _, a := range anchors {
+		if _, err := db.Exec(`INSERT INTO anchors VALUES(?, ?, ?, ?)`, a.Index, a.Key[:], a.TagV[:], a.TagT[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tail, _, _ := mem.Tail()
+	if _, err := db.Exec(`INSERT INTO tail VALUES(1, ?, ?, ?)`, tail.Index, tail.TagV[:], tail.TagT[:]); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+
+	store, err := OpenSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("Opening a single-log database failed: %v", err)
+	}
+	sqlStore := store.(*sqliteStore)
+	defer sqlStore.db.Close()
+	if got := readAllRecords(t, store); !reflect.DeepEqual(got, records) {
+		t.Fatal("Migration changed the records")
+	}
+	if got, _ := store.ListAnchors(); len(got) != len(anchors) || got[1].Key != anchors[1].Key {
+		t.Errorf("Migration changed the anchors: %+v", got)
+	}
+	if got, ok, _ := store.Tail(); !ok || got.Index != tail.Index || got.TagV != tail.TagV {
+		t.Errorf("Migration changed the tail: %+v", got)
+	}
+	if got, err := ListSQLiteLogs(sqlStore.db); err != nil || !reflect.DeepEqual(got, []string{""}) {
+		t.Errorf("ListSQLiteLogs = %q, %v", got, err)
+	}
+
+	logger.store = store
+	if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+		t.Fatalf("Append after migration: %v", err)
+	}
+	var zeroTag [32]byte
+	if _, err := VerifyFrom(readAllRecords(t, store), 0, a0, zeroTag); err != nil {
+		t.Fatalf("V-chain after migration failed: %v", err)
+	}
+}
+
+func TestSQLiteStore_ReadOnlyAndClose(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-sqlite-ro-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "logs.db")
+	db, err := OpenSQLiteDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, id := range []string{"", "app-a"} {
+		store, err := OpenSQLiteLogStore(db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger, err := New(Config{AnchorEvery: 2}, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 4; i++ {
+			if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Closing a store of a shared database leaves the database open.
+		if err := store.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatalf("Closing a log store closed the shared database: %v", err)
+	}
+
+	store, err := OpenSQLiteStoreWithOptions(dbPath, SQLiteStoreOptions{ReadOnly: true, Check: CheckOnOpen})
+	if err != nil {
+		t.Fatalf("Opening read-only failed: %v", err)
+	}
+	if got := readAllRecords(t, store); len(got) != 4 {
+		t.Errorf("Expected 4 records, got %d", len(got))
+	}
+	if err := store.Append(Record{Index: 5}, TailState{Index: 5}, nil); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got: %v", err)
+	}
+	if _, err := store.(*sqliteStore).db.Exec(`DELETE FROM logs`); err == nil {
+		t.Error("Expected SQLite to refuse a write through a read-only handle")
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.(*sqliteStore).db.Ping(); err == nil {
+		t.Error("Expected Close to close a database the store opened")
+	}
+
+	roDB, err := OpenSQLiteDBReadOnly(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer roDB.Close()
+	store, err = OpenSQLiteLogStoreReadOnly(roDB, "app-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tail, ok, err := store.Tail(); err != nil || !ok || tail.Index != 4 {
+		t.Errorf("Tail = %+v, %v, %v", tail, ok, err)
+	}
+	if _, err := OpenSQLiteLogStoreReadOnly(roDB, "app-b"); err == nil {
+		t.Error("Expected opening an unknown log read-only to fail")
+	}
+	if got, _ := ListSQLiteLogs(roDB); len(got) != 2 {
+		t.Errorf("Opening read-only registered a log: %v", got)
+	}
+}
+
+func TestSQLiteStore_Search(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-sqlite-search-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "logs.db")
+	db, err := OpenSQLiteDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	search := func(store Store, query string) ([]uint64, error) {
+		var got []uint64
+		for r, err := range store.(SearchStore).Search(query) {
+			if err != nil {
+				return got, err
+			}
+			got = append(got, r.Index)
+		}
+		return got, nil
+	}
+	messages := []string{
+		"user alice login failed",
+		"user bob login ok",
+		"\xff\xfe login failed",
+		"config reloaded",
+		"user alice login ok",
+		"user carol login failed",
+	}
+
+	plain, err := OpenSQLiteLogStore(db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainLogger, err := New(Config{AnchorEvery: 2}, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range messages[:3] {
+		if _, err := plainLogger.Append([]byte(m), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := search(plain, "login"); !errors.Is(err, ErrSearchDisabled) {
+		t.Fatalf("Expected ErrSearchDisabled before the index exists, got: %v", err)
+	}
+
+	// Records stored before the index is enabled are indexed too.
+	if err := EnableSQLiteSearch(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnableSQLiteSearch(db); err != nil {
+		t.Fatalf("Enabling search twice: %v", err)
+	}
+	for _, m := range messages[3:] {
+		if _, err := plainLogger.Append([]byte(m), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	redactable, err := OpenSQLiteLogStore(db, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redactLogger, err := New(Config{AnchorEvery: 2, Redactable: true}, redactable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range messages {
+		if _, err := redactLogger.Append([]byte(m), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  []uint64
+	}{
+		{"login AND failed", []uint64{1, 6}},
+		{"alice", []uint64{1, 5}},
+		{"car*", []uint64{6}},
+		{`"login ok"`, []uint64{2, 5}},
+		{"nobody", nil},
+	} {
+		for _, store := range []Store{plain, redactable} {
+			got, err := search(store, tc.query)
+			if err != nil {
+				t.Fatalf("Search(%q): %v", tc.query, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Search(%q) = %v, want %v", tc.query, got, tc.want)
+			}
+		}
+	}
+	if _, err := search(plain, `"unterminated`); err == nil {
+		t.Error("Expected an error for a malformed query")
+	}
+
+	// A hit verifies from the latest anchor before it.
+	for r, err := range plain.(SearchStore).Search("carol") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := NewSemiTrustedVerifier(plain).VerifyFromAnchor(mustAnchor(t, plain, 4)); err != nil {
+			t.Errorf("Verifying the span of hit %d: %v", r.Index, err)
+		}
+	}
+
+	// Redacted payloads and pruned records drop out of the index.
+	if _, err := redactForTest(t, redactable, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := search(redactable, "alice"); err != nil || !reflect.DeepEqual(got, []uint64{5}) {
+		t.Errorf("Search after redaction = %v, %v", got, err)
+	}
+	if err := plain.(Pruner).Prune(3); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := search(plain, "failed"); err != nil || !reflect.DeepEqual(got, []uint64{6}) {
+		t.Errorf("Search after pruning = %v, %v", got, err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM logs_fts_rows WHERE log_id='' AND idx < 3`).Scan(&n); err != nil || n != 0 {
+		t.Errorf("Pruned records left %d index rows: %v", n, err)
+	}
+
+	ro, err := OpenSQLiteStoreWithOptions(dbPath, SQLiteStoreOptions{ReadOnly: true, Search: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if got, err := search(ro, "alice"); err != nil || !reflect.DeepEqual(got, []uint64{5}) {
+		t.Errorf("Read-only search = %v, %v", got, err)
+	}
+}
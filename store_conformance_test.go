@@ -0,0 +1,254 @@
+package securelog
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+// conformanceBackend opens a Store implementation for the conformance suite.
+// reopen is nil for stores that do not persist.
+type conformanceBackend struct {
+	name   string
+	open   func(dir string) (Store, error)
+	reopen func(dir string) (Store, error)
+}
+
+func conformanceBackends() []conformanceBackend {
+	fileOpen := func(dir string) (Store, error) { return OpenFileStore(filepath.Join(dir, "file")) }
+	segmentedOpen := func(dir string) (Store, error) {
+		return OpenFileStoreWithOptions(filepath.Join(dir, "segmented"), FileStoreOptions{SegmentMaxBytes: 1024})
+	}
+	sqliteOpen := func(dir string) (Store, error) { return OpenSQLiteStore(filepath.Join(dir, "logs.db")) }
+	boltOpen := func(dir string) (Store, error) { return OpenBoltStore(filepath.Join(dir, "logs.bolt")) }
+	compressedOpen := func(dir string) (Store, error) {
+		return OpenBoltStoreWithOptions(filepath.Join(dir, "logs.bolt"), BoltStoreOptions{Compress: true})
+	}
+	return []conformanceBackend{
+		{"memory", func(string) (Store, error) { return NewMemoryStore(), nil }, nil},
+		{"file", fileOpen, fileOpen},
+		{"segmented", segmentedOpen, segmentedOpen},
+		{"sqlite", sqliteOpen, sqliteOpen},
+		{"bolt", boltOpen, boltOpen},
+		{"bolt-compressed", compressedOpen, boltOpen},
+	}
+}
+
+// TestStoreConformance runs the same checks against every Store
+// implementation: ordering, anchors, tail, tree state, the optional
+// interfaces and persistence across reopening.
+func TestStoreConformance(t *testing.T) {
+	for _, b := range conformanceBackends() {
+		t.Run(b.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "securelog-conformance-*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tmpDir)
+
+			store, err := b.open(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { closeStore(store) }()
+			for name, ok := range map[string]bool{
+				"SeqStore":        implements[SeqStore](store),
+				"TimeStore":       implements[TimeStore](store),
+				"TreeStore":       implements[TreeStore](store),
+				"RedactableStore": implements[RedactableStore](store),
+				"Pruner":          implements[Pruner](store),
+				"Repairer":        implements[Repairer](store),
+			} {
+				if !ok {
+					t.Errorf("%T does not implement %s", store, name)
+				}
+			}
+
+			// An empty store has no records, anchors or tail.
+			if _, ok, err := store.Tail(); err != nil || ok {
+				t.Errorf("Tail of an empty store = %v, %v", ok, err)
+			}
+			if anchors, err := store.ListAnchors(); err != nil || len(anchors) != 0 {
+				t.Errorf("ListAnchors of an empty store = %v, %v", anchors, err)
+			}
+			if got := readAllRecords(t, store); len(got) != 0 {
+				t.Errorf("Empty store yielded %d records", len(got))
+			}
+
+			logger, err := New(Config{AnchorEvery: 3, Redactable: true}, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a0, _ := logger.GetInitialKeys()
+			base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+			var msgs [][]byte
+			for i := 0; i < 10; i++ {
+				msg := bytes.Repeat([]byte{byte('a' + i)}, i*40)
+				msgs = append(msgs, msg)
+				if _, err := logger.Append(msg, base.Add(time.Duration(i)*time.Second)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Appends must be contiguous and leave the store alone otherwise.
+			tail, ok, err := store.Tail()
+			if err != nil || !ok || tail.Index != 10 {
+				t.Fatalf("Tail = %+v, %v, %v", tail, ok, err)
+			}
+			if err := store.Append(Record{Index: 12}, TailState{Index: 12}, nil); err == nil {
+				t.Error("Expected a non-contiguous append to fail")
+			}
+			if err := store.Append(Record{Index: 10}, TailState{Index: 10}, nil); err == nil {
+				t.Error("Expected a duplicate append to fail")
+			}
+			if got, _, _ := store.Tail(); got != tail {
+				t.Errorf("A failed append changed the tail to %+v", got)
+			}
+
+			records := readAllRecords(t, store)
+			if len(records) != 10 {
+				t.Fatalf("Expected 10 records, got %d", len(records))
+			}
+			for i, r := range records {
+				payload, _ := r.Payload()
+				if r.Index != uint64(i+1) || r.TS != base.Add(time.Duration(i)*time.Second).UnixNano() || !bytes.Equal(payload, msgs[i]) {
+					t.Errorf("Record %d = {%d %d %q}", i+1, r.Index, r.TS, payload)
+				}
+			}
+			if tail.TagV != records[9].TagV || tail.TagT != records[9].TagT {
+				t.Error("Tail tags do not match the last record")
+			}
+			if got := readAllRecordsFrom(t, store, 8); len(got) != 3 || got[0].Index != 8 {
+				t.Errorf("Iter(8) yielded %d records", len(got))
+			}
+			if got := readAllRecordsFrom(t, store, 11); len(got) != 0 {
+				t.Errorf("Iter(11) yielded %d records", len(got))
+			}
+			var ranged []uint64
+			for r, err := range RecordRange(store, 4, 6) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				ranged = append(ranged, r.Index)
+			}
+			if !reflect.DeepEqual(ranged, []uint64{4, 5, 6}) {
+				t.Errorf("RecordRange(4, 6) yielded %v", ranged)
+			}
+			if got := collectTime(t, store, base.Add(2*time.Second), base.Add(5*time.Second)); !reflect.DeepEqual(got, []uint64{3, 4, 5}) {
+				t.Errorf("RecordsInTime yielded %v", got)
+			}
+
+			// Anchors carry the tree root at their index.
+			anchors, err := store.ListAnchors()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var anchorIdx []uint64
+			for _, a := range anchors {
+				anchorIdx = append(anchorIdx, a.Index)
+				if a.TagV != records[a.Index-1].TagV || a.TagT != records[a.Index-1].TagT {
+					t.Errorf("Anchor %d tags do not match its record", a.Index)
+				}
+			}
+			if !reflect.DeepEqual(anchorIdx, []uint64{3, 6, 9}) {
+				t.Errorf("ListAnchors = %v, want [3 6 9]", anchorIdx)
+			}
+			if a, ok, err := store.AnchorAt(6); err != nil || !ok || a != anchors[1] {
+				t.Errorf("AnchorAt(6) = %+v, %v, %v", a, ok, err)
+			}
+			if _, ok, err := store.AnchorAt(5); err != nil || ok {
+				t.Errorf("AnchorAt(5) = %v, %v", ok, err)
+			}
+			leaves, err := store.(TreeStore).LeafHashes(1, 10)
+			if err != nil || len(leaves) != 10 {
+				t.Fatalf("LeafHashes(1, 10) = %d hashes, %v", len(leaves), err)
+			}
+			for i, r := range records {
+				if leaves[i] != LeafHash(r, true) {
+					t.Errorf("Leaf hash %d does not match its record", r.Index)
+				}
+			}
+			if got, _ := store.(TreeStore).LeafHashes(9, 20); len(got) != 2 {
+				t.Errorf("LeafHashes(9, 20) = %d hashes, want 2", len(got))
+			}
+			if anchors[2].TreeRoot != mth(leaves[:9]) || tail.TreeRoot != mth(leaves) {
+				t.Error("Stored tree roots do not match the leaves")
+			}
+
+			var zeroTag [32]byte
+			redactable := VerifyOptions{Redactable: true}
+			if _, err := VerifyChainContext(context.Background(), records, 0, a0, zeroTag, true, redactable); err != nil {
+				t.Fatalf("V-chain: %v", err)
+			}
+			if err := NewSemiTrustedVerifier(store).VerifyFromAnchorContext(context.Background(), anchors[0], redactable); err != nil {
+				t.Fatalf("VerifyFromAnchor: %v", err)
+			}
+			if rep, err := CheckStore(store); err != nil || !rep.OK() || rep.Records != 10 {
+				t.Fatalf("CheckStore = %+v, %v", rep, err)
+			}
+
+			// Redaction keeps the chains verifiable.
+			if redactable.RedactionKey, err = redactForTest(t, store, 2); err != nil {
+				t.Fatal(err)
+			}
+			records = readAllRecords(t, store)
+			if _, ok := records[1].Payload(); ok {
+				t.Error("Expected record 2 to be redacted")
+			}
+			if _, err := VerifyChainContext(context.Background(), records, 0, a0, zeroTag, true, redactable); err != nil {
+				t.Fatalf("V-chain after redaction: %v", err)
+			}
+
+			// The state survives reopening.
+			if b.reopen != nil {
+				closeStore(store)
+				if store, err = b.reopen(tmpDir); err != nil {
+					t.Fatalf("Reopening failed: %v", err)
+				}
+				if got := readAllRecords(t, store); !reflect.DeepEqual(got, records) {
+					t.Error("Records changed across reopening")
+				}
+				if got, _ := store.ListAnchors(); !reflect.DeepEqual(got, anchors) {
+					t.Error("Anchors changed across reopening")
+				}
+				if got, _, _ := store.Tail(); got != tail {
+					t.Errorf("Tail after reopening = %+v, want %+v", got, tail)
+				}
+				logger.store = store
+			}
+			if _, err := logger.Append([]byte("after"), base.Add(time.Minute)); err != nil {
+				t.Fatalf("Append after reopening: %v", err)
+			}
+
+			// Pruning drops records and anchors before the index, not the tree.
+			if err := store.(Pruner).Prune(5); err != nil {
+				t.Fatal(err)
+			}
+			if got := readAllRecords(t, store); len(got) != 7 || got[0].Index != 5 {
+				t.Errorf("After Prune(5) the store holds %d records", len(got))
+			}
+			if got, _ := store.ListAnchors(); len(got) != 2 || got[0].Index != 6 {
+				t.Errorf("After Prune(5) the store holds anchors %+v", got)
+			}
+			if got, _ := store.(TreeStore).LeafHashes(1, 11); len(got) != 11 {
+				t.Errorf("After Prune(5) LeafHashes(1, 11) = %d hashes", len(got))
+			}
+			if err := NewSemiTrustedVerifier(store).VerifyFromAnchorContext(context.Background(), mustAnchor(t, store, 6), redactable); err != nil {
+				t.Fatalf("VerifyFromAnchor after pruning: %v", err)
+			}
+		})
+	}
+}
+
+func implements[T any](store Store) bool {
+	_, ok := store.(T)
+	return ok
+}
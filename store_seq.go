@@ -0,0 +1,108 @@
+package securelog
+
+import (
+	"context"
+	"iter"
+	"math"
+	"sync"
+)
+
+// SeqStore is implemented by stores that expose records as range-over-func
+// iterators. Unlike Iter they need no goroutine or cleanup call: breaking out
+// of the loop releases the underlying file or query.
+//
+// Each sequence yields (record, nil) in index order. A failure is yielded
+// once as (Record{}, err), typically a *StoreReadError, and ends the sequence.
+type SeqStore interface {
+	// All yields the records with Index >= start.
+	All(start uint64) iter.Seq2[Record, error]
+	// Range yields the records with start <= Index <= end.
+	Range(start, end uint64) iter.Seq2[Record, error]
+}
+
+// AllRecords yields the records of st with Index >= start, using st's own
+// iterator when it implements SeqStore and adapting Iter otherwise.
+func AllRecords(st Store, start uint64) iter.Seq2[Record, error] {
+	if s, ok := st.(SeqStore); ok {
+		return s.All(start)
+	}
+	return RecordRange(st, start, math.MaxUint64)
+}
+
+// RecordRange yields the records of st with start <= Index <= end, using st's
+// own iterator when it implements SeqStore and adapting Iter otherwise.
+func RecordRange(st Store, start, end uint64) iter.Seq2[Record, error] {
+	if s, ok := st.(SeqStore); ok {
+		return s.Range(start, end)
+	}
+	return func(yield func(Record, error) bool) {
+		ch, done, err := st.Iter(start)
+		if err != nil {
+			yield(Record{}, err)
+			return
+		}
+		for r := range ch {
+			if r.Index > end || !yield(r, nil) {
+				_ = done()
+				return
+			}
+		}
+		if err := done(); err != nil {
+			yield(Record{}, err)
+		}
+	}
+}
+
+// collectRecords reads the records of st from startIdx on, stopping early
+// when ctx is cancelled. Read failures are returned as *StoreReadError.
+func collectRecords(ctx context.Context, st Store, startIdx uint64) ([]Record, error) {
+	return collectRange(ctx, st, startIdx, math.MaxUint64)
+}
+
+// collectRange reads the records of st with from <= Index <= to, stopping
+// early when ctx is cancelled.
+func collectRange(ctx context.Context, st Store, from, to uint64) ([]Record, error) {
+	var recs []Record
+	for r, err := range RecordRange(st, from, to) {
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		recs = append(recs, r)
+	}
+	return recs, ctx.Err()
+}
+
+// seqChan runs seq in a goroutine to serve the channel-based Iter API.
+// The returned func stops the goroutine and reports the error that ended seq.
+func seqChan(seq iter.Seq2[Record, error]) (<-chan Record, func() error) {
+	out := make(chan Record, 64)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	var seqErr error
+
+	go func() {
+		defer close(finished)
+		defer close(out)
+		for r, err := range seq {
+			if err != nil {
+				seqErr = err
+				return
+			}
+			select {
+			case out <- r:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return out, func() error {
+		once.Do(func() { close(done) })
+		<-finished
+		return seqErr
+	}
+}
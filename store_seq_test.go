@@ -0,0 +1,131 @@
+package securelog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+// plainStore hides any optional interfaces of the wrapped store, standing in
+// for a third-party Store that only implements Iter.
+type plainStore struct{ Store }
+
+func TestSeqStore_AllStores(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-seq-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fileSt, err := OpenFileStore(filepath.Join(tmpDir, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileSt.(*fileStore).Close()
+
+	sqliteSt, err := OpenSQLiteStore(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, store := range map[string]Store{"file": fileSt, "sqlite": sqliteSt} {
+		logger, err := New(Config{}, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 10; i++ {
+			if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for kind, st := range map[string]Store{"native": store, "adapter": plainStore{store}} {
+			t.Run(name+"/"+kind, func(t *testing.T) {
+				if _, ok := st.(SeqStore); ok != (kind == "native") {
+					t.Fatalf("Unexpected SeqStore implementation: %v", ok)
+				}
+
+				var got []uint64
+				for r, err := range AllRecords(st, 3) {
+					if err != nil {
+						t.Fatal(err)
+					}
+					got = append(got, r.Index)
+				}
+				if len(got) != 8 || got[0] != 3 || got[7] != 10 {
+					t.Errorf("AllRecords(3) yielded %v", got)
+				}
+
+				got = nil
+				for r, err := range RecordRange(st, 4, 6) {
+					if err != nil {
+						t.Fatal(err)
+					}
+					got = append(got, r.Index)
+				}
+				if len(got) != 3 || got[0] != 4 || got[2] != 6 {
+					t.Errorf("RecordRange(4, 6) yielded %v", got)
+				}
+
+				// Breaking out early releases the iterator.
+				for r, err := range AllRecords(st, 1) {
+					if err != nil || r.Index != 1 {
+						t.Fatalf("Unexpected first record %d: %v", r.Index, err)
+					}
+					break
+				}
+			})
+		}
+	}
+}
+
+func TestSeqStore_ReadError(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-seq-err-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entrySize := int64(headerSize + codecSize + len("entry") + tagsSize + checksumSize)
+	if err := os.Truncate(filepath.Join(tmpDir, logsFileName), fileHeaderSize+4*entrySize+3); err != nil {
+		t.Fatal(err)
+	}
+
+	for kind, st := range map[string]Store{"native": store, "adapter": plainStore{store}} {
+		count := 0
+		var readErr *StoreReadError
+		for _, err := range AllRecords(st, 1) {
+			if err != nil {
+				if !errors.As(err, &readErr) {
+					t.Fatalf("%s: expected *StoreReadError, got: %v", kind, err)
+				}
+				continue
+			}
+			count++
+		}
+		if count != 4 || readErr == nil || readErr.Offset != fileHeaderSize+4*entrySize {
+			t.Errorf("%s: got %d records and error %v", kind, count, readErr)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package securelog
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"iter"
+	"math"
+	"time"
+)
+
+// TimeStore is implemented by stores that index records by timestamp.
+type TimeStore interface {
+	// IterTime yields the records with from <= TS < to in index order.
+	IterTime(from, to time.Time) iter.Seq2[Record, error]
+}
+
+// RecordsInTime yields the records of st with from <= TS < to in index order,
+// using st's time index when it implements TimeStore and scanning the whole
+// log otherwise. Timestamps need not be monotonic.
+func RecordsInTime(st Store, from, to time.Time) iter.Seq2[Record, error] {
+	if s, ok := st.(TimeStore); ok {
+		return s.IterTime(from, to)
+	}
+	lo, hi := from.UnixNano(), to.UnixNano()
+	return func(yield func(Record, error) bool) {
+		for r, err := range AllRecords(st, 1) {
+			if err != nil {
+				yield(Record{}, err)
+				return
+			}
+			if r.TS >= lo && r.TS < hi && !yield(r, nil) {
+				return
+			}
+		}
+	}
+}
+
+// TimeWindow is a span of a log selected by time, together with the anchors
+// a semi-trusted verifier checks it between. Records runs from the record
+// after Anchor up to End, so it also holds the records in between whose
+// timestamps lie outside the window.
+type TimeWindow struct {
+	From, To time.Time
+	Anchor   Anchor   // latest anchor before the first record in the window; Index 0 if there is none
+	End      Anchor   // first anchor at or after the last record in the window; Index 0 if there is none
+	Records  []Record // records Anchor.Index+1 .. End.Index, or to the last record in the window without End
+	// Redactable is set by the auditor for windows of logs written with
+	// Config.Redactable; Verify then checks the records as redactable.
+	Redactable bool
+}
+
+// Matching returns the records of w with From <= TS < To.
+func (w TimeWindow) Matching() []Record {
+	lo, hi := w.From.UnixNano(), w.To.UnixNano()
+	var out []Record
+	for _, r := range w.Records {
+		if r.TS >= lo && r.TS < hi {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Verify checks the V-chain over w.Records from w.Anchor to w.End. A window
+// without an anchor starts at the beginning of the log: set w.Anchor.Key to
+// A_1 from TrustedServer.ReleaseA1 first.
+//
+// Verify proves the records authentic and contiguous. Ending on w.End ties
+// the last record in the window to the rest of the log: records cut from the
+// end of the window fail to match End unless End and everything after it go
+// too. A window without End reaches the log's tail, and dropping its last
+// records goes unnoticed here: check the tail against T or a signed tree
+// head. Verify cannot show that no record after the window has a timestamp
+// inside it.
+func (w TimeWindow) Verify() error {
+	return w.VerifyContext(context.Background(), VerifyOptions{})
+}
+
+// VerifyContext is Verify with cancellation, progress reporting and
+// timestamp policy checks.
+func (w TimeWindow) VerifyContext(ctx context.Context, opts VerifyOptions) error {
+	if len(w.Records) == 0 {
+		return nil
+	}
+	opts.Redactable = opts.Redactable || w.Redactable
+	co := newChainOptions(opts)
+	co.firstKey = w.Anchor.Index == 0
+	final, err := verifyChain(ctx, w.Records, w.Anchor.Index, w.Anchor.Key, w.Anchor.TagV, true, co)
+	co.progress.finish(err)
+	if err != nil {
+		return err
+	}
+	if w.End.Index != 0 && !hmac.Equal(final[:], w.End.TagV[:]) {
+		return ErrTagMismatch
+	}
+	return nil
+}
+
+// ReadTimeWindow returns the records of st with from <= TS < to as a
+// TimeWindow an auditor can verify without reading the rest of the log.
+func ReadTimeWindow(st Store, from, to time.Time) (TimeWindow, error) {
+	w := TimeWindow{From: from, To: to}
+	first, last := uint64(math.MaxUint64), uint64(0)
+	for r, err := range RecordsInTime(st, from, to) {
+		if err != nil {
+			return w, err
+		}
+		first, last = min(first, r.Index), max(last, r.Index)
+	}
+	if last == 0 {
+		return w, nil
+	}
+
+	anchors, err := st.ListAnchors()
+	if err != nil {
+		return w, err
+	}
+	for _, a := range anchors {
+		if a.Index < first && a.Index > w.Anchor.Index {
+			w.Anchor = a
+		}
+		if a.Index >= last && (w.End.Index == 0 || a.Index < w.End.Index) {
+			w.End = a
+		}
+	}
+	end := last
+	if w.End.Index != 0 {
+		end = w.End.Index
+	}
+	for r, err := range RecordRange(st, w.Anchor.Index+1, end) {
+		if err != nil {
+			return w, err
+		}
+		w.Records = append(w.Records, r)
+	}
+	if len(w.Records) == 0 || w.Records[len(w.Records)-1].Index != end {
+		return w, errors.New("time window changed while it was read")
+	}
+	return w, nil
+}
@@ -0,0 +1,276 @@
+package securelog
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+// collectTime returns the indexes RecordsInTime yields for [from, to).
+func collectTime(t *testing.T, st Store, from, to time.Time) []uint64 {
+	t.Helper()
+	var got []uint64
+	for r, err := range RecordsInTime(st, from, to) {
+		if err != nil {
+			t.Fatalf("RecordsInTime: %v", err)
+		}
+		got = append(got, r.Index)
+	}
+	return got
+}
+
+// filterTime returns the indexes of records with from <= TS < to.
+func filterTime(records []Record, from, to time.Time) []uint64 {
+	var want []uint64
+	for _, r := range records {
+		if r.TS >= from.UnixNano() && r.TS < to.UnixNano() {
+			want = append(want, r.Index)
+		}
+	}
+	return want
+}
+
+func TestIterTime_AllStores(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-time-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	backends := []struct {
+		name string
+		open func() (Store, error)
+	}{
+		{"file", func() (Store, error) { return OpenFileStore(filepath.Join(tmpDir, "file")) }},
+		{"segmented", func() (Store, error) {
+			return OpenFileStoreWithOptions(filepath.Join(tmpDir, "segmented"), FileStoreOptions{SegmentMaxBytes: 4096})
+		}},
+		{"sqlite", func() (Store, error) { return OpenSQLiteStore(filepath.Join(tmpDir, "time.db")) }},
+		{"bolt", func() (Store, error) { return OpenBoltStore(filepath.Join(tmpDir, "time.bolt")) }},
+		{"memory", func() (Store, error) { return NewMemoryStore(), nil }},
+	}
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			store, err := b.open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			if _, ok := store.(TimeStore); !ok {
+				t.Fatalf("%T does not implement TimeStore", store)
+			}
+			logger, err := New(Config{AnchorEvery: 50}, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a0, _ := logger.GetInitialKeys()
+			ts := NewTrustedServer()
+			ts.RegisterLog(InitCommitment{LogID: "times", KeyA0: a0})
+			a1, err := ts.ReleaseA1("times")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Timestamps mostly rise, but every seventh record is stamped an
+			// hour early, as a skewed clock would.
+			for i := 0; i < 300; i++ {
+				ts := base.Add(time.Duration(i) * time.Minute)
+				if i%7 == 6 {
+					ts = ts.Add(-time.Hour)
+				}
+				if _, err := logger.Append([]byte("entry"), ts); err != nil {
+					t.Fatal(err)
+				}
+			}
+			records := readAllRecords(t, store)
+
+			for _, span := range [][2]int{{0, 1}, {100, 130}, {10, 290}, {-120, 0}, {-60, 400}, {500, 600}} {
+				from := base.Add(time.Duration(span[0]) * time.Minute)
+				to := base.Add(time.Duration(span[1]) * time.Minute)
+				got, want := collectTime(t, store, from, to), filterTime(records, from, to)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("Records in [%d, %d) min = %v, want %v", span[0], span[1], got, want)
+				}
+			}
+
+			// The window [100, 130) starts at record 101 and, through the
+			// skewed records, runs on to record 189, stamped at minute 128.
+			// It is read on to the next anchor, 200.
+			from, to := base.Add(100*time.Minute), base.Add(130*time.Minute)
+			w, err := ReadTimeWindow(store, from, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := filterTime(records, from, to)
+			if w.Anchor.Index != 100 || w.End.Index != 200 || w.Records[0].Index != 101 || w.Records[len(w.Records)-1].Index != 200 {
+				t.Errorf("Window between anchors %d and %d spans %d..%d, want 100 and 200, 101..200",
+					w.Anchor.Index, w.End.Index, w.Records[0].Index, w.Records[len(w.Records)-1].Index)
+			}
+			var matching []uint64
+			for _, r := range w.Matching() {
+				matching = append(matching, r.Index)
+			}
+			if !reflect.DeepEqual(matching, want) {
+				t.Errorf("Matching = %v, want %v", matching, want)
+			}
+			if err := w.Verify(); err != nil {
+				t.Errorf("Verify of a clean window: %v", err)
+			}
+			truncated := w
+			truncated.Records = w.Records[:len(w.Records)-15]
+			if err := truncated.Verify(); !errors.Is(err, ErrTagMismatch) {
+				t.Errorf("Expected ErrTagMismatch for a window cut short, got: %v", err)
+			}
+			w.Records[3].Msg = []byte("forged")
+			if err := w.Verify(); !errors.Is(err, ErrTagMismatch) {
+				t.Errorf("Expected ErrTagMismatch for a forged record, got: %v", err)
+			}
+
+			// A window before the first anchor is verified from the A_1 T
+			// releases to auditors.
+			w, err = ReadTimeWindow(store, base, base.Add(10*time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w.Anchor.Index != 0 || w.Records[0].Index != 1 {
+				t.Fatalf("Window at log start anchored at %d, starts at %d", w.Anchor.Index, w.Records[0].Index)
+			}
+			w.Anchor.Key = a1
+			if err := w.Verify(); err != nil {
+				t.Errorf("Verify of a window at log start: %v", err)
+			}
+			w.Anchor.Key = a0
+			if err := w.Verify(); !errors.Is(err, ErrTagMismatch) {
+				t.Errorf("Expected ErrTagMismatch from A_0, got: %v", err)
+			}
+
+			w, err = ReadTimeWindow(store, base.Add(500*time.Minute), base.Add(600*time.Minute))
+			if err != nil || len(w.Records) != 0 || w.Verify() != nil {
+				t.Errorf("Empty window = %+v, %v", w, err)
+			}
+		})
+	}
+}
+
+func TestTimeWindow_Redactable(t *testing.T) {
+	store := NewMemoryStore()
+	logger, err := New(Config{AnchorEvery: 4, Redactable: true}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 10; i++ {
+		if _, err := logger.Append([]byte("entry"), base.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := ReadTimeWindow(store, base.Add(6*time.Minute), base.Add(8*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Anchor.Index != 4 || w.End.Index != 8 || len(w.Records) != 4 {
+		t.Fatalf("Window between anchors %d and %d holds %d records, want 4 and 8, 4", w.Anchor.Index, w.End.Index, len(w.Records))
+	}
+	// Read as a plain log, the records' envelopes do not match their MACs.
+	if err := w.Verify(); !errors.Is(err, ErrTagMismatch) {
+		t.Errorf("Expected ErrTagMismatch verifying a redactable window as plain, got: %v", err)
+	}
+	w.Redactable = true
+	if err := w.Verify(); err != nil {
+		t.Errorf("Verify of a redactable window: %v", err)
+	}
+	w.Redactable = false
+	if err := w.VerifyContext(context.Background(), VerifyOptions{Redactable: true}); err != nil {
+		t.Errorf("VerifyContext of a redactable window: %v", err)
+	}
+}
+
+func TestFileStore_TimeIndexRecovery(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-times-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := New(Config{AnchorEvery: 10}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 200; i++ {
+		if _, err := logger.Append([]byte("entry"), base.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records := readAllRecords(t, store)
+	timesPath := filepath.Join(tmpDir, timesFileName)
+	clean, err := os.ReadFile(timesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (200 + timeBlockSize - 1) / timeBlockSize * timeEntrySize; len(clean) != want {
+		t.Fatalf("times.idx holds %d bytes, want %d", len(clean), want)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := base.Add(70*time.Second), base.Add(140*time.Second)
+	want := filterTime(records, from, to)
+	for _, tc := range []struct {
+		name  string
+		times []byte
+	}{
+		{"missing", nil},
+		{"truncated", clean[:timeEntrySize+5]},
+		{"stale", clean[:len(clean)-timeEntrySize]},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.times == nil {
+				if err := os.Remove(timesPath); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := os.WriteFile(timesPath, tc.times, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			// A read-only store leaves times.idx alone and scans what it
+			// does not cover.
+			reader, err := OpenFileStoreWithOptions(tmpDir, FileStoreOptions{ReadOnly: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := collectTime(t, reader, from, to); !reflect.DeepEqual(got, want) {
+				t.Errorf("Read-only query = %v, want %v", got, want)
+			}
+			if err := reader.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			store, err := OpenFileStore(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			if got, err := os.ReadFile(timesPath); err != nil || !reflect.DeepEqual(got, clean) {
+				t.Errorf("times.idx not rebuilt on open: %v", err)
+			}
+			if got := collectTime(t, store, from, to); !reflect.DeepEqual(got, want) {
+				t.Errorf("Query after rebuild = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package securelog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timestamp policy violations reported as Finding kinds.
+var (
+	// ErrTimestampRegression indicates an entry is older than its predecessor.
+	ErrTimestampRegression = errors.New("timestamp earlier than previous entry")
+	// ErrTimestampBeforeStart indicates an entry predates the log's start.
+	ErrTimestampBeforeStart = errors.New("timestamp before log start")
+	// ErrTimestampAfterClose indicates an entry postdates the log's closure.
+	ErrTimestampAfterClose = errors.New("timestamp after log close")
+	// ErrTimestampGap indicates consecutive entries are further apart than allowed.
+	ErrTimestampGap = errors.New("gap between timestamps exceeds limit")
+	// ErrTimestampFuture indicates an entry lies too far in the future.
+	ErrTimestampFuture = errors.New("timestamp too far in the future")
+)
+
+// TimestampPolicy configures the checks applied to Record.TS during verification.
+// MAC verification already authenticates timestamps; the policy additionally
+// checks that they are plausible. The zero value disables all checks.
+type TimestampPolicy struct {
+	Monotonic     bool          // entries must not be older than their predecessor
+	NotBefore     time.Time     // earliest acceptable timestamp (zero = unchecked)
+	NotAfter      time.Time     // latest acceptable timestamp (zero = unchecked)
+	FromProtocol  bool          // take NotBefore/NotAfter from the log's commitment and closure where known
+	MaxGap        time.Duration // maximum distance between consecutive entries (0 = unlimited)
+	MaxFutureSkew time.Duration // how far past Now an entry may lie (0 = unchecked)
+	Now           func() time.Time
+}
+
+// withProtocolBounds fills NotBefore/NotAfter from the log's start and close
+// times when FromProtocol is set. Zero times leave the bound unchanged.
+func (p TimestampPolicy) withProtocolBounds(start, closed time.Time) TimestampPolicy {
+	if !p.FromProtocol {
+		return p
+	}
+	if !start.IsZero() {
+		p.NotBefore = start
+	}
+	if !closed.IsZero() {
+		p.NotAfter = closed
+	}
+	return p
+}
+
+func (p TimestampPolicy) enabled() bool {
+	return p.Monotonic || !p.NotBefore.IsZero() || !p.NotAfter.IsZero() || p.MaxGap > 0 || p.MaxFutureSkew > 0
+}
+
+// Finding is a policy violation detected on an otherwise authentic record.
+// Kind is one of the ErrTimestamp* sentinels.
+type Finding struct {
+	Index  uint64
+	Kind   error
+	Detail string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("entry %d: %v (%s)", f.Index, f.Kind, f.Detail)
+}
+
+// PolicyError is returned when the MAC chain verified but policy checks found
+// violations. errors.Is matches every Finding kind it contains.
+type PolicyError struct {
+	Findings []Finding
+}
+
+func (e *PolicyError) Error() string {
+	parts := make([]string, len(e.Findings))
+	for i, f := range e.Findings {
+		parts[i] = f.String()
+	}
+	return fmt.Sprintf("%d policy violation(s): %s", len(e.Findings), strings.Join(parts, "; "))
+}
+
+// Unwrap exposes the finding kinds to errors.Is.
+func (e *PolicyError) Unwrap() []error {
+	kinds := make([]error, 0, len(e.Findings))
+	for _, f := range e.Findings {
+		kinds = append(kinds, f.Kind)
+	}
+	return kinds
+}
+
+// timestampChecker applies a TimestampPolicy record by record.
+// A nil checker is valid and checks nothing.
+type timestampChecker struct {
+	policy   TimestampPolicy
+	now      time.Time
+	prev     int64
+	havePrev bool
+	findings []Finding
+}
+
+func newTimestampChecker(p TimestampPolicy) *timestampChecker {
+	if !p.enabled() {
+		return nil
+	}
+	c := &timestampChecker{policy: p}
+	if p.MaxFutureSkew > 0 {
+		if p.Now != nil {
+			c.now = p.Now()
+		} else {
+			c.now = time.Now()
+		}
+	}
+	return c
+}
+
+// seed sets the timestamp of the entry preceding the first checked one, so a
+// run resumed from a checkpoint applies the same checks as a full replay.
+func (c *timestampChecker) seed(ts int64) {
+	if c == nil {
+		return
+	}
+	c.prev = ts
+	c.havePrev = true
+}
+
+func (c *timestampChecker) check(r Record) {
+	if c == nil {
+		return
+	}
+	p := c.policy
+	ts := time.Unix(0, r.TS)
+
+	if !p.NotBefore.IsZero() && ts.Before(p.NotBefore) {
+		c.add(r.Index, ErrTimestampBeforeStart, "%s < %s", ts, p.NotBefore)
+	}
+	if !p.NotAfter.IsZero() && ts.After(p.NotAfter) {
+		c.add(r.Index, ErrTimestampAfterClose, "%s > %s", ts, p.NotAfter)
+	}
+	if p.MaxFutureSkew > 0 && ts.Sub(c.now) > p.MaxFutureSkew {
+		c.add(r.Index, ErrTimestampFuture, "%s ahead of %s", ts.Sub(c.now), c.now)
+	}
+	if c.havePrev {
+		delta := time.Duration(r.TS - c.prev)
+		if p.Monotonic && delta < 0 {
+			c.add(r.Index, ErrTimestampRegression, "%s before previous entry", -delta)
+		}
+		if p.MaxGap > 0 && delta > p.MaxGap {
+			c.add(r.Index, ErrTimestampGap, "%s after previous entry", delta)
+		}
+	}
+	c.prev = r.TS
+	c.havePrev = true
+}
+
+func (c *timestampChecker) add(idx uint64, kind error, format string, args ...any) {
+	c.findings = append(c.findings, Finding{Index: idx, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+}
+
+// err returns a *PolicyError carrying all findings, or nil.
+func (c *timestampChecker) err() error {
+	if c == nil || len(c.findings) == 0 {
+		return nil
+	}
+	return &PolicyError{Findings: c.findings}
+}
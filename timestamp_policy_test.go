@@ -0,0 +1,272 @@
+package securelog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+func TestVerifyChainContext_TimestampPolicy(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-tspolicy-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a0, _ := logger.GetInitialKeys()
+
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	stamps := []time.Time{
+		base,
+		base.Add(time.Second),
+		base.Add(-time.Second),   // 3: goes backwards
+		base.Add(2 * time.Hour),  // 4: gap of more than an hour
+		base.Add(48 * time.Hour), // 5: beyond NotAfter, gap and future
+	}
+	for _, ts := range stamps {
+		if _, err := logger.Append([]byte("entry"), ts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records := readAllRecords(t, store)
+	var zeroTag [32]byte
+
+	// Without a policy the chain verifies.
+	if _, err := VerifyChainContext(context.Background(), records, 0, a0, zeroTag, true, VerifyOptions{}); err != nil {
+		t.Fatalf("Expected success without policy, got: %v", err)
+	}
+
+	opts := VerifyOptions{Timestamps: TimestampPolicy{
+		Monotonic:     true,
+		NotBefore:     base,
+		NotAfter:      base.Add(24 * time.Hour),
+		MaxGap:        time.Hour,
+		MaxFutureSkew: time.Hour,
+		Now:           func() time.Time { return base.Add(24 * time.Hour) },
+	}}
+	lastTag, err := VerifyChainContext(context.Background(), records, 0, a0, zeroTag, true, opts)
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("Expected *PolicyError, got: %v", err)
+	}
+	if lastTag != records[len(records)-1].TagV {
+		t.Error("Expected final tag to be returned alongside policy findings")
+	}
+
+	want := []struct {
+		idx  uint64
+		kind error
+	}{
+		{3, ErrTimestampBeforeStart},
+		{3, ErrTimestampRegression},
+		{4, ErrTimestampGap},
+		{5, ErrTimestampAfterClose},
+		{5, ErrTimestampFuture},
+		{5, ErrTimestampGap},
+	}
+	if len(policyErr.Findings) != len(want) {
+		t.Fatalf("Expected %d findings, got %d: %v", len(want), len(policyErr.Findings), policyErr)
+	}
+	for i, w := range want {
+		f := policyErr.Findings[i]
+		if f.Index != w.idx || f.Kind != w.kind {
+			t.Errorf("Finding %d: expected %v at %d, got %v at %d", i, w.kind, w.idx, f.Kind, f.Index)
+		}
+	}
+	for _, kind := range []error{ErrTimestampRegression, ErrTimestampGap, ErrTimestampFuture} {
+		if !errors.Is(err, kind) {
+			t.Errorf("Expected errors.Is(err, %v)", kind)
+		}
+	}
+
+	// A MAC failure takes precedence over policy findings.
+	records[1].Msg = []byte("tampered")
+	if _, err := VerifyChainContext(context.Background(), records, 0, a0, zeroTag, true, opts); err != ErrTagMismatch {
+		t.Errorf("Expected ErrTagMismatch, got: %v", err)
+	}
+}
+
+func TestFinalVerify_TimestampBoundsFromProtocol(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-tsbounds-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logID := "ts-bounds"
+	commit, openMsg, err := logger.InitProtocol(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := logger.Append([]byte("backdated"), commit.StartTime.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	closeMsg, err := logger.CloseProtocol(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer()
+	server.TrustedServer.RegisterLog(commit)
+	server.TrustedServer.RegisterOpen(openMsg)
+	if err := server.TrustedServer.AcceptClosure(closeMsg); err != nil {
+		t.Fatal(err)
+	}
+	records := readAllRecords(t, store)
+
+	// Policy off: the log is authentic.
+	if err := server.TrustedServer.FinalVerify(logID, records); err != nil {
+		t.Fatalf("FinalVerify failed: %v", err)
+	}
+
+	// Policy on: served over HTTP so the findings travel through the protobuf response.
+	server.SetTimestampPolicy(TimestampPolicy{FromProtocol: true})
+	fresh := NewTrustedServer()
+	fresh.RegisterLog(commit)
+	fresh.RegisterOpen(openMsg)
+	if err := fresh.AcceptClosure(closeMsg); err != nil {
+		t.Fatal(err)
+	}
+	server.TrustedServer = fresh
+
+	mux := http.NewServeMux()
+	server.SetupRoutes(mux)
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+
+	ok, err := NewProtoHTTPTransport(httpServer.URL).SendLogFile(logID, records)
+	if ok {
+		t.Fatal("Expected verification to fail on backdated entry")
+	}
+	if !errors.Is(err, ErrTimestampBeforeStart) {
+		t.Fatalf("Expected ErrTimestampBeforeStart, got: %v", err)
+	}
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) || policyErr.Findings[0].Index != 2 {
+		t.Errorf("Expected finding for entry 2, got: %v", err)
+	}
+}
+
+func TestTrustedServer_TimestampPolicyAcrossCheckpoint(t *testing.T) {
+	store := NewMemoryStore()
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logID := "ts-resume"
+	commit, openMsg, err := logger.InitProtocol(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := commit.StartTime
+	for i := 1; i <= 3; i++ {
+		if _, err := logger.Append([]byte("entry"), base.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Entry 5 regresses behind entry 4, the tail checkpoint of the first run.
+	if _, err := logger.Append([]byte("late"), base.Add(2*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	records := readAllRecords(t, store)
+
+	opts := VerifyOptions{Timestamps: TimestampPolicy{Monotonic: true}}
+	for _, warm := range []bool{false, true} {
+		ts := NewTrustedServer()
+		ts.RegisterLog(commit)
+		ts.RegisterOpen(openMsg)
+		recs := records
+		if warm {
+			if _, err := ts.VerifyTrustedChainContext(context.Background(), logID, records[:4], opts); err != nil {
+				t.Fatal(err)
+			}
+			recs = records[4:]
+		}
+		_, err := ts.VerifyTrustedChainContext(context.Background(), logID, recs, opts)
+		var policyErr *PolicyError
+		if !errors.As(err, &policyErr) || policyErr.Findings[0].Index != 5 {
+			t.Errorf("warm=%v: expected regression at entry 5, got: %v", warm, err)
+		}
+	}
+}
+
+func TestTrustedServer_TimestampPolicyAfterCachedRun(t *testing.T) {
+	store := NewMemoryStore()
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logID := "ts-cached"
+	commit, openMsg, err := logger.InitProtocol(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := commit.StartTime
+	// Entries 3 and 5 go backwards.
+	for _, s := range []int{2, 1, 3, 2, 4} {
+		if _, err := logger.Append([]byte("entry"), base.Add(time.Duration(s)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records := readAllRecords(t, store)
+	opts := VerifyOptions{Timestamps: TimestampPolicy{Monotonic: true}}
+
+	fresh := NewTrustedServer()
+	fresh.RegisterLog(commit)
+	fresh.RegisterOpen(openMsg)
+	_, want := fresh.VerifyTrustedChainContext(context.Background(), logID, records, opts)
+	var wantErr *PolicyError
+	if !errors.As(want, &wantErr) || len(wantErr.Findings) != 2 {
+		t.Fatalf("Expected 2 findings from a fresh verifier, got: %v", want)
+	}
+
+	// A run without a policy caches the tail; the policy run must still
+	// see the records behind that checkpoint.
+	ts := NewTrustedServer()
+	ts.RegisterLog(commit)
+	ts.RegisterOpen(openMsg)
+	if _, err := ts.VerifyTrustedChain(logID, records); err != nil {
+		t.Fatal(err)
+	}
+	if got := ts.TrustedAnchors(logID); len(got) == 0 || got[len(got)-1].Index != uint64(len(records)) {
+		t.Fatalf("Expected the tail to be cached, got %+v", got)
+	}
+	_, err = ts.VerifyTrustedChainContext(context.Background(), logID, records, opts)
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) || len(policyErr.Findings) != len(wantErr.Findings) {
+		t.Fatalf("Expected %v after a cached run, got: %v", want, err)
+	}
+	for i, f := range policyErr.Findings {
+		if f.Index != wantErr.Findings[i].Index {
+			t.Errorf("Finding %d at entry %d, fresh verifier reports %d", i, f.Index, wantErr.Findings[i].Index)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package securelog
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"errors"
 	"fmt"
@@ -109,6 +110,36 @@ func (t *HTTPTransport) SendClosure(closeMsg CloseMessage) error {
 	return nil
 }
 
+// SendTreeHead submits a signed tree head via HTTP POST.
+// A conflict with a head already recorded by T is reported as ErrTreeHeadConflict.
+func (t *HTTPTransport) SendTreeHead(sth SignedTreeHead) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(sth); err != nil {
+		return fmt.Errorf("encode tree head: %w", err)
+	}
+
+	url := t.BaseURL + "/api/v1/logs/treehead"
+	resp, err := t.Client.Post(url, "application/octet-stream", &buf)
+	if err != nil {
+		return fmt.Errorf("post tree head: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return treeHeadResponseError(resp)
+}
+
+// treeHeadResponseError maps a tree head submission response to an error.
+func treeHeadResponseError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusConflict {
+		return fmt.Errorf("%w: %s", ErrTreeHeadConflict, bytes.TrimSpace(body))
+	}
+	return fmt.Errorf("server returned %d: %s", resp.StatusCode, body)
+}
+
 // SendLogFile sends the complete log file for verification.
 func (t *HTTPTransport) SendLogFile(logID string, records []Record) (bool, error) {
 	var buf bytes.Buffer
@@ -175,6 +206,7 @@ func (t *LocalTransport) SendLogFile(logID string, records []Record) (bool, erro
 //
 //	{dir}/commitments/{logID}.gob - InitCommitment
 //	{dir}/closures/{logID}.gob - CloseMessage
+//	{dir}/checkpoints/{logID}.gob - T-chain checkpoints derived by VerifyLog
 //	{dir}/logs/{logID}/ - Log file storage (uses file_store.go)
 type FolderTransport struct {
 	BaseDir string
@@ -182,13 +214,14 @@ type FolderTransport struct {
 }
 
 // NewFolderTransport creates a new folder-based transport.
-// Creates directory structure: commitments/, closures/, logs/
+// Creates directory structure: commitments/, opens/, closures/, checkpoints/, logs/
 func NewFolderTransport(dir string) (*FolderTransport, error) {
 	// Create directory structure
 	dirs := []string{
 		filepath.Join(dir, "commitments"),
 		filepath.Join(dir, "opens"),
 		filepath.Join(dir, "closures"),
+		filepath.Join(dir, "checkpoints"),
 		filepath.Join(dir, "logs"),
 	}
 	for _, d := range dirs {
@@ -321,15 +354,75 @@ func (ft *FolderTransport) LoadClosure(logID string) (CloseMessage, error) {
 	return closeMsg, nil
 }
 
-// GetLogStore returns a Store for reading the log records from {BaseDir}/logs/{logID}/
+// LoadTrustedAnchors reads T-chain checkpoints from {BaseDir}/checkpoints/{logID}.gob.
+// A log that has never been verified has no checkpoints.
+func (ft *FolderTransport) LoadTrustedAnchors(logID string) ([]TrustedAnchor, error) {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	path := filepath.Join(ft.BaseDir, "checkpoints", logID+".gob")
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var anchors []TrustedAnchor
+	if err := gob.NewDecoder(f).Decode(&anchors); err != nil {
+		return nil, err
+	}
+	return anchors, nil
+}
+
+// SaveTrustedAnchors writes T-chain checkpoints to {BaseDir}/checkpoints/{logID}.gob
+func (ft *FolderTransport) SaveTrustedAnchors(logID string, anchors []TrustedAnchor) error {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	path := filepath.Join(ft.BaseDir, "checkpoints", logID+".gob")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return gob.NewEncoder(f).Encode(anchors)
+}
+
+// GetLogStore opens the file store in {BaseDir}/logs/{logID}/, creating it if
+// needed. The caller must Close it.
 func (ft *FolderTransport) GetLogStore(logID string) (Store, error) {
 	logDir := filepath.Join(ft.BaseDir, "logs", logID)
 	return OpenFileStore(logDir)
 }
 
+// GetLogStoreReadOnly opens the existing file store in {BaseDir}/logs/{logID}/
+// for reading only, without locking or recovering the logger's files. The
+// caller must Close it.
+func (ft *FolderTransport) GetLogStoreReadOnly(logID string) (Store, error) {
+	logDir := filepath.Join(ft.BaseDir, "logs", logID)
+	return OpenFileStoreWithOptions(logDir, FileStoreOptions{ReadOnly: true})
+}
+
 // VerifyLog performs final T-chain verification for a log stored in the folder.
 // This is the equivalent of TrustedServer.FinalVerify() for folder-based deployments.
+// Checkpoints derived along the way are saved under checkpoints/, so later runs
+// only re-verify entries after the furthest verified position.
 func (ft *FolderTransport) VerifyLog(logID string) error {
+	return ft.VerifyLogContext(context.Background(), logID, VerifyOptions{})
+}
+
+// VerifyLogContext is VerifyLog with cancellation and progress reporting.
+// The log's mode is taken from its commitment; for a redactable log
+// opts.RedactionKey must be T's redaction key or redacted records fail.
+func (ft *FolderTransport) VerifyLogContext(ctx context.Context, logID string, opts VerifyOptions) error {
 	commit, err := ft.LoadCommitment(logID)
 	if err != nil {
 		return fmt.Errorf("load commitment: %w", err)
@@ -345,22 +438,18 @@ func (ft *FolderTransport) VerifyLog(logID string) error {
 		return fmt.Errorf("load closure: %w", err)
 	}
 
-	store, err := ft.GetLogStore(logID)
+	store, err := ft.GetLogStoreReadOnly(logID)
 	if err != nil {
 		return fmt.Errorf("open log store: %w", err)
 	}
+	defer store.Close()
 
-	ch, done, err := store.Iter(1)
+	records, err := collectRecords(ctx, store, 1)
 	if err != nil {
 		return fmt.Errorf("iterate records: %w", err)
 	}
-	var records []Record
-	for r := range ch {
-		records = append(records, r)
-	}
-	_ = done()
 
-	if err := VerifyCloseMessage(records, closeMsg); err != nil {
+	if err := verifyCloseMessage(records, closeMsg, commit.Redactable); err != nil {
 		return fmt.Errorf("verify close message: %w", err)
 	}
 
@@ -371,16 +460,17 @@ func (ft *FolderTransport) VerifyLog(logID string) error {
 	if first.Index != open.FirstIndex {
 		return errors.New("opening index mismatch")
 	}
-	if string(first.Msg) != "START" {
+	if msg, _ := messageOf(first, commit.Redactable); string(msg) != "START" {
 		return errors.New("missing opening entry")
 	}
 
 	var zeroTag [32]byte
-	firstV, err := VerifyFrom(records[:1], 0, commit.KeyA0, zeroTag)
+	opening := chainOptions{redactable: commit.Redactable}
+	firstV, err := verifyChain(ctx, records[:1], 0, commit.KeyA0, zeroTag, true, opening)
 	if err != nil {
 		return fmt.Errorf("verify opening V-chain: %w", err)
 	}
-	firstT, err := VerifyFromTrusted(records[:1], 0, commit.KeyB0, zeroTag)
+	firstT, err := verifyChain(ctx, records[:1], 0, commit.KeyB0, zeroTag, false, opening)
 	if err != nil {
 		return fmt.Errorf("verify opening T-chain: %w", err)
 	}
@@ -388,15 +478,32 @@ func (ft *FolderTransport) VerifyLog(logID string) error {
 		return errors.New("opening tag mismatch")
 	}
 
-	finalTag, err := VerifyFromTrusted(records, 0, commit.KeyB0, zeroTag)
+	anchors, err := ft.LoadTrustedAnchors(logID)
+	if err != nil {
+		return fmt.Errorf("load trusted anchors: %w", err)
+	}
+	cache := newTrustedAnchorCache(DefaultTrustedAnchorEvery)
+	for _, a := range anchors {
+		cache.add(a)
+	}
+
+	opts.Timestamps = opts.Timestamps.withProtocolBounds(commit.StartTime, closeMsg.CloseTime)
+	opts.Redactable = commit.Redactable
+	co := newChainOptions(opts)
+	co.logID = logID
+	final, err := cache.verify(ctx, records, commit.KeyB0, co)
+	co.progress.finish(err)
 	if err != nil {
 		return fmt.Errorf("verify T-chain: %w", err)
 	}
 
-	if !hmacEqual(finalTag[:], closeMsg.FinalTagT[:]) {
+	if !hmacEqual(final.TagT[:], closeMsg.FinalTagT[:]) {
 		return errors.New("final T-chain tag mismatch")
 	}
 
+	if err := ft.SaveTrustedAnchors(logID, cache.list()); err != nil {
+		return fmt.Errorf("save trusted anchors: %w", err)
+	}
 	return nil
 }
 
@@ -448,7 +555,8 @@ func NewRemoteLogger(cfg Config, store Store, transport Transport, logID string)
 	return rl, nil
 }
 
-// Close sends the closure message to trusted server T.
+// Close sends the closure message to trusted server T and then closes the
+// logger's store.
 func (rl *RemoteLogger) Close() error {
 	return rl.closeOnce()
 }
@@ -473,5 +581,8 @@ func (rl *RemoteLogger) closeOnce() error {
 	rl.mu.Lock()
 	rl.closed = true
 	rl.mu.Unlock()
+	if err := rl.Logger.store.Close(); err != nil {
+		return fmt.Errorf("close store: %w", err)
+	}
 	return nil
 }
@@ -410,13 +410,28 @@ func TestFolderTransport_Complete(t *testing.T) {
 		t.Fatalf("VerifyLog failed: %v", err)
 	}
 
+	// VerifyLog releases the store it opens.
+	if fds, err := os.ReadDir("/proc/self/fd"); err == nil {
+		for i := 0; i < 5; i++ {
+			if err := transport.VerifyLog(logID); err != nil {
+				t.Fatalf("VerifyLog failed: %v", err)
+			}
+		}
+		if after, _ := os.ReadDir("/proc/self/fd"); len(after) > len(fds) {
+			t.Errorf("VerifyLog leaked %d file descriptors", len(after)-len(fds))
+		}
+	}
+
 	// Test GetLogStore
 	logStore, err := transport.GetLogStore(logID)
 	if err != nil {
 		t.Fatalf("GetLogStore failed: %v", err)
 	}
 	if logStore == nil {
-		t.Error("GetLogStore returned nil")
+		t.Fatal("GetLogStore returned nil")
+	}
+	if err := logStore.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
 	}
 }
 
@@ -0,0 +1,186 @@
+package securelog
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrInvalidTreeHeadSignature indicates a signed tree head fails signature verification.
+	ErrInvalidTreeHeadSignature = errors.New("invalid tree head signature")
+	// ErrTreeHeadConflict indicates two tree heads for the same log and size disagree
+	// on the root, i.e. different parties were shown different logs.
+	ErrTreeHeadConflict = errors.New("conflicting tree heads")
+	// ErrTreeHeadKeyMismatch indicates a tree head was not signed by the key
+	// bound to the log in its InitCommitment.
+	ErrTreeHeadKeyMismatch = errors.New("tree head not signed by the log's registered key")
+)
+
+// treeHeadDomain separates tree head signatures from other uses of the key.
+const treeHeadDomain = "securelog tree head v1\x00"
+
+// TreeHead is a party's view of a log: the Merkle tree size and root at a point in time.
+type TreeHead struct {
+	LogID     string
+	Size      uint64
+	Root      [32]byte
+	Timestamp time.Time
+}
+
+// SignedTreeHead is a TreeHead signed with Ed25519 by the party vouching for it
+// (usually the logger). Whether PublicKey is trusted is up to the caller;
+// T trusts only the key bound in the log's InitCommitment.
+type SignedTreeHead struct {
+	Head      TreeHead
+	PublicKey ed25519.PublicKey
+	Signature []byte
+}
+
+// signedBytes is the canonical encoding covered by the signature.
+func (h TreeHead) signedBytes() []byte {
+	buf := make([]byte, 0, len(treeHeadDomain)+4+len(h.LogID)+8+32+8)
+	buf = append(buf, treeHeadDomain...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(h.LogID)))
+	buf = append(buf, h.LogID...)
+	buf = binary.BigEndian.AppendUint64(buf, h.Size)
+	buf = append(buf, h.Root[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(h.Timestamp.UnixNano()))
+	return buf
+}
+
+// SignTreeHead signs h with key.
+func SignTreeHead(h TreeHead, key ed25519.PrivateKey) SignedTreeHead {
+	pub, _ := key.Public().(ed25519.PublicKey)
+	return SignedTreeHead{
+		Head:      h,
+		PublicKey: append(ed25519.PublicKey(nil), pub...),
+		Signature: ed25519.Sign(key, h.signedBytes()),
+	}
+}
+
+// Verify checks the signature against the embedded public key.
+func (s SignedTreeHead) Verify() error {
+	if len(s.PublicKey) != ed25519.PublicKeySize || !ed25519.Verify(s.PublicKey, s.Head.signedBytes(), s.Signature) {
+		return ErrInvalidTreeHeadSignature
+	}
+	return nil
+}
+
+// SignedTreeHead returns the logger's current tree head for logID, signed with key.
+func (l *Logger) SignedTreeHead(logID string, key ed25519.PrivateKey) SignedTreeHead {
+	size, root := l.TreeHead()
+	return SignTreeHead(TreeHead{LogID: logID, Size: size, Root: root, Timestamp: time.Now()}, key)
+}
+
+// VerifyTreeHeadConsistency checks that newer extends older: both heads are
+// validly signed, describe the same log, and proof links their roots.
+func VerifyTreeHeadConsistency(older, newer SignedTreeHead, proof ConsistencyProof) error {
+	if err := older.Verify(); err != nil {
+		return fmt.Errorf("older head: %w", err)
+	}
+	if err := newer.Verify(); err != nil {
+		return fmt.Errorf("newer head: %w", err)
+	}
+	if older.Head.LogID != newer.Head.LogID {
+		return errors.New("tree heads belong to different logs")
+	}
+	if proof.OldSize != older.Head.Size || proof.NewSize != newer.Head.Size {
+		return errors.New("proof sizes do not match tree heads")
+	}
+	return VerifyConsistency(proof, older.Head.Root, newer.Head.Root)
+}
+
+// RecordTreeHead stores a signed tree head so views of the log seen by
+// different parties can be compared. Only heads signed by the TreeHeadKey
+// bound in the log's commitment are accepted; others fail with
+// ErrTreeHeadKeyMismatch and are not stored. A head whose root differs from a
+// stored head of the same size is then equivocation by the logger: it is kept
+// as evidence and ErrTreeHeadConflict is returned.
+func (ts *TrustedServer) RecordTreeHead(sth SignedTreeHead) error {
+	if err := sth.Verify(); err != nil {
+		return err
+	}
+	commit, ok := ts.commitments[sth.Head.LogID]
+	if !ok {
+		return errors.New("log not registered with trusted server")
+	}
+	if len(commit.TreeHeadKey) == 0 || !bytes.Equal(sth.PublicKey, commit.TreeHeadKey) {
+		return ErrTreeHeadKeyMismatch
+	}
+
+	ts.headMu.Lock()
+	defer ts.headMu.Unlock()
+
+	heads := ts.treeHeads[sth.Head.LogID]
+	var conflict *SignedTreeHead
+	for i, h := range heads {
+		if h.Head.Size != sth.Head.Size || !bytes.Equal(h.PublicKey, sth.PublicKey) {
+			continue
+		}
+		if bytes.Equal(h.Signature, sth.Signature) {
+			return nil // already recorded
+		}
+		if h.Head.Root != sth.Head.Root && conflict == nil {
+			conflict = &heads[i]
+		}
+	}
+
+	heads = append(heads, sth)
+	sort.SliceStable(heads, func(i, j int) bool { return heads[i].Head.Size < heads[j].Head.Size })
+	ts.treeHeads[sth.Head.LogID] = heads
+
+	if conflict != nil {
+		return fmt.Errorf("%w: size %d has roots %x and %x",
+			ErrTreeHeadConflict, sth.Head.Size, conflict.Head.Root[:8], sth.Head.Root[:8])
+	}
+	return nil
+}
+
+// TreeHeads returns the signed tree heads recorded for logID, ordered by size.
+func (ts *TrustedServer) TreeHeads(logID string) []SignedTreeHead {
+	ts.headMu.Lock()
+	defer ts.headMu.Unlock()
+	return append([]SignedTreeHead(nil), ts.treeHeads[logID]...)
+}
+
+// checkTreeHeads verifies that every tree head recorded for logID under its
+// registered TreeHeadKey is a prefix of records, which must be the whole log
+// starting at index 1.
+func (ts *TrustedServer) checkTreeHeads(logID string, records []Record) error {
+	commit := ts.commitments[logID]
+	key := commit.TreeHeadKey
+	var heads []SignedTreeHead
+	for _, h := range ts.TreeHeads(logID) {
+		if len(key) != 0 && bytes.Equal(h.PublicKey, key) {
+			heads = append(heads, h)
+		}
+	}
+	if len(heads) == 0 {
+		return nil
+	}
+	if records[0].Index != 1 {
+		return errors.New("tree heads require the log from its first entry")
+	}
+
+	var tree merkleTree
+	next := 0
+	for _, h := range heads {
+		if h.Head.Size > uint64(len(records)) {
+			return fmt.Errorf("%w: head for size %d beyond final index %d",
+				ErrTreeHeadConflict, h.Head.Size, len(records))
+		}
+		for tree.size < h.Head.Size {
+			tree.append(LeafHash(records[next], commit.Redactable))
+			next++
+		}
+		if root := tree.root(); root != h.Head.Root {
+			return fmt.Errorf("%w: head for size %d does not match the log", ErrTreeHeadConflict, h.Head.Size)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package securelog
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+func TestSignedTreeHead_Consistency(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-sth-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger, err := New(Config{TreeHeadKey: key}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logID := "sth-log"
+	commit, openMsg, err := logger.InitProtocol(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	older := logger.SignedTreeHead(logID, key)
+	if err := older.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	for i := 0; i < 7; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	newer := logger.SignedTreeHead(logID, key)
+
+	proof, err := logger.ConsistencyProof(older.Head.Size, newer.Head.Size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyTreeHeadConsistency(older, newer, proof); err != nil {
+		t.Fatalf("VerifyTreeHeadConsistency failed: %v", err)
+	}
+
+	pbProof, err := FromProtoConsistencyProof(ToProtoConsistencyProof(proof))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbHead, err := FromProtoSignedTreeHead(ToProtoSignedTreeHead(newer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyTreeHeadConsistency(older, pbHead, pbProof); err != nil {
+		t.Fatalf("VerifyTreeHeadConsistency after protobuf round trip failed: %v", err)
+	}
+
+	// Changing anything covered by the signature invalidates it.
+	forged := newer
+	forged.Head.Size++
+	if err := forged.Verify(); !errors.Is(err, ErrInvalidTreeHeadSignature) {
+		t.Errorf("Expected ErrInvalidTreeHeadSignature, got: %v", err)
+	}
+
+	ts := NewTrustedServer()
+	ts.RegisterLog(commit)
+	ts.RegisterOpen(openMsg)
+	if err := ts.RecordTreeHead(older); err != nil {
+		t.Fatalf("RecordTreeHead failed: %v", err)
+	}
+	if err := ts.RecordTreeHead(newer); err != nil {
+		t.Fatalf("RecordTreeHead failed: %v", err)
+	}
+	if err := ts.RecordTreeHead(newer); err != nil {
+		t.Fatalf("Re-recording the same head failed: %v", err)
+	}
+	if got := len(ts.TreeHeads(logID)); got != 2 {
+		t.Fatalf("Expected 2 tree heads, got %d", got)
+	}
+
+	// A different root for an already seen size is a split view.
+	split := newer.Head
+	split.Root[0] ^= 0xFF
+	if err := ts.RecordTreeHead(SignTreeHead(split, key)); !errors.Is(err, ErrTreeHeadConflict) {
+		t.Errorf("Expected ErrTreeHeadConflict, got: %v", err)
+	}
+
+	closeMsg, err := logger.CloseProtocol(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.AcceptClosure(closeMsg); err != nil {
+		t.Fatal(err)
+	}
+	// The conflicting head recorded above is kept, so final verification fails.
+	if err := ts.FinalVerify(logID, readAllRecords(t, store)); !errors.Is(err, ErrTreeHeadConflict) {
+		t.Errorf("Expected FinalVerify to report ErrTreeHeadConflict, got: %v", err)
+	}
+
+	clean := NewTrustedServer()
+	clean.RegisterLog(commit)
+	clean.RegisterOpen(openMsg)
+	if err := clean.AcceptClosure(closeMsg); err != nil {
+		t.Fatal(err)
+	}
+	if err := clean.RecordTreeHead(older); err != nil {
+		t.Fatal(err)
+	}
+	if err := clean.RecordTreeHead(newer); err != nil {
+		t.Fatal(err)
+	}
+
+	// Anyone can sign a conflicting head with their own key; T must not
+	// store it, or an honest log could never pass final verification.
+	_, foreign, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clean.RecordTreeHead(SignTreeHead(split, foreign)); !errors.Is(err, ErrTreeHeadKeyMismatch) {
+		t.Errorf("Expected ErrTreeHeadKeyMismatch for foreign key, got: %v", err)
+	}
+	if got := len(clean.TreeHeads(logID)); got != 2 {
+		t.Errorf("Expected foreign head not to be stored, have %d heads", got)
+	}
+	if err := clean.FinalVerify(logID, readAllRecords(t, store)); err != nil {
+		t.Errorf("FinalVerify with consistent tree heads failed: %v", err)
+	}
+
+	// A log registered without a tree head key accepts no heads at all.
+	unbound := commit
+	unbound.TreeHeadKey = nil
+	keyless := NewTrustedServer()
+	keyless.RegisterLog(unbound)
+	if err := keyless.RecordTreeHead(older); !errors.Is(err, ErrTreeHeadKeyMismatch) {
+		t.Errorf("Expected ErrTreeHeadKeyMismatch without a bound key, got: %v", err)
+	}
+
+	pbCommit, err := FromProtoInitCommitment(ToProtoInitCommitment(commit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pbCommit.TreeHeadKey.Equal(commit.TreeHeadKey) {
+		t.Error("TreeHeadKey lost in protobuf round trip")
+	}
+}
+
+func TestServer_HandleTreeHead(t *testing.T) {
+	server := NewServer()
+	mux := http.NewServeMux()
+	server.SetupRoutes(mux)
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, foreign, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logID := "sth-http"
+	server.TrustedServer.RegisterLog(InitCommitment{LogID: logID, StartTime: time.Now(), TreeHeadKey: pub})
+
+	head := TreeHead{LogID: logID, Size: 3, Root: [32]byte{1}, Timestamp: time.Now()}
+	protoTransport := NewProtoHTTPTransport(httpServer.URL)
+	if err := protoTransport.SendTreeHead(SignTreeHead(head, key)); err != nil {
+		t.Fatalf("SendTreeHead (protobuf) failed: %v", err)
+	}
+
+	head.Root = [32]byte{2}
+	if err := protoTransport.SendTreeHead(SignTreeHead(head, foreign)); err == nil || errors.Is(err, ErrTreeHeadConflict) {
+		t.Errorf("Expected rejection of head signed by foreign key, got: %v", err)
+	}
+	gobTransport := NewHTTPTransport(httpServer.URL)
+	if err := gobTransport.SendTreeHead(SignTreeHead(head, key)); !errors.Is(err, ErrTreeHeadConflict) {
+		t.Errorf("Expected ErrTreeHeadConflict from gob transport, got: %v", err)
+	}
+
+	head.LogID = "unknown"
+	if err := protoTransport.SendTreeHead(SignTreeHead(head, key)); err == nil {
+		t.Error("Expected error for unregistered log")
+	}
+}
@@ -0,0 +1,188 @@
+package securelog
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// DefaultTrustedAnchorEvery is the default interval, in entries, at which the
+// trusted server T caches T-chain checkpoints while verifying a log.
+const DefaultTrustedAnchorEvery = 1000
+
+// TrustedAnchor is a T-chain checkpoint (B_i, μ_T,i) derived by the trusted
+// server T while verifying a log. Unlike Anchor it carries the trusted key B_i,
+// so it must only ever be kept server-side and never written to the logger's Store.
+type TrustedAnchor struct {
+	Index uint64
+	Key   [KeySize]byte // B_i - trusted server chain key at checkpoint i
+	TagT  [32]byte      // μ_T,i at checkpoint i
+	TS    int64         // Record.TS of entry i, seeds timestamp checks on resume (0 = unknown)
+}
+
+// trustedAnchorCache holds the checkpoints T has derived for one log.
+// Anchors are kept sorted by index; besides the regular interval checkpoints
+// the cache always remembers the furthest verified position so the next run
+// only has to cover the new suffix.
+//
+// Each cache carries its own lock so that verifying one log never blocks
+// verification of the others; exported entry points hold mu, the lowercase
+// helpers expect it to be held.
+type trustedAnchorCache struct {
+	mu      sync.Mutex
+	every   uint64
+	anchors []TrustedAnchor
+}
+
+func newTrustedAnchorCache(every uint64) *trustedAnchorCache {
+	return &trustedAnchorCache{every: every}
+}
+
+// list returns a copy of the cached anchors.
+func (c *trustedAnchorCache) list() []TrustedAnchor {
+	return append([]TrustedAnchor(nil), c.anchors...)
+}
+
+// latest returns the furthest cached checkpoint.
+func (c *trustedAnchorCache) latest() (TrustedAnchor, bool) {
+	if len(c.anchors) == 0 {
+		return TrustedAnchor{}, false
+	}
+	return c.anchors[len(c.anchors)-1], true
+}
+
+// latestWithin returns the furthest checkpoint usable for records spanning
+// [first, last]: the records must contain the entry right after the checkpoint.
+func (c *trustedAnchorCache) latestWithin(first, last uint64) (TrustedAnchor, bool) {
+	for i := len(c.anchors) - 1; i >= 0; i-- {
+		a := c.anchors[i]
+		if a.Index+1 >= first && a.Index <= last {
+			return a, true
+		}
+	}
+	return TrustedAnchor{}, false
+}
+
+// earliestWithin returns the earliest checkpoint usable for records spanning
+// [first, last].
+func (c *trustedAnchorCache) earliestWithin(first, last uint64) (TrustedAnchor, bool) {
+	for _, a := range c.anchors {
+		if a.Index+1 >= first && a.Index <= last {
+			return a, true
+		}
+	}
+	return TrustedAnchor{}, false
+}
+
+// onGrid reports whether idx falls on the regular checkpoint interval.
+func (c *trustedAnchorCache) onGrid(idx uint64) bool {
+	return c.every != 0 && idx%c.every == 0
+}
+
+// add inserts a checkpoint, replacing an existing one at the same index.
+func (c *trustedAnchorCache) add(a TrustedAnchor) {
+	i := sort.Search(len(c.anchors), func(i int) bool { return c.anchors[i].Index >= a.Index })
+	if i < len(c.anchors) && c.anchors[i].Index == a.Index {
+		c.anchors[i] = a
+		return
+	}
+	c.anchors = append(c.anchors, TrustedAnchor{})
+	copy(c.anchors[i+1:], c.anchors[i:])
+	c.anchors[i] = a
+}
+
+// advance records a newly verified tail position together with the interval
+// checkpoints passed on the way, dropping the previous tail checkpoint if it
+// was not on the regular interval.
+func (c *trustedAnchorCache) advance(tail TrustedAnchor, passed []TrustedAnchor) {
+	if last, ok := c.latest(); ok && last.Index < tail.Index && !c.onGrid(last.Index) {
+		c.anchors = c.anchors[:len(c.anchors)-1]
+	}
+	for _, a := range passed {
+		c.add(a)
+	}
+	c.add(tail)
+}
+
+// verify checks the T-chain over records, starting from the furthest usable
+// cached checkpoint or from B_0 when none applies, and caches new checkpoints.
+// Records must be contiguous; when they include the checkpoint entry itself its
+// stored μ_T must still match the cached one. With a timestamp policy the
+// run starts from B_0, or from the earliest usable checkpoint of a log that
+// no longer starts at 1, so the policy sees every timestamp a full replay
+// would. Returns the state after the last record.
+func (c *trustedAnchorCache) verify(
+	ctx context.Context, records []Record, b0 [KeySize]byte, co chainOptions,
+) (TrustedAnchor, error) {
+	if len(records) == 0 {
+		return TrustedAnchor{}, errors.New("no records to verify")
+	}
+	first, last := records[0].Index, records[len(records)-1].Index
+
+	start := TrustedAnchor{Key: b0}
+	a, ok := c.latestWithin(first, last)
+	if co.timestamps != nil {
+		a, ok = c.earliestWithin(first, last)
+		ok = ok && first != 1
+	}
+	if ok {
+		start = a
+	} else if first != 1 {
+		return TrustedAnchor{}, errors.New("no trusted anchor covers the first record")
+	}
+
+	pos := int(start.Index + 1 - first)
+	if start.Index >= first {
+		boundary := records[start.Index-first]
+		if boundary.Index != start.Index {
+			return TrustedAnchor{}, ErrGap
+		}
+		if !hmac.Equal(boundary.TagT[:], start.TagT[:]) {
+			return TrustedAnchor{}, ErrTagMismatch
+		}
+		// μ_T,i authenticates the boundary entry, including its timestamp.
+		start.TS = boundary.TS
+	}
+	// Records before the checkpoint are not re-MACed, but a redaction marker
+	// may have been written into them since; it must still be T's.
+	for _, r := range records[:pos] {
+		if err := co.checkRedacted(r); err != nil {
+			return TrustedAnchor{}, err
+		}
+		co.timestamps.check(r)
+	}
+
+	end, passed, err := c.walk(ctx, records[pos:], start, co)
+	if err != nil {
+		return TrustedAnchor{}, err
+	}
+	c.advance(end, passed)
+	return end, nil
+}
+
+// walk verifies the T-chain over records starting from checkpoint start and
+// returns the final state together with the interval checkpoints passed on
+// the way. The cache itself is not modified. When start carries a timestamp,
+// the timestamp policy continues from it as if the run had started at 1.
+func (c *trustedAnchorCache) walk(
+	ctx context.Context, records []Record, start TrustedAnchor, co chainOptions,
+) (TrustedAnchor, []TrustedAnchor, error) {
+	if start.Index > 0 && start.TS != 0 {
+		co.timestamps.seed(start.TS)
+	}
+	var passed []TrustedAnchor
+	end := start
+	co.onStep = func(r Record, key [KeySize]byte, tag [32]byte) {
+		end = TrustedAnchor{Index: r.Index, Key: key, TagT: tag, TS: r.TS}
+		if c.onGrid(r.Index) {
+			passed = append(passed, end)
+		}
+	}
+	_, err := verifyChain(ctx, records, start.Index, start.Key, start.TagT, false, co)
+	if err != nil {
+		return TrustedAnchor{}, nil, err
+	}
+	return end, passed, nil
+}
@@ -0,0 +1,333 @@
+package securelog
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+//revive:disable:cyclomatic High complexity acceptable in tests
+//revive:disable:cognitive-complexity High complexity acceptable in tests
+//revive:disable:function-length Long test functions are acceptable
+
+func readAllRecords(t *testing.T, store Store) []Record {
+	t.Helper()
+	ch, done, err := store.Iter(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []Record
+	for r := range ch {
+		records = append(records, r)
+	}
+	_ = done()
+	return records
+}
+
+func TestTrustedVerifier_DerivesAnchors(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-tanchor-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, b0 := logger.GetInitialKeys()
+
+	for i := 0; i < 25; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	verifier := NewTrustedVerifier(store, b0)
+	verifier.SetTrustedAnchorInterval(10)
+	if err := verifier.VerifyAll(); err != nil {
+		t.Fatalf("VerifyAll failed: %v", err)
+	}
+
+	anchors := verifier.TrustedAnchors()
+	want := []uint64{10, 20, 25}
+	if len(anchors) != len(want) {
+		t.Fatalf("Expected %d trusted anchors, got %d", len(want), len(anchors))
+	}
+	records := readAllRecords(t, store)
+	for i, a := range anchors {
+		if a.Index != want[i] {
+			t.Errorf("Anchor %d: expected index %d, got %d", i, want[i], a.Index)
+		}
+		if a.TagT != records[a.Index-1].TagT {
+			t.Errorf("Anchor %d: μ_T does not match stored record", a.Index)
+		}
+	}
+
+	// The derived B_i must be usable with VerifyFromAnchor.
+	if err := verifier.VerifyFromAnchor(anchors[0].Index, anchors[0].Key, anchors[0].TagT); err != nil {
+		t.Fatalf("VerifyFromAnchor with derived anchor failed: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, err := logger.Append([]byte("more"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := verifier.VerifyIncremental(); err != nil {
+		t.Fatalf("VerifyIncremental failed: %v", err)
+	}
+	anchors = verifier.TrustedAnchors()
+	want = []uint64{10, 20, 30, 35}
+	if len(anchors) != len(want) {
+		t.Fatalf("Expected %d trusted anchors after incremental run, got %d", len(want), len(anchors))
+	}
+	for i, a := range anchors {
+		if a.Index != want[i] {
+			t.Errorf("Anchor %d: expected index %d, got %d", i, want[i], a.Index)
+		}
+	}
+
+	// A fresh verifier restored from saved anchors only needs the suffix.
+	restored := NewTrustedVerifier(store, [KeySize]byte{})
+	restored.LoadTrustedAnchors(anchors)
+	if err := restored.VerifyIncremental(); err != nil {
+		t.Fatalf("VerifyIncremental from restored anchors failed: %v", err)
+	}
+}
+
+func TestTrustedServer_IncrementalFinalVerify(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-tserver-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store, err := OpenFileStore(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logID := "incremental-log"
+	commit, openMsg, err := logger.InitProtocol(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := NewTrustedServer()
+	ts.SetTrustedAnchorInterval(5)
+	ts.RegisterLog(commit)
+	ts.RegisterOpen(openMsg)
+
+	for i := 0; i < 11; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Verify the still-open log; T caches checkpoints at 5, 10 and the tail (12).
+	last, err := ts.VerifyTrustedChain(logID, readAllRecords(t, store))
+	if err != nil {
+		t.Fatalf("VerifyTrustedChain failed: %v", err)
+	}
+	if last.Index != 12 {
+		t.Fatalf("Expected tail checkpoint at 12, got %d", last.Index)
+	}
+	if got := len(ts.TrustedAnchors(logID)); got != 3 {
+		t.Fatalf("Expected 3 trusted anchors, got %d", got)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	closeMsg, err := logger.CloseProtocol(logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.AcceptClosure(closeMsg); err != nil {
+		t.Fatal(err)
+	}
+
+	records := readAllRecords(t, store)
+
+	// Only the suffix after the tail checkpoint is re-verified, so a suffix
+	// starting at the checkpoint entry is sufficient.
+	if _, err := ts.VerifyTrustedChain(logID, records[11:]); err != nil {
+		t.Fatalf("VerifyTrustedChain on suffix failed: %v", err)
+	}
+
+	if err := ts.FinalVerify(logID, records); err != nil {
+		t.Fatalf("FinalVerify failed: %v", err)
+	}
+	anchors := ts.TrustedAnchors(logID)
+	if anchors[len(anchors)-1].Index != closeMsg.FinalIndex {
+		t.Errorf("Expected last checkpoint at %d, got %d", closeMsg.FinalIndex, anchors[len(anchors)-1].Index)
+	}
+
+	// Tampering with the checkpoint entry itself is still caught.
+	tampered := append([]Record(nil), records...)
+	tampered[15].TagT[0] ^= 0xFF
+	if _, err := ts.VerifyTrustedChain(logID, tampered[15:]); err != ErrTagMismatch {
+		t.Errorf("Expected ErrTagMismatch for modified checkpoint entry, got: %v", err)
+	}
+
+	// A suffix not covered by any checkpoint is rejected.
+	fresh := NewTrustedServer()
+	fresh.RegisterLog(commit)
+	if _, err := fresh.VerifyTrustedChain(logID, records[3:]); err == nil {
+		t.Error("Expected error verifying uncovered suffix")
+	}
+}
+
+func TestFolderTransport_SavesTrustedAnchors(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "securelog-folder-anchors-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	transport, err := NewFolderTransport(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logID := "folder-anchors"
+	store, err := OpenFileStore(filepath.Join(tmpDir, "logs", logID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*fileStore).Close()
+
+	logger, err := NewRemoteLogger(Config{}, store, transport, logID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := transport.VerifyLog(logID); err != nil {
+		t.Fatalf("VerifyLog failed: %v", err)
+	}
+
+	anchors, err := transport.LoadTrustedAnchors(logID)
+	if err != nil {
+		t.Fatalf("LoadTrustedAnchors failed: %v", err)
+	}
+	if len(anchors) != 1 || anchors[0].Index != 6 {
+		t.Fatalf("Expected a single checkpoint at 6, got %+v", anchors)
+	}
+
+	// Second run resumes from the saved checkpoint.
+	if err := transport.VerifyLog(logID); err != nil {
+		t.Fatalf("second VerifyLog failed: %v", err)
+	}
+}
+
+func TestTrustedServer_AnchorLockIsPerLog(t *testing.T) {
+	ts := NewTrustedServer()
+	logIDs := []string{"busy-log", "other-log"}
+	records := make(map[string][]Record)
+	for _, logID := range logIDs {
+		store := NewMemoryStore()
+		logger, err := New(Config{}, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		commit, openMsg, err := logger.InitProtocol(logID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts.RegisterLog(commit)
+		ts.RegisterOpen(openMsg)
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		records[logID] = readAllRecords(t, store)
+	}
+
+	// Simulate a long verification of busy-log holding its cache lock.
+	busy := ts.anchorCache("busy-log")
+	busy.mu.Lock()
+	defer busy.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ts.VerifyTrustedChain("other-log", records["other-log"])
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("VerifyTrustedChain failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("verifying one log blocked on another log's anchor cache")
+	}
+}
+
+func TestTrustedVerifier_ConcurrentCacheAccess(t *testing.T) {
+	store := NewMemoryStore()
+	logger, err := New(Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, b0 := logger.GetInitialKeys()
+	for i := 0; i < 40; i++ {
+		if _, err := logger.Append([]byte("entry"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	verifier := NewTrustedVerifier(store, b0)
+	if err := verifier.VerifyAll(); err != nil {
+		t.Fatal(err)
+	}
+	saved := verifier.TrustedAnchors()
+
+	// Run under -race: reconfiguring and restoring the cache while it is
+	// verified must not race.
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- verifier.VerifyIncremental()
+		}()
+		go func(i int) {
+			defer wg.Done()
+			verifier.SetTrustedAnchorInterval(uint64(5 + i))
+			verifier.LoadTrustedAnchors(saved)
+			_ = verifier.TrustedAnchors()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("VerifyIncremental: %v", err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package securelog
 
 import (
+	"context"
 	"crypto/hmac"
 	"errors"
 )
@@ -17,16 +18,16 @@ func NewSemiTrustedVerifier(store Store) *SemiTrustedVerifier {
 
 // VerifyFromAnchor loads records after anchor.Index and verifies the V-chain using (A_i, μ_V,i).
 func (v *SemiTrustedVerifier) VerifyFromAnchor(a Anchor) error {
-	ch, done, err := v.store.Iter(a.Index + 1)
+	return v.VerifyFromAnchorContext(context.Background(), a, VerifyOptions{})
+}
+
+// VerifyFromAnchorContext is VerifyFromAnchor with cancellation and progress reporting.
+func (v *SemiTrustedVerifier) VerifyFromAnchorContext(ctx context.Context, a Anchor, opts VerifyOptions) error {
+	recs, err := collectRecords(ctx, v.store, a.Index+1)
 	if err != nil {
 		return err
 	}
-	defer done()
-	var recs []Record
-	for r := range ch {
-		recs = append(recs, r)
-	}
-	final, err := VerifyFrom(recs, a.Index, a.Key, a.TagV)
+	final, err := VerifyChainContext(ctx, recs, a.Index, a.Key, a.TagV, true, opts)
 	if err != nil {
 		return err
 	}
@@ -45,60 +46,101 @@ func (v *SemiTrustedVerifier) VerifyFromAnchor(a Anchor) error {
 
 // TrustedVerifier represents the trusted server (T) from Section 4.1 of the paper.
 // T holds the B_i key chain and can verify the T-chain which is protected from malicious verifiers.
+// While verifying, T derives its own checkpoints (B_i, μ_T,i) and caches them in memory,
+// so later runs only need to re-verify entries appended since the last run.
 type TrustedVerifier struct {
 	store        Store
 	initialKeyB0 [KeySize]byte // B_0 - initial key for T-chain
+	anchors      *trustedAnchorCache
 }
 
 // NewTrustedVerifier creates a new trusted verifier that validates the T-chain using initial key B_0.
 func NewTrustedVerifier(store Store, b0 [KeySize]byte) *TrustedVerifier {
-	return &TrustedVerifier{store: store, initialKeyB0: b0}
+	return &TrustedVerifier{
+		store:        store,
+		initialKeyB0: b0,
+		anchors:      newTrustedAnchorCache(DefaultTrustedAnchorEvery),
+	}
 }
 
-// VerifyAll verifies the entire log from the beginning using the T-chain.
-// This provides final validation that cannot be forged by a malicious verifier V.
-func (t *TrustedVerifier) VerifyAll() error {
-	ch, done, err := t.store.Iter(1)
-	if err != nil {
-		return err
-	}
-	defer done()
-	var recs []Record
-	for r := range ch {
-		recs = append(recs, r)
-	}
+// SetTrustedAnchorInterval sets how often (in entries) checkpoints are cached.
+// Zero keeps only the furthest verified position.
+func (t *TrustedVerifier) SetTrustedAnchorInterval(every uint64) {
+	t.anchors.mu.Lock()
+	defer t.anchors.mu.Unlock()
+	t.anchors.every = every
+}
 
-	var zeroTag [32]byte
-	final, err := VerifyFromTrusted(recs, 0, t.initialKeyB0, zeroTag)
-	if err != nil {
-		return err
-	}
-	tail, ok, err := t.store.Tail()
-	if err != nil {
-		return err
+// TrustedAnchors returns the T-chain checkpoints derived so far.
+// They contain B_i and must stay with T.
+func (t *TrustedVerifier) TrustedAnchors() []TrustedAnchor {
+	t.anchors.mu.Lock()
+	defer t.anchors.mu.Unlock()
+	return t.anchors.list()
+}
+
+// LoadTrustedAnchors restores checkpoints previously returned by TrustedAnchors.
+func (t *TrustedVerifier) LoadTrustedAnchors(anchors []TrustedAnchor) {
+	t.anchors.mu.Lock()
+	defer t.anchors.mu.Unlock()
+	for _, a := range anchors {
+		t.anchors.add(a)
 	}
+}
+
+// VerifyIncremental verifies only the entries appended after the furthest
+// cached checkpoint, falling back to VerifyAll when no checkpoint exists yet.
+func (t *TrustedVerifier) VerifyIncremental() error {
+	return t.VerifyIncrementalContext(context.Background(), VerifyOptions{})
+}
+
+// VerifyIncrementalContext is VerifyIncremental with cancellation and progress reporting.
+func (t *TrustedVerifier) VerifyIncrementalContext(ctx context.Context, opts VerifyOptions) error {
+	t.anchors.mu.Lock()
+	a, ok := t.anchors.latest()
+	t.anchors.mu.Unlock()
 	if !ok {
-		return errors.New("tail state unavailable")
+		return t.VerifyAllContext(ctx, opts)
 	}
-	if !hmac.Equal(final[:], tail.TagT[:]) {
-		return ErrTagMismatch
-	}
-	return nil
+	return t.verifyFrom(ctx, a, opts)
+}
+
+// VerifyAll verifies the entire log from the beginning using the T-chain.
+// This provides final validation that cannot be forged by a malicious verifier V.
+func (t *TrustedVerifier) VerifyAll() error {
+	return t.VerifyAllContext(context.Background(), VerifyOptions{})
+}
+
+// VerifyAllContext is VerifyAll with cancellation and progress reporting.
+func (t *TrustedVerifier) VerifyAllContext(ctx context.Context, opts VerifyOptions) error {
+	return t.verifyFrom(ctx, TrustedAnchor{Key: t.initialKeyB0}, opts)
 }
 
 // VerifyFromAnchor verifies from a checkpoint using the T-chain.
-// The anchor must contain B_i and μ_T,i for checkpoint i.
+// The anchor must contain B_i and μ_T,i for checkpoint i, e.g. one of TrustedAnchors.
 func (t *TrustedVerifier) VerifyFromAnchor(idx uint64, bi [KeySize]byte, tagT [32]byte) error {
-	ch, done, err := t.store.Iter(idx + 1)
+	return t.VerifyFromAnchorContext(context.Background(), idx, bi, tagT, VerifyOptions{})
+}
+
+// VerifyFromAnchorContext is VerifyFromAnchor with cancellation and progress reporting.
+func (t *TrustedVerifier) VerifyFromAnchorContext(
+	ctx context.Context, idx uint64, bi [KeySize]byte, tagT [32]byte, opts VerifyOptions,
+) error {
+	return t.verifyFrom(ctx, TrustedAnchor{Index: idx, Key: bi, TagT: tagT}, opts)
+}
+
+// verifyFrom verifies the T-chain from start to the store's tail and caches
+// the checkpoints passed on the way.
+func (t *TrustedVerifier) verifyFrom(ctx context.Context, start TrustedAnchor, opts VerifyOptions) (err error) {
+	recs, err := collectRecords(ctx, t.store, start.Index+1)
 	if err != nil {
 		return err
 	}
-	defer done()
-	var recs []Record
-	for r := range ch {
-		recs = append(recs, r)
-	}
-	final, err := VerifyFromTrusted(recs, idx, bi, tagT)
+	co := newChainOptions(opts)
+	defer func() { co.progress.finish(err) }()
+	t.anchors.mu.Lock()
+	defer t.anchors.mu.Unlock()
+	final, passed, err := t.anchors.walk(ctx, recs, start, co)
 	if err != nil {
 		return err
 	}
@@ -109,8 +151,9 @@ func (t *TrustedVerifier) VerifyFromAnchor(idx uint64, bi [KeySize]byte, tagT [3
 	if !ok {
 		return errors.New("tail state unavailable")
 	}
-	if !hmac.Equal(final[:], tail.TagT[:]) {
+	if !hmac.Equal(final.TagT[:], tail.TagT[:]) {
 		return ErrTagMismatch
 	}
+	t.anchors.advance(final, passed)
 	return nil
 }
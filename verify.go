@@ -1,9 +1,12 @@
 package securelog
 
 import (
+	"context"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/binary"
 	"errors"
+	"fmt"
 )
 
 // ErrGap indicates missing or non-sequential log entries were detected during verification.
@@ -12,39 +15,140 @@ var ErrGap = errors.New("gap or reordering detected")
 // ErrTagMismatch indicates a MAC tag verification failure, suggesting tampering or incorrect keys.
 var ErrTagMismatch = errors.New("tag mismatch: tampering or wrong key")
 
+// ErrStorageCorruption indicates a stored record whose checksum does not
+// match its content: media corruption rather than tampering, which would
+// leave a well-formed record with a bad MAC tag.
+var ErrStorageCorruption = errors.New("storage corruption")
+
+// StoreReadError reports that a store could not be read back, e.g. a
+// truncated record, an I/O failure or a checksum mismatch (ErrStorageCorruption).
+// It is a storage fault rather than a cryptographic one: the records before
+// it may still verify.
+type StoreReadError struct {
+	Offset int64  // byte offset of the unreadable record in its data file, or -1 if the store has no byte layout
+	Index  uint64 // index of the last record read successfully (0 if none)
+	Err    error
+}
+
+func (e *StoreReadError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("store read error after record %d: %v", e.Index, e.Err)
+	}
+	return fmt.Sprintf("store read error at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *StoreReadError) Unwrap() error { return e.Err }
+
 // VerifyChain verifies either the V-chain or T-chain depending on useVerifierChain.
 func VerifyChain(
 	records []Record, startIdx uint64, kStart [KeySize]byte,
 	tStart [32]byte, useVerifierChain bool,
+) (lastTag [32]byte, err error) {
+	return verifyChain(context.Background(), records, startIdx, kStart, tStart, useVerifierChain, chainOptions{})
+}
+
+// VerifyChainContext is VerifyChain with cancellation, progress reporting and
+// timestamp policy checks. It returns ctx.Err() as soon as ctx is cancelled.
+// If the chain verifies but opts.Timestamps is violated, the final tag is
+// returned together with a *PolicyError listing every finding.
+func VerifyChainContext(
+	ctx context.Context, records []Record, startIdx uint64, kStart [KeySize]byte,
+	tStart [32]byte, useVerifierChain bool, opts VerifyOptions,
+) (lastTag [32]byte, err error) {
+	co := newChainOptions(opts)
+	lastTag, err = verifyChain(ctx, records, startIdx, kStart, tStart, useVerifierChain, co)
+	co.progress.finish(err)
+	return lastTag, err
+}
+
+// chainOptions carries the optional per-record hooks used by verifyChain.
+type chainOptions struct {
+	progress   *progressTracker
+	timestamps *timestampChecker
+	// onStep, when non-nil, is called after every verified record with the
+	// evolved key and aggregate tag, which lets T derive checkpoints as it goes.
+	onStep     func(r Record, key [KeySize]byte, tag [32]byte)
+	onRedacted func(Redaction)
+
+	redactable   bool              // records are redactable envelopes (Config.Redactable)
+	redactionKey ed25519.PublicKey // T's key every redaction marker must be signed with
+	logID        string            // when set, redaction markers must name this log
+	eitherMode   bool              // accept a record MACed in either mode (forensics only)
+	// firstKey marks kStart as the key of the first record itself (A_1 from
+	// ReleaseA1) rather than the key before it.
+	firstKey bool
+}
+
+func newChainOptions(opts VerifyOptions) chainOptions {
+	return chainOptions{
+		progress:     newProgressTracker(opts),
+		timestamps:   newTimestampChecker(opts.Timestamps),
+		onRedacted:   opts.OnRedacted,
+		redactable:   opts.Redactable,
+		redactionKey: opts.RedactionKey,
+	}
+}
+
+// macInput returns the bytes the MACs of r cover. In eitherMode the reading
+// of the other mode is returned as alt, so the caller can try both.
+func (co chainOptions) macInput(r Record) (covered, alt []byte, ok bool) {
+	covered, ok = macMessage(r.Msg, co.redactable)
+	if co.eitherMode {
+		alt, _ = macMessage(r.Msg, !co.redactable)
+	}
+	return covered, alt, ok
+}
+
+// checkRedacted verifies the marker of r when r is a redacted record of a
+// redactable log and reports it.
+func (co chainOptions) checkRedacted(r Record) error {
+	if !co.redactable || co.eitherMode {
+		return nil
+	}
+	red, ok, err := checkRedaction(r, co.redactionKey)
+	if err != nil || !ok {
+		return err
+	}
+	if co.logID != "" && red.LogID != co.logID {
+		return fmt.Errorf("record %d: redaction issued for log %q: %w", r.Index, red.LogID, ErrRedactionUnauthorized)
+	}
+	if co.onRedacted != nil {
+		co.onRedacted(red)
+	}
+	return nil
+}
+
+// verifyChain is the shared implementation behind VerifyChain.
+// MAC failures stop verification immediately; policy findings are collected
+// and reported once the whole chain has verified.
+func verifyChain(
+	ctx context.Context, records []Record, startIdx uint64, kStart [KeySize]byte,
+	tStart [32]byte, useVerifierChain bool, co chainOptions,
 ) (lastTag [32]byte, err error) {
 	key := kStart
 	prev := tStart
 	expect := startIdx
 
 	for _, r := range records {
+		if err := ctx.Err(); err != nil {
+			return lastTag, err
+		}
+
 		expect++
 		if r.Index != expect {
 			return lastTag, ErrGap
 		}
 
-		h := sha256.Sum256(key[:])
-		copy(key[:], h[:])
+		if !co.firstKey || r.Index != startIdx+1 {
+			h := sha256.Sum256(key[:])
+			copy(key[:], h[:])
+		}
 
 		var idx [8]byte
 		binary.BigEndian.PutUint64(idx[:], r.Index)
 		var tsb [8]byte
 		binary.BigEndian.PutUint64(tsb[:], uint64(r.TS))
 
-		macVal := mac(key[:], idx[:], tsb[:], r.Msg)
-		//   if starting from zero aggregate (full replay), use μ = H(tag) for the first step
-		//   else (from an anchor), μ = H(μ_prev || tag)
-		var tag [32]byte
-		if isZero32(prev) {
-			tag = htag(macVal)
-		} else {
-			tag = fold(prev, macVal)
-		}
-
 		var stored [32]byte
 		if useVerifierChain {
 			stored = r.TagV
@@ -52,14 +156,50 @@ func VerifyChain(
 			stored = r.TagT
 		}
 
-		if !constantTimeEqual(tag[:], stored[:]) {
+		covered, alt, ok := co.macInput(r)
+		if !ok && alt == nil {
+			// Not an envelope on a redactable log: the message was replaced.
 			return lastTag, ErrTagMismatch
 		}
+		tag, match := chainTag(prev, key, idx[:], tsb[:], covered, stored)
+		if !match && alt != nil {
+			tag, match = chainTag(prev, key, idx[:], tsb[:], alt, stored)
+		}
+		if !match {
+			return lastTag, ErrTagMismatch
+		}
+		if err := co.checkRedacted(r); err != nil {
+			return lastTag, err
+		}
 
 		prev = tag
 		lastTag = tag
+		co.timestamps.check(r)
+		if co.onStep != nil {
+			co.onStep(r, key, tag)
+		}
+		co.progress.step(r.Index)
+	}
+	return lastTag, co.timestamps.err()
+}
+
+// chainTag computes the aggregate tag of a record whose MACs cover covered
+// and reports whether it matches the stored tag. A nil covered never matches.
+//
+//	if starting from zero aggregate (full replay), use μ = H(tag) for the first step
+//	else (from an anchor), μ = H(μ_prev || tag)
+func chainTag(prev [32]byte, key [KeySize]byte, idx, tsb, covered []byte, stored [32]byte) ([32]byte, bool) {
+	if covered == nil {
+		return [32]byte{}, false
+	}
+	macVal := mac(key[:], idx, tsb, covered)
+	var tag [32]byte
+	if isZero32(prev) {
+		tag = htag(macVal)
+	} else {
+		tag = fold(prev, macVal)
 	}
-	return lastTag, nil
+	return tag, constantTimeEqual(tag[:], stored[:])
 }
 
 // constantTimeEqual performs constant-time comparison of two byte slices.